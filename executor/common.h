@@ -623,6 +623,68 @@ static void execute_one(void);
 #if SYZ_EXECUTOR
 static void reply_handshake();
 #endif
+#if SYZ_EXECUTOR && GOOS_linux
+static void loop_template();
+#endif
+
+// wait_for_test waits for the test process pid to exit (killing it if it hangs)
+// and returns its exit status.
+static int wait_for_test(int pid)
+{
+	// We used to use sigtimedwait(SIGCHLD) to wait for the subprocess.
+	// But SIGCHLD is also delivered when a process stops/continues,
+	// so it would require a loop with status analysis and timeout recalculation.
+	// SIGCHLD should also unblock the usleep below, so the spin loop
+	// should be as efficient as sigtimedwait.
+	int status = 0;
+	uint64 start = current_time_ms();
+#if SYZ_EXECUTOR && SYZ_EXECUTOR_USES_SHMEM
+	uint64 last_executed = start;
+	uint32* completed_calls = (uint32*)((char*)output_data + output_offset);
+	uint32 executed_calls = __atomic_load_n(completed_calls, __ATOMIC_RELAXED);
+#endif
+	for (;;) {
+		if (waitpid(-1, &status, WNOHANG | WAIT_FLAGS) == pid)
+			break;
+		sleep_ms(1);
+#if SYZ_EXECUTOR
+#if SYZ_EXECUTOR_USES_SHMEM
+		// Even though the test process executes exit at the end
+		// and execution time of each syscall is bounded by syscall_timeout_ms (~50ms),
+		// this backup watchdog is necessary and its performance is important.
+		// The problem is that exit in the test processes can fail (sic).
+		// One observed scenario is that the test processes prohibits
+		// exit_group syscall using seccomp. Another observed scenario
+		// is that the test processes setups a userfaultfd for itself,
+		// then the main thread hangs when it wants to page in a page.
+		// Below we check if the test process still executes syscalls
+		// and kill it after ~1s of inactivity.
+		uint64 min_timeout_ms = program_timeout_ms * 3 / 5;
+		uint64 inactive_timeout_ms = syscall_timeout_ms * 20;
+		uint64 now = current_time_ms();
+		uint32 now_executed = __atomic_load_n(completed_calls, __ATOMIC_RELAXED);
+		if (executed_calls != now_executed) {
+			executed_calls = now_executed;
+			last_executed = now;
+		}
+		// TODO: adjust timeout for progs with syz_usb_connect call.
+		if ((now - start < program_timeout_ms) &&
+		    (now - start < min_timeout_ms || now - last_executed < inactive_timeout_ms))
+			continue;
+#else
+		if (current_time_ms() - start < program_timeout_ms)
+			continue;
+#endif
+#else
+		if (current_time_ms() - start < /*{{{PROGRAM_TIMEOUT_MS}}}*/)
+			continue;
+#endif
+		debug("killing hanging pid %d\n", pid);
+		kill_and_wait(pid, &status);
+		break;
+	}
+	return status;
+}
 
 static void loop(void)
 {
@@ -633,6 +695,12 @@ static void loop(void)
 	// Tell parent that we are ready to serve.
 	reply_handshake();
 #endif
+#if SYZ_EXECUTOR && GOOS_linux
+	if (flag_fork_template) {
+		loop_template();
+		return;
+	}
+#endif
 #if SYZ_EXECUTOR && GOOS_akaros
 	// For akaros we do exec in the child process because new threads can't be created in the fork child.
 	// Thus we proxy input program over the child_pipe to the child process.
@@ -700,63 +768,13 @@ static void loop(void)
 #if SYZ_EXECUTOR && GOOS_akaros
 		resend_execute(child_pipe[1]);
 #endif
-		// We used to use sigtimedwait(SIGCHLD) to wait for the subprocess.
-		// But SIGCHLD is also delivered when a process stops/continues,
-		// so it would require a loop with status analysis and timeout recalculation.
-		// SIGCHLD should also unblock the usleep below, so the spin loop
-		// should be as efficient as sigtimedwait.
-		int status = 0;
-		uint64 start = current_time_ms();
-#if SYZ_EXECUTOR && SYZ_EXECUTOR_USES_SHMEM
-		uint64 last_executed = start;
-		uint32 executed_calls = __atomic_load_n(output_data, __ATOMIC_RELAXED);
-#endif
-		for (;;) {
-			if (waitpid(-1, &status, WNOHANG | WAIT_FLAGS) == pid)
-				break;
-			sleep_ms(1);
-#if SYZ_EXECUTOR
-#if SYZ_EXECUTOR_USES_SHMEM
-			// Even though the test process executes exit at the end
-			// and execution time of each syscall is bounded by syscall_timeout_ms (~50ms),
-			// this backup watchdog is necessary and its performance is important.
-			// The problem is that exit in the test processes can fail (sic).
-			// One observed scenario is that the test processes prohibits
-			// exit_group syscall using seccomp. Another observed scenario
-			// is that the test processes setups a userfaultfd for itself,
-			// then the main thread hangs when it wants to page in a page.
-			// Below we check if the test process still executes syscalls
-			// and kill it after ~1s of inactivity.
-			uint64 min_timeout_ms = program_timeout_ms * 3 / 5;
-			uint64 inactive_timeout_ms = syscall_timeout_ms * 20;
-			uint64 now = current_time_ms();
-			uint32 now_executed = __atomic_load_n(output_data, __ATOMIC_RELAXED);
-			if (executed_calls != now_executed) {
-				executed_calls = now_executed;
-				last_executed = now;
-			}
-			// TODO: adjust timeout for progs with syz_usb_connect call.
-			if ((now - start < program_timeout_ms) &&
-			    (now - start < min_timeout_ms || now - last_executed < inactive_timeout_ms))
-				continue;
-#else
-			if (current_time_ms() - start < program_timeout_ms)
-				continue;
-#endif
-#else
-			if (current_time_ms() - start < /*{{{PROGRAM_TIMEOUT_MS}}}*/)
-				continue;
-#endif
-			debug("killing hanging pid %d\n", pid);
-			kill_and_wait(pid, &status);
-			break;
-		}
+		int status = wait_for_test(pid);
 #if SYZ_EXECUTOR
 		if (WEXITSTATUS(status) == kFailStatus) {
 			errno = 0;
 			fail("child failed");
 		}
-		reply_execute(0);
+		reply_program_done();
 #endif
 #if SYZ_EXECUTOR || SYZ_USE_TMP_DIR
 		remove_dir(cwdbuf);
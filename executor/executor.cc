@@ -114,6 +114,7 @@ static void resend_execute(int fd);
 #if SYZ_EXECUTOR_USES_FORK_SERVER
 static void receive_handshake();
 static void reply_handshake();
+static void reply_program_done();
 #endif
 
 #if SYZ_EXECUTOR_USES_SHMEM
@@ -133,6 +134,7 @@ const int kMaxOutputCoverage = 6 << 20; // coverage is needed in ~ up to 1/3 of
 const int kMaxOutputSignal = 4 << 20;
 const int kMinOutput = 256 << 10; // if we don't need to send signal, the output is rather short.
 const int kInitialOutput = kMinOutput; // the minimal size to be allocated in the parent process
+const int kMaxOutput = 16 << 20; // keep in sync with ipc.outputSize
 #else
 // We don't fork and allocate the memory only once, so prepare for the worst case.
 const int kInitialOutput = 14 << 20;
@@ -146,6 +148,10 @@ const int kOutFd = 4;
 static uint32* output_data;
 static uint32* output_pos;
 static int output_size;
+// Offset and size of the output region part reserved for the current program of a batch
+// (see execute_req.batch_size). output_limit is 0 for non-batched programs.
+static uint64 output_offset;
+static uint64 output_limit;
 static void mmap_output(int size);
 static uint32* write_output(uint32 v);
 static uint32* write_output_64(uint64 v);
@@ -170,6 +176,7 @@ static bool flag_cgroups;
 static bool flag_close_fds;
 static bool flag_devlink_pci;
 static bool flag_nic_vf;
+static bool flag_fork_template;
 static bool flag_vhci_injection;
 static bool flag_wifi;
 static bool flag_delay_kcov_mmap;
@@ -188,6 +195,9 @@ static uint64 syscall_timeout_ms;
 static uint64 program_timeout_ms;
 static uint64 slowdown_scale;
 
+// Offset of the current program in input_data (non-zero for batched programs).
+static uint64 input_offset;
+
 #define SYZ_EXECUTOR 1
 #include "common.h"
 
@@ -271,6 +281,8 @@ struct thread_t {
 	intptr_t res;
 	uint32 reserrno;
 	bool fault_injected;
+	uint64 start_us; // when the call was started
+	uint64 duration_us; // call execution time, valid once the call has finished
 	cover_t cov;
 	bool soft_fail_state;
 };
@@ -312,6 +324,15 @@ struct execute_req {
 	uint64 program_timeout_ms;
 	uint64 slowdown_scale;
 	uint64 prog_size;
+	// If batch_size > 1, this request is followed by batch_size-1 more requests.
+	// Programs of the batch are executed one-by-one in separate processes
+	// and the reply is sent once all of them are executed.
+	// Batches are supported only with shared memory and fork server.
+	uint64 batch_size;
+	// Location of the program in the input region and of its output in the output region.
+	uint64 prog_offset;
+	uint64 output_offset;
+	uint64 output_size;
 };
 
 struct execute_reply {
@@ -332,7 +353,10 @@ struct call_reply {
 	uint32 call_index;
 	uint32 call_num;
 	uint32 reserrno;
+	uint32 result_lo;
+	uint32 result_hi;
 	uint32 flags;
+	uint32 duration_us;
 	uint32 signal_size;
 	uint32 cover_size;
 	uint32 comps_size;
@@ -374,6 +398,7 @@ static void copyout_call_results(thread_t* th);
 static void write_call_output(thread_t* th, bool finished);
 static void write_extra_output();
 static void execute_call(thread_t* th);
+static uint64 current_time_us();
 static void thread_create(thread_t* th, int id, bool need_coverage);
 static void thread_mmap_cover(thread_t* th);
 static void* worker_thread(void* arg);
@@ -625,6 +650,7 @@ void parse_env_flags(uint64 flags)
 	flag_wifi = flags & (1 << 13);
 	flag_delay_kcov_mmap = flags & (1 << 14);
 	flag_nic_vf = flags & (1 << 15);
+	flag_fork_template = flags & (1 << 16);
 }
 
 #if SYZ_EXECUTOR_USES_FORK_SERVER
@@ -654,35 +680,46 @@ void reply_handshake()
 
 static execute_req last_execute_req;
 
+#if SYZ_EXECUTOR_USES_SHMEM && SYZ_EXECUTOR_USES_FORK_SERVER
+const int kMaxBatch = 64; // keep in sync with ipc.maxBatch
+
+// Requests of the current batch and the index of the next request to execute.
+static execute_req batch_reqs[kMaxBatch];
+static uint64 batch_size;
+static uint64 batch_pos;
+#endif
+
+static void parse_execute_req(const execute_req& req);
+
 void receive_execute()
 {
 	execute_req& req = last_execute_req;
+#if SYZ_EXECUTOR_USES_SHMEM && SYZ_EXECUTOR_USES_FORK_SERVER
+	if (batch_pos < batch_size) {
+		req = batch_reqs[batch_pos++];
+		parse_execute_req(req);
+		return;
+	}
+#endif
 	if (read(kInPipeFd, &req, sizeof(req)) != (ssize_t)sizeof(req))
 		fail("control pipe read failed");
 	if (req.magic != kInMagic)
 		failmsg("bad execute request magic", "magic=0x%llx", req.magic);
 	if (req.prog_size > kMaxInput)
 		failmsg("bad execute prog size", "size=0x%llx", req.prog_size);
-	parse_env_flags(req.env_flags);
-	procid = req.pid;
-	syscall_timeout_ms = req.syscall_timeout_ms;
-	program_timeout_ms = req.program_timeout_ms;
-	slowdown_scale = req.slowdown_scale;
-	flag_collect_signal = req.exec_flags & (1 << 0);
-	flag_collect_cover = req.exec_flags & (1 << 1);
-	flag_dedup_cover = req.exec_flags & (1 << 2);
-	flag_comparisons = req.exec_flags & (1 << 3);
-	flag_threaded = req.exec_flags & (1 << 4);
-	flag_coverage_filter = req.exec_flags & (1 << 5);
-
-	debug("[%llums] exec opts: procid=%llu threaded=%d cover=%d comps=%d dedup=%d signal=%d"
-	      " timeouts=%llu/%llu/%llu prog=%llu filter=%d\n",
-	      current_time_ms() - start_time_ms, procid, flag_threaded, flag_collect_cover,
-	      flag_comparisons, flag_dedup_cover, flag_collect_signal, syscall_timeout_ms,
-	      program_timeout_ms, slowdown_scale, req.prog_size, flag_coverage_filter);
-	if (syscall_timeout_ms == 0 || program_timeout_ms <= syscall_timeout_ms || slowdown_scale == 0)
-		failmsg("bad timeouts", "syscall=%llu, program=%llu, scale=%llu",
-			syscall_timeout_ms, program_timeout_ms, slowdown_scale);
+#if SYZ_EXECUTOR_USES_SHMEM && SYZ_EXECUTOR_USES_FORK_SERVER
+	if (req.batch_size > kMaxBatch)
+		failmsg("bad execute batch size", "size=%llu", req.batch_size);
+	batch_size = req.batch_size;
+	batch_pos = 1;
+	for (uint64 i = 1; i < batch_size; i++) {
+		if (read(kInPipeFd, &batch_reqs[i], sizeof(req)) != (ssize_t)sizeof(req))
+			fail("control pipe read failed");
+		if (batch_reqs[i].magic != kInMagic)
+			failmsg("bad execute request magic", "magic=0x%llx", batch_reqs[i].magic);
+	}
+#endif
+	parse_execute_req(req);
 	if (SYZ_EXECUTOR_USES_SHMEM) {
 		if (req.prog_size)
 			fail("need_prog: no program");
@@ -703,6 +740,46 @@ void receive_execute()
 		failmsg("bad input size", "size=%lld, want=%lld", pos, req.prog_size);
 }
 
+void parse_execute_req(const execute_req& req)
+{
+	parse_env_flags(req.env_flags);
+	procid = req.pid;
+	syscall_timeout_ms = req.syscall_timeout_ms;
+	program_timeout_ms = req.program_timeout_ms;
+	slowdown_scale = req.slowdown_scale;
+	flag_collect_signal = req.exec_flags & (1 << 0);
+	flag_collect_cover = req.exec_flags & (1 << 1);
+	flag_dedup_cover = req.exec_flags & (1 << 2);
+	flag_comparisons = req.exec_flags & (1 << 3);
+	flag_threaded = req.exec_flags & (1 << 4);
+	flag_coverage_filter = req.exec_flags & (1 << 5);
+
+	debug("[%llums] exec opts: procid=%llu threaded=%d cover=%d comps=%d dedup=%d signal=%d"
+	      " timeouts=%llu/%llu/%llu prog=%llu filter=%d batch=%llu\n",
+	      current_time_ms() - start_time_ms, procid, flag_threaded, flag_collect_cover,
+	      flag_comparisons, flag_dedup_cover, flag_collect_signal, syscall_timeout_ms,
+	      program_timeout_ms, slowdown_scale, req.prog_size, flag_coverage_filter, req.batch_size);
+	if (syscall_timeout_ms == 0 || program_timeout_ms <= syscall_timeout_ms || slowdown_scale == 0)
+		failmsg("bad timeouts", "syscall=%llu, program=%llu, scale=%llu",
+			syscall_timeout_ms, program_timeout_ms, slowdown_scale);
+#if SYZ_EXECUTOR_USES_SHMEM && SYZ_EXECUTOR_USES_FORK_SERVER
+	if (req.prog_offset >= kMaxInput || req.prog_offset % sizeof(uint64) != 0)
+		failmsg("bad execute prog offset", "offset=0x%llx", req.prog_offset);
+	if (req.output_offset % SYZ_PAGE_SIZE != 0 || req.output_size % SYZ_PAGE_SIZE != 0 ||
+	    req.output_offset + req.output_size > kMaxOutput)
+		failmsg("bad execute output", "offset=0x%llx size=0x%llx", req.output_offset, req.output_size);
+	input_offset = req.prog_offset;
+	output_offset = req.output_offset;
+	output_limit = req.output_size;
+	// The fork server watches the number of completed calls at the beginning of the program output.
+	if (output_limit != 0)
+		mmap_output(output_offset + SYZ_PAGE_SIZE);
+#else
+	if (req.batch_size > 1 || req.prog_offset || req.output_offset || req.output_size)
+		fail("batched execution is not supported");
+#endif
+}
+
 bool cover_collection_required()
 {
 	return flag_coverage && (flag_collect_signal || flag_collect_cover || flag_comparisons);
@@ -729,11 +806,140 @@ void reply_execute(int status)
 		fail("control pipe write failed");
 }
 
+#if SYZ_EXECUTOR_USES_FORK_SERVER
+// reply_program_done is called by the fork server after each executed program,
+// the reply is sent once all programs of the batch are executed.
+void reply_program_done()
+{
+#if SYZ_EXECUTOR_USES_SHMEM
+	if (batch_pos < batch_size)
+		return;
+#endif
+	reply_execute(0);
+}
+#endif
+
+#if GOOS_linux && SYZ_EXECUTOR_USES_FORK_SERVER && SYZ_EXECUTOR_USES_SHMEM
+#include <sys/socket.h>
+
+// Number of programs executed by a single template process before it's recreated.
+// Programs forked from the same template share its work dir, so the template
+// is periodically restarted to clean up the state they leave behind.
+const int kTemplatePrograms = 100;
+// Number of template restarts without a single executed program before we give up.
+const int kTemplateFailures = 3;
+
+// template_serve runs in the template process: it sets up the test environment once
+// and then forks a test process for each program it receives over sock.
+// The test processes are created with CLONE_PARENT, so they are children of the fork server,
+// which waits for them (and kills them if necessary) the same way it does in the normal mode.
+static void template_serve(int sock, const char* dir)
+{
+	close(kInPipeFd);
+	close(kOutPipeFd);
+	if (chdir(dir))
+		fail("failed to chdir");
+	setup_test();
+#if SYZ_HAVE_SETUP_EXT_TEST
+	setup_ext_test();
+#endif
+	for (;;) {
+		execute_req req;
+		ssize_t n = recv(sock, &req, sizeof(req), 0);
+		if (n == 0)
+			doexit(0);
+		if (n != (ssize_t)sizeof(req))
+			fail("template recv failed");
+		last_execute_req = req;
+		parse_execute_req(req);
+		int pid = syscall(__NR_clone, CLONE_PARENT | SIGCHLD, 0, 0, 0, 0);
+		if (pid < 0)
+			fail("clone failed");
+		if (pid == 0) {
+			close(sock);
+			prctl(PR_SET_PDEATHSIG, SIGKILL, 0, 0, 0);
+			setpgrp();
+			flush_tun();
+			execute_one();
+			close_fds();
+			doexit(0);
+		}
+		if (send(sock, &pid, sizeof(pid), MSG_NOSIGNAL) != sizeof(pid))
+			doexit(0);
+	}
+}
+
+// loop_template is the fork server loop used with flag_fork_template.
+// Instead of forking the fork server and setting up the test environment for each program,
+// it forks a template process that does the setup once and then spawns lightweight
+// test processes for up to kTemplatePrograms programs.
+static void loop_template()
+{
+	bool pending = false;
+	int failures = 0;
+	for (int iter = 0;; iter++) {
+		char cwdbuf[32];
+		sprintf(cwdbuf, "./%d", iter);
+		if (mkdir(cwdbuf, 0777))
+			fail("failed to mkdir");
+		int sv[2];
+		if (socketpair(AF_UNIX, SOCK_SEQPACKET, 0, sv))
+			fail("socketpair failed");
+		int template_pid = fork();
+		if (template_pid < 0)
+			fail("clone failed");
+		if (template_pid == 0) {
+			close(sv[0]);
+			template_serve(sv[1], cwdbuf);
+		}
+		close(sv[1]);
+		debug("spawned template pid %d\n", template_pid);
+		int executed = 0;
+		while (executed < kTemplatePrograms) {
+			// If the template died before spawning the previous program,
+			// the program is resent to the new template.
+			if (!pending)
+				receive_execute();
+			pending = true;
+			reset_loop();
+			int pid = 0;
+			if (send(sv[0], &last_execute_req, sizeof(last_execute_req), MSG_NOSIGNAL) !=
+				(ssize_t)sizeof(last_execute_req) ||
+			    recv(sv[0], &pid, sizeof(pid), 0) != sizeof(pid) || pid <= 0)
+				break;
+			pending = false;
+			executed++;
+			debug("spawned worker pid %d\n", pid);
+			int status = wait_for_test(pid);
+			if (WEXITSTATUS(status) == kFailStatus) {
+				errno = 0;
+				fail("child failed");
+			}
+			reply_program_done();
+			// The program was killed or crashed, it may have left the shared state broken.
+			if (WIFSIGNALED(status))
+				break;
+		}
+		close(sv[0]);
+		// The template may have already been reaped by wait_for_test.
+		int status = 0;
+		kill(template_pid, SIGKILL);
+		waitpid(template_pid, &status, __WALL);
+		remove_dir(cwdbuf);
+		failures = executed ? 0 : failures + 1;
+		if (failures >= kTemplateFailures)
+			fail("fork template process failed repeatedly");
+	}
+}
+#endif
+
 #if SYZ_EXECUTOR_USES_SHMEM
 void realloc_output_data()
 {
 #if SYZ_EXECUTOR_USES_FORK_SERVER
-	if (flag_comparisons)
+	if (output_limit != 0)
+		mmap_output(output_offset + output_limit);
+	else if (flag_comparisons)
 		mmap_output(kMaxOutputComparisons);
 	else if (flag_collect_cover)
 		mmap_output(kMaxOutputCoverage);
@@ -741,6 +947,11 @@ void realloc_output_data()
 		mmap_output(kMaxOutputSignal);
 	if (close(kOutFd) < 0)
 		fail("failed to close kOutFd");
+	if (output_limit != 0) {
+		// Use only the part of the output region reserved for this program of the batch.
+		output_data = (uint32*)((char*)output_data + output_offset);
+		output_size = output_limit;
+	}
 #endif
 }
 #endif // if SYZ_EXECUTOR_USES_SHMEM
@@ -754,7 +965,7 @@ void execute_one()
 	write_output(0); // Number of executed syscalls (updated later).
 #endif // if SYZ_EXECUTOR_USES_SHMEM
 	uint64 start = current_time_ms();
-	uint64* input_pos = (uint64*)input_data;
+	uint64* input_pos = (uint64*)(input_data + input_offset);
 
 	if (cover_collection_required()) {
 		if (!flag_threaded)
@@ -1111,10 +1322,15 @@ void copyout_call_results(thread_t* th)
 void write_call_output(thread_t* th, bool finished)
 {
 	uint32 reserrno = 999;
+	uint64 result = -1;
 	const bool blocked = finished && th != last_scheduled;
 	uint32 call_flags = call_flag_executed | (blocked ? call_flag_blocked : 0);
+	// For unfinished calls we report how long they have been running so far.
+	uint64 duration_us = current_time_us() - th->start_us;
 	if (finished) {
 		reserrno = th->res != -1 ? 0 : th->reserrno;
+		result = th->res;
+		duration_us = th->duration_us;
 		call_flags |= call_flag_finished |
 			      (th->fault_injected ? call_flag_fault_injected : 0);
 	}
@@ -1123,7 +1339,10 @@ void write_call_output(thread_t* th, bool finished)
 	write_output(th->call_index);
 	write_output(th->call_num);
 	write_output(reserrno);
+	write_output((uint32)result);
+	write_output((uint32)(result >> 32));
 	write_output(call_flags);
+	write_output(std::min<uint64>(duration_us, UINT32_MAX));
 	uint32* signal_count_pos = write_output(0); // filled in later
 	uint32* cover_count_pos = write_output(0); // filled in later
 	uint32* comps_count_pos = write_output(0); // filled in later
@@ -1168,7 +1387,10 @@ void write_call_output(thread_t* th, bool finished)
 	reply.call_index = th->call_index;
 	reply.call_num = th->call_num;
 	reply.reserrno = reserrno;
+	reply.result_lo = (uint32)result;
+	reply.result_hi = (uint32)(result >> 32);
 	reply.flags = call_flags;
+	reply.duration_us = std::min<uint64>(duration_us, UINT32_MAX);
 	reply.signal_size = 0;
 	reply.cover_size = 0;
 	reply.comps_size = 0;
@@ -1191,7 +1413,10 @@ void write_extra_output()
 	write_output(-1); // call index
 	write_output(-1); // call num
 	write_output(999); // errno
+	write_output(-1); // result low
+	write_output(-1); // result high
 	write_output(0); // call flags
+	write_output(0); // duration
 	uint32* signal_count_pos = write_output(0); // filled in later
 	uint32* cover_count_pos = write_output(0); // filled in later
 	write_output(0); // comps_count_pos
@@ -1248,6 +1473,18 @@ void* worker_thread(void* arg)
 	return 0;
 }
 
+static uint64 current_time_us()
+{
+#if GOOS_windows
+	return current_time_ms() * 1000;
+#else
+	struct timespec ts;
+	if (clock_gettime(CLOCK_MONOTONIC, &ts))
+		fail("clock_gettime failed");
+	return (uint64)ts.tv_sec * 1000000 + (uint64)ts.tv_nsec / 1000;
+#endif
+}
+
 void execute_call(thread_t* th)
 {
 	const call_t* call = &syscalls[th->call_num];
@@ -1275,8 +1512,10 @@ void execute_call(thread_t* th)
 	// Arrange for res = -1 and errno = EFAULT result for such case.
 	th->res = -1;
 	errno = EFAULT;
+	th->start_us = current_time_us();
 	NONFAILING(th->res = execute_syscall(call, th->args));
 	th->reserrno = errno;
+	th->duration_us = current_time_us() - th->start_us;
 	// Our pseudo-syscalls may misbehave.
 	if ((th->res == -1 && th->reserrno == 0) || call->attrs.ignore_return)
 		th->reserrno = EINVAL;
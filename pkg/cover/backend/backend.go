@@ -37,6 +37,9 @@ type Symbol struct {
 	Start      uint64
 	End        uint64
 	Symbolized bool
+	// Functions called directly from this function.
+	// Currently only filled for the main kernel image on amd64/arm64.
+	Callees []*Symbol
 }
 
 // ObjectUnit represents either CompileUnit or Symbol.
@@ -106,6 +106,7 @@ func makeDWARFUnsafe(params *dwarfParams) (*Impl, error) {
 	var allSymbols []*Symbol
 	var allRanges []pcRange
 	var allUnits []*CompileUnit
+	var allCalls []callEdge
 	var pcBase uint64
 	for _, module := range modules {
 		errc := make(chan error, 1)
@@ -135,6 +136,7 @@ func makeDWARFUnsafe(params *dwarfParams) (*Impl, error) {
 					return
 				}
 				coverPoints, err = readCoverPoints(target, info, data)
+				allCalls = readCallEdges(target, info, data, symbols)
 			} else {
 				coverPoints, err = params.readModuleCoverPoints(target, module, info)
 			}
@@ -168,6 +170,9 @@ func makeDWARFUnsafe(params *dwarfParams) (*Impl, error) {
 		})
 	}
 
+	// Note: this must be done before buildSymbols because it drops symbols without coverage points,
+	// but calls to such functions still connect other functions.
+	buildCallGraph(allSymbols, allCalls)
 	allSymbols = buildSymbols(allSymbols, allRanges, allCoverPoints)
 	nunit := 0
 	for _, unit := range allUnits {
@@ -455,6 +460,65 @@ func readCoverPoints(target *targets.Target, info *symbolInfo, data []byte) ([2]
 	return pcs, nil
 }
 
+type callEdge struct {
+	pc     uint64 // address of the call instruction
+	target uint64 // address of the called function
+}
+
+// readCallEdges finds all direct calls between functions in the object file.
+// Like readCoverPoints it looks for the call opcodes, only calls that target a start
+// of a known function are considered, which filters out nearly all false positives.
+// Indirect calls are not visible this way.
+func readCallEdges(target *targets.Target, info *symbolInfo, data []byte, symbols []*Symbol) []callEdge {
+	starts := make(map[uint64]bool, len(symbols))
+	for _, s := range symbols {
+		starts[s.Start] = true
+	}
+	var calls []callEdge
+	arch := arches[target.Arch]
+	for i, opcode := range data {
+		if opcode != arch.opcodes[0] && opcode != arch.opcodes[1] {
+			continue
+		}
+		i -= arch.opcodeOffset
+		if i < 0 || i+arch.callLen > len(data) {
+			continue
+		}
+		pc := info.textAddr + uint64(i)
+		callee := arch.target(&arch, data[i:], pc, opcode)
+		if !starts[callee] || callee == info.tracePC || info.traceCmp[callee] {
+			continue
+		}
+		calls = append(calls, callEdge{pc, callee})
+	}
+	return calls
+}
+
+// buildCallGraph fills in Symbol.Callees. symbols must be sorted by start address.
+func buildCallGraph(symbols []*Symbol, calls []callEdge) {
+	byStart := make(map[uint64]*Symbol, len(symbols))
+	for _, s := range symbols {
+		if byStart[s.Start] == nil {
+			byStart[s.Start] = s
+		}
+	}
+	seen := make(map[[2]*Symbol]bool)
+	for _, call := range calls {
+		idx := sort.Search(len(symbols), func(i int) bool {
+			return symbols[i].Start > call.pc
+		}) - 1
+		if idx < 0 || call.pc >= symbols[idx].End {
+			continue
+		}
+		caller, callee := symbols[idx], byStart[call.target]
+		if callee == nil || seen[[2]*Symbol{caller, callee}] {
+			continue
+		}
+		seen[[2]*Symbol{caller, callee}] = true
+		caller.Callees = append(caller.Callees, callee)
+	}
+}
+
 func cleanPath(path, objDir, srcDir, buildDir string) (string, string) {
 	filename := ""
 	switch {
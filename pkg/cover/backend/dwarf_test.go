@@ -0,0 +1,57 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package backend
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func TestCallGraph(t *testing.T) {
+	const textAddr = 0x1000
+	data := make([]byte, 0x50)
+	for i := range data {
+		data[i] = 0x90 // nop
+	}
+	call := func(pc, target uint64) {
+		off := pc - textAddr
+		data[off] = 0xe8
+		binary.LittleEndian.PutUint32(data[off+1:], uint32(target-pc-5))
+	}
+	foo := &Symbol{ObjectUnit: ObjectUnit{Name: "foo"}, Start: 0x1000, End: 0x1020}
+	bar := &Symbol{ObjectUnit: ObjectUnit{Name: "bar"}, Start: 0x1020, End: 0x1030}
+	baz := &Symbol{ObjectUnit: ObjectUnit{Name: "baz"}, Start: 0x1030, End: 0x1040}
+	trace := &Symbol{ObjectUnit: ObjectUnit{Name: "__sanitizer_cov_trace_pc"}, Start: 0x1040, End: 0x1050}
+	symbols := []*Symbol{foo, bar, baz, trace}
+	call(0x1000, bar.Start)
+	call(0x1005, trace.Start)
+	call(0x100a, bar.Start)
+	call(0x100f, baz.Start)
+	call(0x1020, baz.Start)
+	// Not a call of a function start.
+	call(0x1030, baz.Start+1)
+	info := &symbolInfo{
+		textAddr: textAddr,
+		tracePC:  trace.Start,
+		traceCmp: make(map[uint64]bool),
+	}
+	calls := readCallEdges(targets.Get(targets.Linux, targets.AMD64), info, data, symbols)
+	buildCallGraph(symbols, calls)
+	want := map[*Symbol][]*Symbol{
+		foo: {bar, baz},
+		bar: {baz},
+	}
+	for _, s := range symbols {
+		if len(s.Callees) != len(want[s]) {
+			t.Fatalf("%v: got %v callees, want %v", s.Name, len(s.Callees), len(want[s]))
+		}
+		for i, callee := range s.Callees {
+			if callee != want[s][i] {
+				t.Errorf("%v: callee #%v is %v, want %v", s.Name, i, callee.Name, want[s][i].Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,379 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package cover
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/syzkaller/pkg/mgrconfig"
+)
+
+// Differential coverage reports show what one set of programs covers that another set does not and vice versa.
+// The two sets may come from different kernel builds, in such case PCs are not comparable,
+// so coverage is compared by file:line and by function name after symbolization.
+
+type fileDiff struct {
+	module      string
+	name        string
+	gainedLines []int
+	lostLines   []int
+	gainedFuncs []string
+	lostFuncs   []string
+}
+
+func (fd *fileDiff) empty() bool {
+	return len(fd.gainedLines)+len(fd.lostLines)+len(fd.gainedFuncs)+len(fd.lostFuncs) == 0
+}
+
+type subsystemDiff struct {
+	Name        string
+	BaseLines   int
+	Lines       int
+	GainedLines int
+	LostLines   int
+	BaseFuncs   int
+	Funcs       int
+	GainedFuncs int
+	LostFuncs   int
+}
+
+// DoDiffHTML generates HTML report with coverage gained and lost by progs compared to baseProgs.
+// base is the report generator for the kernel build baseProgs were collected on,
+// it may be rg itself if both sets come from the same build.
+func (rg *ReportGenerator) DoDiffHTML(w io.Writer, base *ReportGenerator, baseProgs, progs []Prog) error {
+	diffs, subsystems, err := rg.prepareDiff(base, baseProgs, progs)
+	if err != nil {
+		return err
+	}
+	data := &templateDiffData{
+		Subsystems: subsystems,
+	}
+	for _, fd := range diffs {
+		data.Files = append(data.Files, &templateDiffFile{
+			Name:        fd.name,
+			Module:      fd.module,
+			GainedLines: formatLines(fd.gainedLines),
+			LostLines:   formatLines(fd.lostLines),
+			GainedFuncs: strings.Join(fd.gainedFuncs, " "),
+			LostFuncs:   strings.Join(fd.lostFuncs, " "),
+		})
+	}
+	return coverDiffTemplate.Execute(w, data)
+}
+
+var csvDiffHeader = []string{
+	"Subsystem",
+	"Module",
+	"Filename",
+	"Function",
+	"Line",
+	"Change",
+}
+
+// DoDiffCSV is the same as DoDiffHTML, but produces one CSV row per gained/lost line or function.
+// Each file is attributed to the first configured subsystem that contains it.
+func (rg *ReportGenerator) DoDiffCSV(w io.Writer, base *ReportGenerator, baseProgs, progs []Prog) error {
+	diffs, _, err := rg.prepareDiff(base, baseProgs, progs)
+	if err != nil {
+		return err
+	}
+	var data [][]string
+	for _, fd := range diffs {
+		subsystem := fileSubsystem(fd.name, rg.subsystem)
+		for _, fn := range fd.gainedFuncs {
+			data = append(data, []string{subsystem, fd.module, fd.name, fn, "", "gained"})
+		}
+		for _, fn := range fd.lostFuncs {
+			data = append(data, []string{subsystem, fd.module, fd.name, fn, "", "lost"})
+		}
+		for _, ln := range fd.gainedLines {
+			data = append(data, []string{subsystem, fd.module, fd.name, "", strconv.Itoa(ln), "gained"})
+		}
+		for _, ln := range fd.lostLines {
+			data = append(data, []string{subsystem, fd.module, fd.name, "", strconv.Itoa(ln), "lost"})
+		}
+	}
+	writer := csv.NewWriter(w)
+	defer writer.Flush()
+	if err := writer.Write(csvDiffHeader); err != nil {
+		return err
+	}
+	return writer.WriteAll(data)
+}
+
+func (rg *ReportGenerator) prepareDiff(base *ReportGenerator, baseProgs, progs []Prog) (
+	[]*fileDiff, []*subsystemDiff, error) {
+	if base == nil {
+		base = rg
+	}
+	baseFiles, err := base.prepareFileMap(baseProgs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("base coverage: %w", err)
+	}
+	files, err := rg.prepareFileMap(progs)
+	if err != nil {
+		return nil, nil, err
+	}
+	diffs := diffFiles(baseFiles, files)
+	return diffs, diffSubsystems(baseFiles, files, diffs, rg.subsystem), nil
+}
+
+func diffFiles(baseFiles, files map[string]*file) []*fileDiff {
+	names := make(map[string]bool)
+	for name := range baseFiles {
+		names[name] = true
+	}
+	for name := range files {
+		names[name] = true
+	}
+	var diffs []*fileDiff
+	for name := range names {
+		baseFile, f := baseFiles[name], files[name]
+		fd := &fileDiff{name: name}
+		if f != nil {
+			fd.module = f.module
+		} else {
+			fd.module = baseFile.module
+		}
+		baseLines, lines := coveredLines(baseFile), coveredLines(f)
+		baseFuncs, funcs := coveredFuncs(baseFile), coveredFuncs(f)
+		fd.gainedLines = subtractLines(lines, baseLines)
+		fd.lostLines = subtractLines(baseLines, lines)
+		fd.gainedFuncs = subtractFuncs(funcs, baseFuncs)
+		fd.lostFuncs = subtractFuncs(baseFuncs, funcs)
+		if !fd.empty() {
+			diffs = append(diffs, fd)
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].name < diffs[j].name
+	})
+	return diffs
+}
+
+func diffSubsystems(baseFiles, files map[string]*file, diffs []*fileDiff,
+	subsystems []mgrconfig.Subsystem) []*subsystemDiff {
+	var res []*subsystemDiff
+	for _, subsystem := range subsystems {
+		sd := &subsystemDiff{Name: subsystem.Name}
+		for name, f := range baseFiles {
+			if FileInSubsystem(name, subsystem) {
+				sd.BaseLines += len(coveredLines(f))
+				sd.BaseFuncs += len(coveredFuncs(f))
+			}
+		}
+		for name, f := range files {
+			if FileInSubsystem(name, subsystem) {
+				sd.Lines += len(coveredLines(f))
+				sd.Funcs += len(coveredFuncs(f))
+			}
+		}
+		for _, fd := range diffs {
+			if FileInSubsystem(fd.name, subsystem) {
+				sd.GainedLines += len(fd.gainedLines)
+				sd.LostLines += len(fd.lostLines)
+				sd.GainedFuncs += len(fd.gainedFuncs)
+				sd.LostFuncs += len(fd.lostFuncs)
+			}
+		}
+		res = append(res, sd)
+	}
+	return res
+}
+
+// FileInSubsystem returns whether the source file belongs to the subsystem.
+func FileInSubsystem(name string, subsystem mgrconfig.Subsystem) bool {
+	for _, path := range subsystem.Paths {
+		if strings.HasPrefix(name, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func fileSubsystem(name string, subsystems []mgrconfig.Subsystem) string {
+	for _, subsystem := range subsystems {
+		if FileInSubsystem(name, subsystem) {
+			return subsystem.Name
+		}
+	}
+	return ""
+}
+
+func coveredLines(f *file) map[int]bool {
+	res := make(map[int]bool)
+	if f == nil {
+		return res
+	}
+	for ln, line := range f.lines {
+		if len(line.progCount) != 0 {
+			res[ln] = true
+		}
+	}
+	return res
+}
+
+func coveredFuncs(f *file) map[string]bool {
+	res := make(map[string]bool)
+	if f == nil {
+		return res
+	}
+	for _, fn := range f.functions {
+		if fn.covered != 0 {
+			res[fn.name] = true
+		}
+	}
+	return res
+}
+
+func subtractLines(a, b map[int]bool) []int {
+	var res []int
+	for ln := range a {
+		if !b[ln] {
+			res = append(res, ln)
+		}
+	}
+	sort.Ints(res)
+	return res
+}
+
+func subtractFuncs(a, b map[string]bool) []string {
+	var res []string
+	for fn := range a {
+		if !b[fn] {
+			res = append(res, fn)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// formatLines formats sorted line numbers compressing consecutive runs, e.g. "1-3 7 9-10".
+func formatLines(lines []int) string {
+	var res []string
+	for i := 0; i < len(lines); {
+		j := i
+		for j+1 < len(lines) && lines[j+1] == lines[j]+1 {
+			j++
+		}
+		if i == j {
+			res = append(res, strconv.Itoa(lines[i]))
+		} else {
+			res = append(res, fmt.Sprintf("%v-%v", lines[i], lines[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(res, " ")
+}
+
+type templateDiffData struct {
+	Subsystems []*subsystemDiff
+	Files      []*templateDiffFile
+}
+
+type templateDiffFile struct {
+	Name        string
+	Module      string
+	GainedLines string
+	LostLines   string
+	GainedFuncs string
+	LostFuncs   string
+}
+
+var coverDiffTemplate = template.Must(template.New("coverDiff").Parse(`
+<!DOCTYPE html>
+<html>
+	<head>
+		<meta http-equiv="Content-Type" content="text/html; charset=utf-8">
+		<style>
+			body {
+				background: white;
+				color: rgb(70, 70, 70);
+			}
+			th, td {
+				text-align: left;
+				vertical-align: top;
+				border: 1px solid black;
+			}
+			th {
+				background: gray;
+			}
+			tr:nth-child(2n+1) {
+				background: #CCC
+			}
+			table {
+				border-collapse: collapse;
+				border: 1px solid black;
+				margin-bottom: 20px;
+			}
+			.gained {
+				color: rgb(0, 120, 0);
+			}
+			.lost {
+				color: rgb(200, 0, 0);
+			}
+		</style>
+	</head>
+	<body>
+		<table>
+			<caption>Subsystems</caption>
+			<thead>
+				<tr>
+					<th>Name</th>
+					<th>Base / New Lines</th>
+					<th>Gained Lines</th>
+					<th>Lost Lines</th>
+					<th>Base / New Functions</th>
+					<th>Gained Functions</th>
+					<th>Lost Functions</th>
+				</tr>
+			</thead>
+			<tbody>
+				{{range $s := .Subsystems}}
+				<tr>
+					<td>{{$s.Name}}</td>
+					<td>{{$s.BaseLines}} / {{$s.Lines}}</td>
+					<td class="gained">+{{$s.GainedLines}}</td>
+					<td class="lost">-{{$s.LostLines}}</td>
+					<td>{{$s.BaseFuncs}} / {{$s.Funcs}}</td>
+					<td class="gained">+{{$s.GainedFuncs}}</td>
+					<td class="lost">-{{$s.LostFuncs}}</td>
+				</tr>
+				{{end}}
+			</tbody>
+		</table>
+		<table>
+			<caption>Files</caption>
+			<thead>
+				<tr>
+					<th>Module</th>
+					<th>File</th>
+					<th>Gained Functions</th>
+					<th>Lost Functions</th>
+					<th>Gained Lines</th>
+					<th>Lost Lines</th>
+				</tr>
+			</thead>
+			<tbody>
+				{{range $f := .Files}}
+				<tr>
+					<td>{{$f.Module}}</td>
+					<td>{{$f.Name}}</td>
+					<td class="gained">{{$f.GainedFuncs}}</td>
+					<td class="lost">{{$f.LostFuncs}}</td>
+					<td class="gained">{{$f.GainedLines}}</td>
+					<td class="lost">{{$f.LostLines}}</td>
+				</tr>
+				{{end}}
+			</tbody>
+		</table>
+	</body>
+</html>
+`))
@@ -0,0 +1,121 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package cover
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/syzkaller/pkg/mgrconfig"
+)
+
+func TestDiffFiles(t *testing.T) {
+	mkFile := func(lines []int, funcs map[string]int) *file {
+		f := &file{
+			module: "vmlinux",
+			lines:  make(map[int]line),
+		}
+		for _, ln := range lines {
+			f.lines[ln] = line{progCount: map[int]bool{0: true}}
+		}
+		// Uncovered lines must not be treated as covered.
+		f.lines[1000] = line{}
+		for name, covered := range funcs {
+			f.functions = append(f.functions, &function{name: name, pcs: 10, covered: covered})
+		}
+		return f
+	}
+	baseFiles := map[string]*file{
+		"fs/open.c":     mkFile([]int{1, 2, 3, 10}, map[string]int{"do_open": 3, "do_close": 1}),
+		"net/socket.c":  mkFile([]int{5}, map[string]int{"sock_create": 1}),
+		"kernel/fork.c": mkFile([]int{7}, map[string]int{"copy_process": 1}),
+	}
+	files := map[string]*file{
+		"fs/open.c":     mkFile([]int{2, 3, 4, 5, 6, 20}, map[string]int{"do_open": 5, "do_close": 0}),
+		"kernel/fork.c": mkFile([]int{7}, map[string]int{"copy_process": 1}),
+		"mm/mmap.c":     mkFile([]int{30, 31}, map[string]int{"do_mmap": 2}),
+	}
+	diffs := diffFiles(baseFiles, files)
+	want := []*fileDiff{
+		{
+			module:      "vmlinux",
+			name:        "fs/open.c",
+			gainedLines: []int{4, 5, 6, 20},
+			lostLines:   []int{1, 10},
+			lostFuncs:   []string{"do_close"},
+		},
+		{
+			module:      "vmlinux",
+			name:        "mm/mmap.c",
+			gainedLines: []int{30, 31},
+			gainedFuncs: []string{"do_mmap"},
+		},
+		{
+			module:    "vmlinux",
+			name:      "net/socket.c",
+			lostLines: []int{5},
+			lostFuncs: []string{"sock_create"},
+		},
+	}
+	if !reflect.DeepEqual(diffs, want) {
+		for _, fd := range diffs {
+			t.Logf("%+v", *fd)
+		}
+		t.Fatalf("wrong diff")
+	}
+	subsystems := diffSubsystems(baseFiles, files, diffs, []mgrconfig.Subsystem{
+		{Name: "fs", Paths: []string{"fs/"}},
+		{Name: "all", Paths: []string{""}},
+	})
+	wantSubsystems := []*subsystemDiff{
+		{
+			Name:        "fs",
+			BaseLines:   4,
+			Lines:       6,
+			GainedLines: 4,
+			LostLines:   2,
+			BaseFuncs:   2,
+			Funcs:       1,
+			LostFuncs:   1,
+		},
+		{
+			Name:        "all",
+			BaseLines:   6,
+			Lines:       9,
+			GainedLines: 6,
+			LostLines:   3,
+			BaseFuncs:   4,
+			Funcs:       3,
+			GainedFuncs: 1,
+			LostFuncs:   2,
+		},
+	}
+	if !reflect.DeepEqual(subsystems, wantSubsystems) {
+		for _, sd := range subsystems {
+			t.Logf("%+v", *sd)
+		}
+		t.Fatalf("wrong subsystem diff")
+	}
+	if got := fileSubsystem("net/socket.c", []mgrconfig.Subsystem{
+		{Name: "fs", Paths: []string{"fs/"}},
+		{Name: "net", Paths: []string{"net/", "drivers/net/"}},
+		{Name: "all", Paths: []string{""}},
+	}); got != "net" {
+		t.Fatalf("got subsystem %q, want net", got)
+	}
+}
+
+func TestFormatLines(t *testing.T) {
+	tests := map[string][]int{
+		"":           nil,
+		"5":          {5},
+		"1-3 7 9-10": {1, 2, 3, 7, 9, 10},
+		"1 3 5":      {1, 3, 5},
+	}
+	for want, lines := range tests {
+		if got := formatLines(lines); got != want {
+			t.Errorf("formatLines(%v) = %q, want %q", lines, got, want)
+		}
+	}
+}
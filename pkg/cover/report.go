@@ -224,3 +224,15 @@ func (rg *ReportGenerator) findSymbol(pc uint64) *backend.Symbol {
 	}
 	return s
 }
+
+// CoveredSymbols returns names of functions and files (compilation units) that contain any of the PCs.
+func (rg *ReportGenerator) CoveredSymbols(pcs []uint64) (funcs, files map[string]bool) {
+	funcs, files = make(map[string]bool), make(map[string]bool)
+	for _, pc := range pcs {
+		if s := rg.findSymbol(pc); s != nil {
+			funcs[s.Name] = true
+			files[s.Unit.Name] = true
+		}
+	}
+	return
+}
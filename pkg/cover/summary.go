@@ -0,0 +1,69 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package cover
+
+import (
+	"github.com/google/syzkaller/pkg/mgrconfig"
+)
+
+// PCCount is the number of coverage PCs and how many of them are covered.
+type PCCount struct {
+	Covered int
+	Total   int
+}
+
+// Summary contains coverage of individual source files and subsystems.
+type Summary struct {
+	// Files with at least one covered PC.
+	Files map[string]PCCount
+	// All configured subsystems and "all" for the whole kernel (see Subsystems).
+	Subsystems map[string]PCCount
+}
+
+// Summary counts PCs covered by progs per source file and per subsystem.
+// Unlike reports, it does not need symbolization, so it's cheap enough to be computed periodically.
+func (rg *ReportGenerator) Summary(progs []Prog) *Summary {
+	covered := make(map[uint64]bool)
+	for _, prog := range progs {
+		for _, pc := range prog.PCs {
+			covered[pc] = true
+		}
+	}
+	files := make(map[string]PCCount)
+	res := &Summary{
+		Files:      make(map[string]PCCount),
+		Subsystems: make(map[string]PCCount),
+	}
+	for _, unit := range rg.Units {
+		cnt := files[unit.Name]
+		cnt.Total += len(unit.PCs)
+		for _, pc := range unit.PCs {
+			if covered[pc] {
+				cnt.Covered++
+			}
+		}
+		files[unit.Name] = cnt
+	}
+	for name, cnt := range files {
+		if cnt.Covered != 0 {
+			res.Files[name] = cnt
+		}
+	}
+	for _, subsystem := range rg.subsystem {
+		var sum PCCount
+		for name, cnt := range files {
+			if FileInSubsystem(name, subsystem) {
+				sum.Covered += cnt.Covered
+				sum.Total += cnt.Total
+			}
+		}
+		res.Subsystems[subsystem.Name] = sum
+	}
+	return res
+}
+
+// Subsystems returns the configured subsystems and the "all" subsystem that contains all files.
+func (rg *ReportGenerator) Subsystems() []mgrconfig.Subsystem {
+	return rg.subsystem
+}
@@ -0,0 +1,55 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package cover
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/syzkaller/pkg/cover/backend"
+	"github.com/google/syzkaller/pkg/mgrconfig"
+)
+
+func TestSummary(t *testing.T) {
+	unit := func(name string, pcs ...uint64) *backend.CompileUnit {
+		return &backend.CompileUnit{ObjectUnit: backend.ObjectUnit{Name: name, PCs: pcs}}
+	}
+	rg := &ReportGenerator{
+		subsystem: []mgrconfig.Subsystem{
+			{Name: "fs", Paths: []string{"fs/"}},
+			{Name: "net", Paths: []string{"net/", "drivers/net/"}},
+			{Name: "all", Paths: []string{""}},
+		},
+		Impl: &backend.Impl{
+			Units: []*backend.CompileUnit{
+				unit("fs/open.c", 1, 2, 3, 4),
+				unit("fs/read_write.c", 5, 6),
+				unit("net/socket.c", 7, 8, 9),
+				unit("drivers/net/tun.c", 10),
+				unit("mm/mmap.c", 11, 12),
+			},
+		},
+	}
+	progs := []Prog{
+		{PCs: []uint64{1, 2, 7}},
+		{PCs: []uint64{2, 3, 10, 11}},
+	}
+	got := rg.Summary(progs)
+	want := &Summary{
+		Files: map[string]PCCount{
+			"fs/open.c":         {Covered: 3, Total: 4},
+			"net/socket.c":      {Covered: 1, Total: 3},
+			"drivers/net/tun.c": {Covered: 1, Total: 1},
+			"mm/mmap.c":         {Covered: 1, Total: 2},
+		},
+		Subsystems: map[string]PCCount{
+			"fs":  {Covered: 3, Total: 6},
+			"net": {Covered: 2, Total: 4},
+			"all": {Covered: 6, Total: 12},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+}
@@ -25,6 +25,8 @@ type OptionalConfig struct {
 	OldFlagsCompatMode bool
 	BeforeContextLen   int
 	StraceBin          string
+	// If set, closing the channel aborts the currently running program (as if it timed out).
+	Stop <-chan bool
 }
 
 type ExecProgInstance struct {
@@ -107,7 +109,7 @@ func (inst *ExecProgInstance) runCommand(command string, duration time.Duration)
 		command = inst.StraceBin + filterCalls + ` -s 100 -x -f ` + command
 		prefixOutput = []byte(fmt.Sprintf("%s\n\n<...>\n", command))
 	}
-	outc, errc, err := inst.VMInstance.Run(duration, nil, command)
+	outc, errc, err := inst.VMInstance.Run(duration, inst.Stop, command)
 	if err != nil {
 		return nil, fmt.Errorf("failed to run command in VM: %v", err)
 	}
@@ -445,9 +445,20 @@ func (inst *inst) testRepro() ([]byte, error) {
 }
 
 type OptionalFuzzerArgs struct {
-	Slowdown   int
-	RawCover   bool
-	SandboxArg int
+	Slowdown         int
+	RawCover         bool
+	SandboxArg       int
+	ResetAfter       int
+	FaultCampaign    bool
+	ForkTemplate     bool
+	ForkBench        bool
+	DeprioritizeSlow bool
+	CorpusSchedule   string
+	// Paths to the fuzzer certificate, key and the CA certificate inside of the VM
+	// (empty if the manager does not use TLS).
+	TLSCert string
+	TLSKey  string
+	TLSCA   string
 }
 
 type FuzzerCmdArgs struct {
@@ -489,6 +500,18 @@ func FuzzerCmd(args *FuzzerCmdArgs) string {
 			{Name: "slowdown", Value: fmt.Sprint(args.Optional.Slowdown)},
 			{Name: "raw_cover", Value: fmt.Sprint(args.Optional.RawCover)},
 			{Name: "sandbox_arg", Value: fmt.Sprint(args.Optional.SandboxArg)},
+			{Name: "reset_after", Value: fmt.Sprint(args.Optional.ResetAfter)},
+			{Name: "fault_campaign", Value: fmt.Sprint(args.Optional.FaultCampaign)},
+			{Name: "fork_template", Value: fmt.Sprint(args.Optional.ForkTemplate)},
+			{Name: "fork_bench", Value: fmt.Sprint(args.Optional.ForkBench)},
+			{Name: "deprioritize_slow", Value: fmt.Sprint(args.Optional.DeprioritizeSlow)},
+			{Name: "tls_cert", Value: args.Optional.TLSCert},
+			{Name: "tls_key", Value: args.Optional.TLSKey},
+			{Name: "tls_ca", Value: args.Optional.TLSCA},
+		}
+		if args.Optional.CorpusSchedule != "" {
+			// Empty value is not a valid schedule, the fuzzer uses its default in such case.
+			flags = append(flags, tool.Flag{Name: "schedule", Value: args.Optional.CorpusSchedule})
 		}
 		optionalArg = " " + tool.OptionalFlags(flags)
 	}
@@ -79,6 +79,53 @@ func TestFuzzerCmd(t *testing.T) {
 	}
 }
 
+func TestFuzzerCmdOptional(t *testing.T) {
+	for _, test := range []struct {
+		schedule string
+		want     string
+	}{
+		// Callers that don't set the schedule (e.g. OldFuzzerCmd, syz-runtest) must not override the default.
+		{"", "signal"},
+		{"power", "power"},
+	} {
+		cmdLine := FuzzerCmd(&FuzzerCmdArgs{
+			Fuzzer:   os.Args[0],
+			Executor: "/myexecutor",
+			Name:     "myname",
+			OS:       targets.Linux,
+			Arch:     targets.AMD64,
+			FwdAddr:  "localhost:1234",
+			Sandbox:  "none",
+			Procs:    1,
+			Optional: &OptionalFuzzerArgs{
+				Slowdown:       3,
+				SandboxArg:     7,
+				CorpusSchedule: test.schedule,
+			},
+		})
+		// Parse only the optional flags the way syz-fuzzer does.
+		var args []string
+		for _, arg := range strings.Split(cmdLine, " ") {
+			if strings.HasPrefix(arg, "-optional=") {
+				args = append(args, arg)
+			}
+		}
+		flags := flag.NewFlagSet("", flag.ContinueOnError)
+		flagSlowdown := flags.Int("slowdown", 1, "")
+		flagSandboxArg := flags.Int("sandbox_arg", 0, "")
+		flagSchedule := flags.String("schedule", "signal", "")
+		if err := tool.ParseFlags(flags, args); err != nil {
+			t.Fatal(err)
+		}
+		if *flagSchedule != test.want {
+			t.Errorf("schedule %q: got %q, want %q", test.schedule, *flagSchedule, test.want)
+		}
+		if *flagSlowdown != 3 || *flagSandboxArg != 7 {
+			t.Errorf("bad slowdown/sandbox_arg: %v/%v", *flagSlowdown, *flagSandboxArg)
+		}
+	}
+}
+
 func TestExecprogCmd(t *testing.T) {
 	// IMPORTANT: if this test fails, do not fix it by changing flags here!
 	// See comment in TestFuzzerCmd.
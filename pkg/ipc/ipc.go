@@ -43,6 +43,7 @@ const (
 	FlagEnableWifi                               // setup and use mac80211_hwsim for wifi emulation
 	FlagDelayKcovMmap                            // manage kcov memory in an optimized way
 	FlagEnableNicVF                              // setup NIC VF device
+	FlagForkTemplate                             // fork test processes from a pre-initialized template (linux only)
 )
 
 // Per-exec flags for ExecOpts.Flags.
@@ -90,8 +91,12 @@ type CallInfo struct {
 	Signal []uint32 // feedback signal, filled if FlagSignal is set
 	Cover  []uint32 // per-call coverage, filled if FlagSignal is set and cover == true,
 	// if dedup == false, then cov effectively contains a trace, otherwise duplicates are removed
-	Comps prog.CompMap // per-call comparison operands
-	Errno int          // call errno (0 if the call was successful)
+	Comps  prog.CompMap // per-call comparison operands
+	Errno  int          // call errno (0 if the call was successful)
+	Result int64        // call return value (-1 if the call failed or did not finish)
+	// Call execution time. For unfinished calls it's the time from the call start
+	// till the end of the program.
+	Duration time.Duration
 }
 
 type ProgInfo struct {
@@ -117,6 +122,8 @@ type Env struct {
 
 const (
 	outputSize = 16 << 20
+	// Max number of programs in a single executor request (see ExecBatch).
+	maxBatch = 64
 
 	statusFail = 67
 
@@ -263,15 +270,139 @@ func (env *Env) Exec(opts *ExecOpts, p *prog.Prog) (output []byte, info *ProgInf
 	if !env.config.UseShmem {
 		progData = env.in[:progSize]
 	}
+	output, hanged, err0 = env.exec([]*execItem{{opts: opts, p: p}}, progData)
+	if err0 != nil {
+		return
+	}
+	info, err0 = env.parseOutput(p, opts, env.out)
+	if info != nil && env.config.Flags&FlagSignal == 0 {
+		addFallbackSignal(p, info)
+	}
+	return
+}
+
+// ExecBatch is like Exec, but executes several programs with individual options.
+// If the executor uses shared memory and fork server, programs are sent to the executor
+// in batches (as many as fit into the input/output regions) to save on round-trips,
+// otherwise they are executed one-by-one. Programs are still executed in separate processes.
+// infos contains results for programs executed before an error, hanged is set if any batch hanged.
+func (env *Env) ExecBatch(opts []*ExecOpts, progs []*prog.Prog) (
+	output []byte, infos []*ProgInfo, hanged bool, err0 error) {
+	if len(opts) != len(progs) {
+		err0 = fmt.Errorf("got %v exec opts for %v programs", len(opts), len(progs))
+		return
+	}
+	for len(infos) < len(progs) {
+		if !env.config.UseShmem || !env.config.UseForkServer {
+			i := len(infos)
+			out, info, hang, err := env.Exec(opts[i], progs[i])
+			output = append(output, out...)
+			hanged = hanged || hang
+			if err != nil {
+				err0 = err
+				return
+			}
+			infos = append(infos, info)
+			continue
+		}
+		items, err := env.packBatch(opts[len(infos):], progs[len(infos):])
+		if err != nil {
+			err0 = err
+			return
+		}
+		out, hang, err := env.exec(items, nil)
+		output = append(output, out...)
+		hanged = hanged || hang
+		if err != nil {
+			err0 = err
+			return
+		}
+		for _, item := range items {
+			itemOut := env.out[item.outputOffset : item.outputOffset+item.outputSize]
+			info, err := env.parseOutput(item.p, item.opts, itemOut)
+			if err != nil {
+				err0 = err
+				return
+			}
+			if env.config.Flags&FlagSignal == 0 {
+				addFallbackSignal(item.p, info)
+			}
+			infos = append(infos, info)
+		}
+	}
+	return
+}
+
+// execItem is a program sent to the executor in a single request with its location
+// in the input and output regions. Programs of a batch get separate parts of the output
+// region, a non-batched program uses the whole region (outputSize is 0).
+type execItem struct {
+	opts         *ExecOpts
+	p            *prog.Prog
+	progOffset   int
+	outputOffset int
+	outputSize   int
+}
+
+// packBatch serializes the longest prefix of progs that fits into the input and output regions.
+func (env *Env) packBatch(opts []*ExecOpts, progs []*prog.Prog) ([]*execItem, error) {
+	var items []*execItem
+	progOffset, outputOffset := 0, 0
+	for i, p := range progs {
+		size := batchOutputSize(opts[i])
+		if len(items) == maxBatch || outputOffset+size > outputSize {
+			break
+		}
+		progSize, err := p.SerializeForExec(env.in[progOffset:])
+		if err != nil {
+			if len(items) != 0 {
+				// Try to execute it in the next batch with the whole input region.
+				break
+			}
+			return nil, err
+		}
+		items = append(items, &execItem{
+			opts:         opts[i],
+			p:            p,
+			progOffset:   progOffset,
+			outputOffset: outputOffset,
+			outputSize:   size,
+		})
+		progOffset += (progSize + 7) &^ 7
+		outputOffset += size
+	}
+	return items, nil
+}
+
+// batchOutputSize returns size of the output region part reserved for a batched program,
+// these are the max output sizes the executor expects depending on the collected data
+// (kMaxOutputComparisons/kMaxOutputCoverage/kMaxOutputSignal/kMinOutput in executor.cc).
+func batchOutputSize(opts *ExecOpts) int {
+	switch {
+	case opts.Flags&FlagCollectComps != 0:
+		return 14 << 20
+	case opts.Flags&FlagCollectCover != 0:
+		return 6 << 20
+	case opts.Flags&FlagCollectSignal != 0:
+		return 4 << 20
+	default:
+		return 256 << 10
+	}
+}
+
+func (env *Env) exec(items []*execItem, progData []byte) (output []byte, hanged bool, err0 error) {
 	// Zero out the first two words (ncmd and nsig), so that we don't have garbage there
 	// if executor crashes before writing non-garbage there.
-	for i := 0; i < 4; i++ {
-		env.out[i] = 0
+	for _, item := range items {
+		for i := 0; i < 4; i++ {
+			env.out[item.outputOffset+i] = 0
+		}
 	}
 
-	atomic.AddUint64(&env.StatExecs, 1)
+	atomic.AddUint64(&env.StatExecs, uint64(len(items)))
 	if env.cmd == nil {
-		if p.Target.OS != targets.TestOS && targets.Get(p.Target.OS, p.Target.Arch).HostFuzzer {
+		target := items[0].p.Target
+		if target.OS != targets.TestOS && targets.Get(target.OS, target.Arch).HostFuzzer {
 			// The executor is actually ssh,
 			// starting them too frequently leads to timeouts.
 			<-rateLimit.C
@@ -283,18 +414,8 @@ func (env *Env) Exec(opts *ExecOpts, p *prog.Prog) (output []byte, info *ProgInf
 			return
 		}
 	}
-	output, hanged, err0 = env.cmd.exec(opts, progData)
-	if err0 != nil {
-		env.cmd.close()
-		env.cmd = nil
-		return
-	}
-
-	info, err0 = env.parseOutput(p, opts)
-	if info != nil && env.config.Flags&FlagSignal == 0 {
-		addFallbackSignal(p, info)
-	}
-	if !env.config.UseForkServer {
+	output, hanged, err0 = env.cmd.exec(items, progData)
+	if err0 != nil || !env.config.UseForkServer {
 		env.cmd.close()
 		env.cmd = nil
 	}
@@ -324,8 +445,7 @@ func addFallbackSignal(p *prog.Prog, info *ProgInfo) {
 	}
 }
 
-func (env *Env) parseOutput(p *prog.Prog, opts *ExecOpts) (*ProgInfo, error) {
-	out := env.out
+func (env *Env) parseOutput(p *prog.Prog, opts *ExecOpts, out []byte) (*ProgInfo, error) {
 	ncmd, ok := readUint32(&out)
 	if !ok {
 		return nil, fmt.Errorf("failed to read number of calls")
@@ -354,7 +474,9 @@ func (env *Env) parseOutput(p *prog.Prog, opts *ExecOpts) (*ProgInfo, error) {
 				return nil, fmt.Errorf("duplicate reply for call %v/%v/%v", i, reply.index, reply.num)
 			}
 			inf.Errno = int(reply.errno)
+			inf.Result = int64(uint64(reply.resultHi)<<32 | uint64(reply.resultLo))
 			inf.Flags = CallFlags(reply.flags)
+			inf.Duration = time.Duration(reply.durationUs) * time.Microsecond
 		} else {
 			extraParts = append(extraParts, CallInfo{})
 			inf = &extraParts[len(extraParts)-1]
@@ -526,6 +648,11 @@ type executeReq struct {
 	progSize         uint64
 	// This structure is followed by a serialized test program in encodingexec format.
 	// Both when sent over a pipe or in shared memory.
+	// If batchSize > 1, it's followed by batchSize-1 more requests (see ExecBatch).
+	batchSize    uint64
+	progOffset   uint64
+	outputOffset uint64
+	outputSize   uint64
 }
 
 type executeReply struct {
@@ -541,7 +668,10 @@ type callReply struct {
 	index      uint32 // call index in the program
 	num        uint32 // syscall number (for cross-checking)
 	errno      uint32
+	resultLo   uint32 // call return value (low 32 bits)
+	resultHi   uint32 // call return value (high 32 bits)
 	flags      uint32 // see CallFlags
+	durationUs uint32 // call execution time in microseconds
 	signalSize uint32
 	coverSize  uint32
 	compsSize  uint32
@@ -738,18 +868,25 @@ func (c *command) wait() error {
 	return <-c.exited
 }
 
-func (c *command) exec(opts *ExecOpts, progData []byte) (output []byte, hanged bool, err0 error) {
-	req := &executeReq{
-		magic:            inMagic,
-		envFlags:         uint64(c.config.Flags),
-		execFlags:        uint64(opts.Flags),
-		pid:              uint64(c.pid),
-		syscallTimeoutMS: uint64(c.config.Timeouts.Syscall / time.Millisecond),
-		programTimeoutMS: uint64(c.config.Timeouts.Program / time.Millisecond),
-		slowdownScale:    uint64(c.config.Timeouts.Scale),
-		progSize:         uint64(len(progData)),
+func (c *command) exec(items []*execItem, progData []byte) (output []byte, hanged bool, err0 error) {
+	var reqData []byte
+	for _, item := range items {
+		req := &executeReq{
+			magic:            inMagic,
+			envFlags:         uint64(c.config.Flags),
+			execFlags:        uint64(item.opts.Flags),
+			pid:              uint64(c.pid),
+			syscallTimeoutMS: uint64(c.config.Timeouts.Syscall / time.Millisecond),
+			programTimeoutMS: uint64(c.config.Timeouts.Program / time.Millisecond),
+			slowdownScale:    uint64(c.config.Timeouts.Scale),
+			progSize:         uint64(len(progData)),
+			batchSize:        uint64(len(items)),
+			progOffset:       uint64(item.progOffset),
+			outputOffset:     uint64(item.outputOffset),
+			outputSize:       uint64(item.outputSize),
+		}
+		reqData = append(reqData, (*[unsafe.Sizeof(*req)]byte)(unsafe.Pointer(req))[:]...)
 	}
-	reqData := (*[unsafe.Sizeof(*req)]byte)(unsafe.Pointer(req))[:]
 	if _, err := c.outwp.Write(reqData); err != nil {
 		output = <-c.readDone
 		err0 = fmt.Errorf("executor %v: failed to write control pipe: %v", c.pid, err)
@@ -767,7 +904,7 @@ func (c *command) exec(opts *ExecOpts, progData []byte) (output []byte, hanged b
 	done := make(chan bool)
 	hang := make(chan bool)
 	go func() {
-		t := time.NewTimer(c.timeout)
+		t := time.NewTimer(c.timeout * time.Duration(len(items)))
 		select {
 		case <-t.C:
 			c.cmd.Process.Kill()
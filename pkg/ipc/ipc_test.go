@@ -126,6 +126,9 @@ func TestExecute(t *testing.T) {
 			if info.Calls[0].Errno != 0 {
 				t.Fatalf("simple call failed: %v\n%s", info.Calls[0].Errno, output)
 			}
+			if info.Calls[0].Result == -1 {
+				t.Fatalf("simple call returned -1\n%s", output)
+			}
 			if len(output) != 0 {
 				t.Fatalf("output on empty program")
 			}
@@ -133,6 +136,106 @@ func TestExecute(t *testing.T) {
 	}
 }
 
+func TestExecuteForkTemplate(t *testing.T) {
+	target, _, _, useShmem, useForkServer, timeouts := initTest(t)
+	if target.OS != targets.Linux || !useForkServer {
+		t.Skipf("fork template mode is not supported on %v", target.OS)
+	}
+
+	bin := buildExecutor(t, target)
+	defer os.Remove(bin)
+
+	cfg := &Config{
+		Executor:      bin,
+		UseShmem:      useShmem,
+		UseForkServer: useForkServer,
+		Timeouts:      timeouts,
+		Flags:         FlagForkTemplate,
+	}
+	env, err := MakeEnv(cfg, 0)
+	if err != nil {
+		t.Fatalf("failed to create env: %v", err)
+	}
+	defer env.Close()
+
+	// Execute more programs than one template serves (kTemplatePrograms in executor),
+	// so that the template is respawned.
+	iters := 150
+	if testing.Short() {
+		iters = 110
+	}
+	for i := 0; i < iters; i++ {
+		p := prepareTestProgram(target)
+		opts := &ExecOpts{
+			Flags: FlagThreaded,
+		}
+		output, info, hanged, err := env.Exec(opts, p)
+		if err != nil {
+			t.Fatalf("failed to run executor: %v", err)
+		}
+		if hanged {
+			t.Fatalf("program hanged:\n%s", output)
+		}
+		if len(info.Calls) != len(p.Calls) {
+			t.Fatalf("executed less calls (%v) than prog len(%v):\n%s", len(info.Calls), len(p.Calls), output)
+		}
+		if info.Calls[0].Errno != 0 || info.Calls[0].Flags&CallExecuted == 0 {
+			t.Fatalf("simple call failed: %+v\n%s", info.Calls[0], output)
+		}
+	}
+}
+
+func TestExecuteBatch(t *testing.T) {
+	target, _, _, useShmem, useForkServer, timeouts := initTest(t)
+
+	bin := buildExecutor(t, target)
+	defer os.Remove(bin)
+
+	cfg := &Config{
+		Executor:      bin,
+		UseShmem:      useShmem,
+		UseForkServer: useForkServer,
+		Timeouts:      timeouts,
+	}
+	env, err := MakeEnv(cfg, 0)
+	if err != nil {
+		t.Fatalf("failed to create env: %v", err)
+	}
+	defer env.Close()
+
+	// More programs than fit into a single batch and with different output sizes.
+	flags := []ExecFlags{0, FlagThreaded, FlagCollectSignal, FlagCollectSignal | FlagThreaded}
+	var opts []*ExecOpts
+	var progs []*prog.Prog
+	for i := 0; i < 100; i++ {
+		opts = append(opts, &ExecOpts{Flags: flags[i%len(flags)]})
+		progs = append(progs, prepareTestProgram(target))
+	}
+	output, infos, hanged, err := env.ExecBatch(opts, progs)
+	if err != nil {
+		t.Fatalf("failed to run executor: %v", err)
+	}
+	if hanged {
+		t.Fatalf("program hanged:\n%s", output)
+	}
+	if len(infos) != len(progs) {
+		t.Fatalf("got %v infos for %v programs", len(infos), len(progs))
+	}
+	for i, info := range infos {
+		p := progs[i]
+		if len(info.Calls) != len(p.Calls) {
+			t.Fatalf("prog %v: executed less calls (%v) than prog len(%v):\n%s",
+				i, len(info.Calls), len(p.Calls), output)
+		}
+		if info.Calls[0].Errno != 0 || info.Calls[0].Flags&CallExecuted == 0 {
+			t.Fatalf("prog %v: simple call failed: %v\n%s", i, info.Calls[0].Errno, output)
+		}
+	}
+	if len(output) != 0 {
+		t.Fatalf("unexpected output from %v batched programs:\n%s", len(progs), output)
+	}
+}
+
 func TestParallel(t *testing.T) {
 	target, _, _, useShmem, useForkServer, timeouts := initTest(t)
 	bin := buildExecutor(t, target)
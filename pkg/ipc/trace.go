@@ -0,0 +1,130 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package ipc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/google/syzkaller/prog"
+)
+
+// ProgTrace is a structured trace of a single program execution.
+// It's serialized as JSON, one trace per line (see WriteProgTrace and ReadProgTraces).
+type ProgTrace struct {
+	Program  string        `json:"program"`         // the program in the syzkaller syntax
+	Duration time.Duration `json:"duration"`        // program execution time (in nanoseconds)
+	Hanged   bool          `json:"hanged"`          // the program hanged and was killed
+	Calls    []CallTrace   `json:"calls"`           // one entry per program call
+	Extra    *CallTrace    `json:"extra,omitempty"` // signal/cover collected from background threads
+}
+
+// CallTrace describes execution of a single call.
+type CallTrace struct {
+	Name          string        `json:"name"`
+	Call          string        `json:"call"` // the call with its arguments in the syzkaller syntax
+	Executed      bool          `json:"executed"`
+	Finished      bool          `json:"finished"`
+	Blocked       bool          `json:"blocked,omitempty"`
+	FaultInjected bool          `json:"fault_injected,omitempty"`
+	Result        int64         `json:"result"`
+	Errno         int           `json:"errno"`
+	Duration      time.Duration `json:"duration"` // call execution time (in nanoseconds)
+	Signal        int           `json:"signal"`   // number of signal elements
+	Cover         int           `json:"cover"`    // number of covered PCs
+	Comps         []CompTrace   `json:"comps,omitempty"`
+}
+
+// CompTrace is a comparison operand along with all operands it was compared to.
+type CompTrace struct {
+	Op   uint64   `json:"op"`
+	Args []uint64 `json:"args"`
+}
+
+// MakeProgTrace creates a trace of execution of p from its results. info may be nil
+// if no calls were executed.
+func MakeProgTrace(p *prog.Prog, info *ProgInfo, duration time.Duration, hanged bool) *ProgTrace {
+	data := p.Serialize()
+	trace := &ProgTrace{
+		Program:  string(data),
+		Duration: duration,
+		Hanged:   hanged,
+		Calls:    make([]CallTrace, len(p.Calls)),
+	}
+	// The program is serialized one call per line, calls may be preceded by comment lines.
+	var lines [][]byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) != 0 && line[0] != '#' {
+			lines = append(lines, line)
+		}
+	}
+	for i, c := range p.Calls {
+		call := &trace.Calls[i]
+		call.Name = c.Meta.Name
+		if len(lines) == len(p.Calls) {
+			call.Call = string(lines[i])
+		}
+		call.Result = -1
+		if info != nil && i < len(info.Calls) {
+			fillCallTrace(call, &info.Calls[i])
+		}
+	}
+	if info != nil && (len(info.Extra.Signal) != 0 || len(info.Extra.Cover) != 0) {
+		trace.Extra = &CallTrace{
+			Signal: len(info.Extra.Signal),
+			Cover:  len(info.Extra.Cover),
+		}
+	}
+	return trace
+}
+
+func fillCallTrace(call *CallTrace, inf *CallInfo) {
+	call.Executed = inf.Flags&CallExecuted != 0
+	call.Finished = inf.Flags&CallFinished != 0
+	call.Blocked = inf.Flags&CallBlocked != 0
+	call.FaultInjected = inf.Flags&CallFaultInjected != 0
+	if call.Executed {
+		call.Result = inf.Result
+		call.Errno = inf.Errno
+		call.Duration = inf.Duration
+	}
+	call.Signal = len(inf.Signal)
+	call.Cover = len(inf.Cover)
+	for op, args := range inf.Comps {
+		comp := CompTrace{Op: op}
+		for arg := range args {
+			comp.Args = append(comp.Args, arg)
+		}
+		sort.Slice(comp.Args, func(i, j int) bool { return comp.Args[i] < comp.Args[j] })
+		call.Comps = append(call.Comps, comp)
+	}
+	sort.Slice(call.Comps, func(i, j int) bool { return call.Comps[i].Op < call.Comps[j].Op })
+}
+
+// WriteProgTrace writes the trace as a single line of JSON.
+func WriteProgTrace(w io.Writer, trace *ProgTrace) error {
+	data, err := json.Marshal(trace)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// ReadProgTraces reads all traces written by WriteProgTrace.
+func ReadProgTraces(r io.Reader) ([]*ProgTrace, error) {
+	var traces []*ProgTrace
+	for dec := json.NewDecoder(r); dec.More(); {
+		trace := new(ProgTrace)
+		if err := dec.Decode(trace); err != nil {
+			return nil, fmt.Errorf("failed to parse trace %v: %v", len(traces), err)
+		}
+		traces = append(traces, trace)
+	}
+	return traces, nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package ipc_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	. "github.com/google/syzkaller/pkg/ipc"
+	"github.com/google/syzkaller/prog"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func TestProgTrace(t *testing.T) {
+	target, err := prog.GetTarget(targets.TestOS, targets.TestArch64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := target.Deserialize([]byte(`r0 = test$res0()
+test$res1(r0)
+test$res1(0xffffffffffffffff) (fail_nth: 1)
+`), prog.Strict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &ProgInfo{
+		Calls: []CallInfo{
+			{
+				Flags:    CallExecuted | CallFinished,
+				Signal:   []uint32{1, 2, 3},
+				Cover:    []uint32{1, 2},
+				Result:   5,
+				Duration: time.Second,
+				Comps: prog.CompMap{
+					3: {2: true, 1: true},
+					1: {4: true},
+				},
+			},
+			{
+				Flags:  CallExecuted | CallFinished | CallBlocked,
+				Errno:  22,
+				Result: -1,
+			},
+			{
+				Flags:  CallExecuted | CallFaultInjected,
+				Result: -1,
+			},
+		},
+		Extra: CallInfo{Signal: []uint32{10}},
+	}
+	trace := MakeProgTrace(p, info, time.Millisecond, false)
+	want := &ProgTrace{
+		Program:  string(p.Serialize()),
+		Duration: time.Millisecond,
+		Calls: []CallTrace{
+			{
+				Name:     "test$res0",
+				Call:     "r0 = test$res0()",
+				Executed: true,
+				Finished: true,
+				Result:   5,
+				Duration: time.Second,
+				Signal:   3,
+				Cover:    2,
+				Comps: []CompTrace{
+					{Op: 1, Args: []uint64{4}},
+					{Op: 3, Args: []uint64{1, 2}},
+				},
+			},
+			{
+				Name:     "test$res1",
+				Call:     "test$res1(r0)",
+				Executed: true,
+				Finished: true,
+				Blocked:  true,
+				Result:   -1,
+				Errno:    22,
+			},
+			{
+				Name:          "test$res1",
+				Call:          "test$res1(0xffffffffffffffff) (fail_nth: 1)",
+				Executed:      true,
+				FaultInjected: true,
+				Result:        -1,
+			},
+		},
+		Extra: &CallTrace{Signal: 1},
+	}
+	if diff := cmp.Diff(want, trace); diff != "" {
+		t.Fatal(diff)
+	}
+	buf := new(bytes.Buffer)
+	noInfo := MakeProgTrace(p, nil, 0, true)
+	for _, tr := range []*ProgTrace{trace, noInfo} {
+		if err := WriteProgTrace(buf, tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	traces, err := ReadProgTraces(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]*ProgTrace{trace, noInfo}, traces); diff != "" {
+		t.Fatal(diff)
+	}
+	if noInfo.Calls[1].Executed || noInfo.Calls[1].Result != -1 || !noInfo.Hanged {
+		t.Fatalf("bad trace without info: %+v", noInfo)
+	}
+}
+
+func TestProgTraceComments(t *testing.T) {
+	target, err := prog.GetTarget(targets.TestOS, targets.TestArch64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := target.Deserialize([]byte(`# program comment
+r0 = test$res0()
+# call comment
+test$res1(r0)
+`), prog.Strict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace := MakeProgTrace(p, nil, 0, false)
+	for i, want := range []string{"r0 = test$res0()", "test$res1(r0)"} {
+		if got := trace.Calls[i].Call; got != want {
+			t.Errorf("call %v: got %q, want %q", i, got, want)
+		}
+	}
+}
@@ -4,6 +4,7 @@
 package kconfig
 
 import (
+	"fmt"
 	"sort"
 
 	"github.com/google/syzkaller/pkg/debugtracer"
@@ -47,14 +48,7 @@ top:
 		for _, part := range [][]string{diff[:half], diff[half:]} {
 			dt.Log("trying half: %v", part)
 			closure := kconf.addDependencies(base, full, part)
-			candidate := base.clone()
-			// Always move all non-tristate configs from full to base as we don't minimize them.
-			for _, cfg := range other {
-				candidate.Set(cfg.Name, cfg.Value)
-			}
-			for _, cfg := range closure {
-				candidate.Set(cfg, Yes)
-			}
+			candidate := makeCandidate(base, other, closure)
 			res, err := pred(candidate)
 			if err != nil {
 				return nil, err
@@ -79,6 +73,100 @@ top:
 	return current, nil
 }
 
+// MinimizeCoverage finds a smaller config that still allows the corpus to cover the given targets
+// (e.g. function or file names). The cover callback returns the set of targets covered on a kernel
+// built with the provided config. Base and full configs have the same meaning as for Minimize.
+// Targets that are not covered even with the full config are ignored.
+func (kconf *KConfig) MinimizeCoverage(base, full *ConfigFile, targets []string,
+	cover func(*ConfigFile) (map[string]bool, error), dt debugtracer.DebugTracer) (*ConfigFile, error) {
+	fullCover, err := cover(full)
+	if err != nil {
+		return nil, err
+	}
+	var required []string
+	for _, target := range targets {
+		if fullCover[target] {
+			required = append(required, target)
+		} else {
+			dt.Log("%v is not covered with the full config, ignoring", target)
+		}
+	}
+	if len(required) == 0 {
+		return nil, fmt.Errorf("none of the targets are covered with the full config")
+	}
+	pred := func(candidate *ConfigFile) (bool, error) {
+		covered, err := cover(candidate)
+		if err != nil {
+			return false, err
+		}
+		for _, target := range required {
+			if !covered[target] {
+				dt.Log("%v is not covered", target)
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	diff, other := kconf.missingConfigs(base, full)
+	dt.Log("kconfig coverage minimization: base=%v full=%v diff=%v targets=%v",
+		len(base.Configs), len(full.Configs), len(diff), required)
+	if res, err := pred(base); err != nil {
+		return nil, err
+	} else if res {
+		dt.Log("base config covers all targets")
+		return base, nil
+	}
+	// Different targets may need configs that are far away from each other in the diff,
+	// so halving the diff as Minimize does would most likely stop right away.
+	// Instead we try to drop chunks of the remaining configs and reduce the chunk size
+	// when no chunk can be dropped (similar to delta debugging).
+	// This needs more predicate invocations, but keeps every config that is required for any target.
+	current, keep := full.clone(), diff
+	for chunk := (len(keep) + 1) / 2; chunk > 0; {
+		dropped := false
+		for start := 0; start < len(keep) && !dropped; start += chunk {
+			end := start + chunk
+			if end > len(keep) {
+				end = len(keep)
+			}
+			part := append(append([]string{}, keep[:start]...), keep[end:]...)
+			closure := kconf.addDependencies(base, full, part)
+			if len(closure) >= len(keep) {
+				// Dependencies of the remaining configs bring the dropped ones back.
+				continue
+			}
+			dt.Log("trying to drop: %v", keep[start:end])
+			candidate := makeCandidate(base, other, closure)
+			res, err := pred(candidate)
+			if err != nil {
+				return nil, err
+			}
+			if res {
+				dt.Log("dropped, %v configs left", len(closure))
+				current, keep, dropped = candidate, closure, true
+			}
+		}
+		if !dropped {
+			chunk /= 2
+		}
+	}
+	dt.Log("resulting configs: %v", keep)
+	return current, nil
+}
+
+// makeCandidate returns base with the configs set to Yes.
+// All non-tristate configs are always moved from full to base as we don't minimize them.
+func makeCandidate(base *ConfigFile, other []*Config, configs []string) *ConfigFile {
+	candidate := base.clone()
+	for _, cfg := range other {
+		candidate.Set(cfg.Name, cfg.Value)
+	}
+	for _, cfg := range configs {
+		candidate.Set(cfg, Yes)
+	}
+	return candidate
+}
+
 func (kconf *KConfig) missingConfigs(base, full *ConfigFile) (tristate []string, other []*Config) {
 	for _, cfg := range full.Configs {
 		if cfg.Value == Yes && base.Value(cfg.Name) == No {
@@ -117,3 +117,75 @@ CONFIG_ROSE=y
 		})
 	}
 }
+
+func TestMinimizeCoverage(t *testing.T) {
+	const (
+		kconfig = `
+mainmenu "test"
+config A
+config B
+config C
+config D
+config NET
+config AX25
+	tristate "Amateur Radio AX.25 Level 2 protocol"
+	depends on NET
+config ROSE
+	tristate "Amateur Radio X.25 PLP (Rose)"
+	depends on AX25
+`
+		baseConfig = `
+CONFIG_A=y
+`
+		fullConfig = `
+CONFIG_A=y
+CONFIG_B=y
+CONFIG_C=y
+CONFIG_D=y
+CONFIG_I=42
+CONFIG_NET=y
+CONFIG_AX25=y
+CONFIG_ROSE=y
+`
+	)
+	kconf, err := ParseData(targets.Get("linux", "amd64"), []byte(kconfig), "kconf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := ParseConfigData([]byte(baseConfig), "base")
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := ParseConfigData([]byte(fullConfig), "full")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cover := func(cf *ConfigFile) (map[string]bool, error) {
+		res := map[string]bool{
+			"b.c":      cf.Value("B") != No,
+			"rose_rcv": cf.Value("ROSE") != No && cf.Value("AX25") != No && cf.Value("NET") != No,
+			"d_func":   cf.Value("D") != No,
+		}
+		return res, nil
+	}
+	res, err := kconf.MinimizeCoverage(base, full, []string{"b.c", "rose_rcv", "not_covered"},
+		cover, &debugtracer.TestTracer{T: t})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `
+CONFIG_A=y
+CONFIG_I=42
+CONFIG_AX25=y
+CONFIG_B=y
+CONFIG_NET=y
+CONFIG_ROSE=y
+`
+	if result := string(res.Serialize()); result != want {
+		t.Fatalf("got:\n%v\n\nwant:\n%s", result, want)
+	}
+	_, err = kconf.MinimizeCoverage(base, full, []string{"not_covered"}, cover, &debugtracer.TestTracer{T: t})
+	if err == nil {
+		t.Fatalf("minimization with uncovered targets did not fail")
+	}
+}
@@ -18,6 +18,17 @@ type Config struct {
 	HTTP string `json:"http"`
 	// TCP address to serve RPC for fuzzer processes (optional).
 	RPC string `json:"rpc,omitempty"`
+	// PEM files with the manager certificate/private key and the CA certificate (optional).
+	// If specified, fuzzers connect to the manager with mutual TLS authentication and encryption
+	// using the fuzzer_tls_cert/fuzzer_tls_key certificate signed by the same CA.
+	// The fuzzer certificate, key and the CA certificate are copied into VMs.
+	// The manager certificate must be valid for the "syz-manager" DNS name,
+	// because fuzzers reach the manager via VM-specific forwarded addresses.
+	RPCTLSCert    string `json:"rpc_tls_cert,omitempty"`
+	RPCTLSKey     string `json:"rpc_tls_key,omitempty"`
+	RPCTLSCA      string `json:"rpc_tls_ca,omitempty"`
+	FuzzerTLSCert string `json:"fuzzer_tls_cert,omitempty"`
+	FuzzerTLSKey  string `json:"fuzzer_tls_key,omitempty"`
 	// Location of a working directory for the syz-manager process. Outputs here include:
 	// - <workdir>/crashes/*: crash output files
 	// - <workdir>/corpus.db: corpus with interesting programs
@@ -56,6 +67,10 @@ type Config struct {
 	//		{ "name": "mydriver": "path": ["mydriver_path"]}
 	//	]
 	KernelSubsystem []Subsystem `json:"kernel_subsystem,omitempty"`
+	// Symbolizer used for kernel crash reports: "addr2line" (default) runs addr2line from binutils,
+	// "native" parses DWARF of the kernel binaries in-process, which does not require binutils
+	// for the target arch and is faster when many reports need to be symbolized.
+	Symbolizer string `json:"symbolizer,omitempty"`
 	// Arbitrary optional tag that is saved along with crash reports (e.g. branch/commit).
 	Tag string `json:"tag,omitempty"`
 	// Location of the disk image file.
@@ -69,6 +84,13 @@ type Config struct {
 	HubClient string `json:"hub_client,omitempty"`
 	HubAddr   string `json:"hub_addr,omitempty"`
 	HubKey    string `json:"hub_key,omitempty"`
+	// PEM files with the manager certificate/private key and the CA certificate
+	// used to verify the hub certificate (optional).
+	// If specified, the hub connection uses mutual TLS authentication and encryption,
+	// the hub must be configured with TLS as well.
+	HubTLSCert string `json:"hub_tls_cert,omitempty"`
+	HubTLSKey  string `json:"hub_tls_key,omitempty"`
+	HubTLSCA   string `json:"hub_tls_ca,omitempty"`
 	// Hub input domain identifier (optional).
 	// The domain is used to avoid duplicate work (input minimization, smashing)
 	// across multiple managers testing similar kernels and connected to the same hub.
@@ -132,6 +154,13 @@ type Config struct {
 	// on this value.
 	SandboxArg int `json:"sandbox_arg"`
 
+	// Do the per-program test setup once per a number of programs instead of per program (linux only).
+	// Test processes are forked from a pre-initialized template process, programs executed by the same
+	// template share the working directory. Sandbox setup is done once per executor process in both modes,
+	// so only the per-program setup is saved and the effect depends on the kernel and the programs.
+	// Fuzzers benchmark both modes on the first start in a VM and log the results to the VM console.
+	ForkTemplate bool `json:"fork_template,omitempty"`
+
 	// Use KCOV coverage (default: true).
 	Cover bool `json:"cover"`
 	// Use coverage filter. Supported types of filter:
@@ -144,13 +173,57 @@ type Config struct {
 	// eg. "0xffffffff81000000:0x10\n"
 	CovFilter covFilterCfg `json:"cover_filter,omitempty"`
 
+	// Directed fuzzing: steer fuzzing towards the specified kernel functions and files
+	// (regular expressions, same as in cover_filter).
+	// eg. "directed": {"functions": ["^tcp_v4_connect$"], "files": ["^net/ipv4/tcp.c$"]}.
+	// The manager computes distances from coverage points to the targets over the kernel call graph
+	// (only direct calls are considered) and fuzzers choose and mutate inputs that get closer
+	// to the targets more frequently. Requires kernel_obj, supported only for amd64 and arm64.
+	// Note: distances are function-level, all coverage points of a function have the same distance
+	// (AFLGo additionally computes basic block distances within functions, this is not implemented).
+	Directed directedCfg `json:"directed,omitempty"`
+
+	// Periodically save the number of covered PCs per kernel subsystem (see KernelSubsystem)
+	// and per source file into workdir/coverhistory.json (requires cover, false by default).
+	// The history is shown as time series on the /coverhistory page. When the manager is restarted
+	// (e.g. with a new kernel build), once the corpus is triaged coverage of each subsystem
+	// is compared with the last record made before the restart, and drops larger than
+	// cover_regression_threshold are reported in the log and in the web UI.
+	CoverHistory bool `json:"cover_history,omitempty"`
+	// Drop of subsystem coverage in percent that is considered a regression (10 by default).
+	CoverRegressionThreshold int `json:"cover_regression_threshold,omitempty"`
+
 	// For each prog in the corpus, remember the raw array of PCs obtained from the kernel.
 	// It can be useful for debugging syzkaller descriptions and syzkaller itself.
 	// Disabled by default as it slows down fuzzing.
 	RawCover bool `json:"raw_cover"`
 
+	// Systematically inject faults into every call of every corpus program (false by default,
+	// requires fault injection support in the kernel). Fuzzers try all fault sites (fail_nth values)
+	// of each call instead of injecting faults only during smashing. Explored fault sites are recorded
+	// in workdir/faults.db so that they are not re-explored after restart; fault sites that crashed
+	// the kernel are skipped on subsequent sweeps.
+	FaultCampaign bool `json:"fault_campaign,omitempty"`
+
+	// Generate syscalls that are slower than the syscall timeout on average less frequently (false by default).
+	// Execution time of syscalls is shown on the /syscalls page regardless of this option.
+	DeprioritizeSlowCalls bool `json:"deprioritize_slow_calls,omitempty"`
+
+	// Policy of choosing corpus programs for mutation:
+	// "signal" (default): proportionally to the size of the program signal;
+	// "power": prefer programs with signal that few other programs have and programs
+	// whose mutations recently gave new signal, programs that were mutated many times
+	// without any result are chosen less frequently. Increases memory consumption of fuzzers.
+	// The number of mutated programs that gave new signal is shown as "fuzz new signal" stat.
+	CorpusSchedule string `json:"corpus_schedule,omitempty"`
+
 	// Reproduce, localize and minimize crashers (default: true).
 	Reproduce bool `json:"reproduce"`
+	// Number of times the final syz and C reproducers are re-run to estimate how reliably
+	// they trigger the crash (default: 0, i.e. no estimation). If it's larger than 1,
+	// option simplifications that make the reproducer significantly less reliable are rejected.
+	// Note: each accepted simplification costs that many additional runs, 5 is a reasonable value.
+	ReproRuns int `json:"repro_runs,omitempty"`
 
 	// The number of VMs that are reserved to only perform fuzzing and nothing else.
 	// Can be helpful e.g. to ensure that the pool of fuzzing VMs is never exhaused and
@@ -187,6 +260,19 @@ type Config struct {
 	// the output.
 	StraceBin string `json:"strace_bin"`
 
+	// Periodically reset VM state by restoring a snapshot instead of rebooting the VM
+	// (false by default, supported only by some VM types, e.g. qemu with "savevm" enabled).
+	// The snapshot is taken after boot once the binaries are copied into the VM. The fuzzer
+	// runs inside of the VM, so the snapshot can't be restored under it: instead the fuzzer
+	// checkpoints its work queue and exits after every snapshot_reset_period programs,
+	// the manager restores the snapshot and starts a new fuzzer that reconnects
+	// and continues with the checkpointed work. So side effects of programs do not
+	// accumulate for longer than the period, but programs are not isolated from each other.
+	// pkg/repro restores the snapshot before every test run to make the runs deterministic.
+	SnapshotResets bool `json:"snapshot_resets,omitempty"`
+	// Number of programs the fuzzer executes between VM state resets (default: 10000).
+	SnapshotResetPeriod int `json:"snapshot_reset_period,omitempty"`
+
 	// Type of virtual machine to use, e.g. "qemu", "gce", "android", "isolated", etc.
 	Type string `json:"type"`
 	// VM-type-specific parameters.
@@ -212,6 +298,11 @@ type Subsystem struct {
 	Paths []string `json:"path"`
 }
 
+type directedCfg struct {
+	Files     []string `json:"files,omitempty"`
+	Functions []string `json:"functions,omitempty"`
+}
+
 type covFilterCfg struct {
 	Files     []string `json:"files,omitempty"`
 	Functions []string `json:"functions,omitempty"`
@@ -143,6 +143,37 @@ func Complete(cfg *Config) error {
 	default:
 		return fmt.Errorf("config param sandbox must contain one of none/setuid/namespace/android")
 	}
+	if cfg.ForkTemplate && cfg.TargetOS != targets.Linux {
+		return fmt.Errorf("fork_template is only supported on linux")
+	}
+	switch cfg.Symbolizer {
+	case "":
+		cfg.Symbolizer = "addr2line"
+	case "addr2line", "native":
+	default:
+		return fmt.Errorf("config param symbolizer must be one of addr2line/native")
+	}
+	switch cfg.CorpusSchedule {
+	case "":
+		cfg.CorpusSchedule = "signal"
+	case "signal", "power":
+	default:
+		return fmt.Errorf("config param corpus_schedule must be one of signal/power")
+	}
+	if len(cfg.Directed.Functions)+len(cfg.Directed.Files) != 0 &&
+		cfg.TargetVMArch != targets.AMD64 && cfg.TargetVMArch != targets.ARM64 {
+		return fmt.Errorf("directed fuzzing is only supported on amd64 and arm64")
+	}
+	if cfg.CoverHistory && !cfg.Cover {
+		return fmt.Errorf("cover_history requires cover")
+	}
+	if cfg.CoverRegressionThreshold == 0 {
+		cfg.CoverRegressionThreshold = 10
+	}
+	if cfg.CoverRegressionThreshold < 0 || cfg.CoverRegressionThreshold > 100 {
+		return fmt.Errorf("bad config param cover_regression_threshold: %v, want [1, 100]",
+			cfg.CoverRegressionThreshold)
+	}
 	if err := cfg.checkSSHParams(); err != nil {
 		return err
 	}
@@ -156,6 +187,35 @@ func Complete(cfg *Config) error {
 			return err
 		}
 	}
+	if cfg.HubTLSCert != "" || cfg.HubTLSKey != "" || cfg.HubTLSCA != "" {
+		if err := checkNonEmpty(
+			cfg.HubTLSCert, "hub_tls_cert",
+			cfg.HubTLSKey, "hub_tls_key",
+			cfg.HubTLSCA, "hub_tls_ca",
+		); err != nil {
+			return err
+		}
+		cfg.HubTLSCert = osutil.Abs(cfg.HubTLSCert)
+		cfg.HubTLSKey = osutil.Abs(cfg.HubTLSKey)
+		cfg.HubTLSCA = osutil.Abs(cfg.HubTLSCA)
+	}
+	if cfg.RPCTLSCert != "" || cfg.RPCTLSKey != "" || cfg.RPCTLSCA != "" ||
+		cfg.FuzzerTLSCert != "" || cfg.FuzzerTLSKey != "" {
+		if err := checkNonEmpty(
+			cfg.RPCTLSCert, "rpc_tls_cert",
+			cfg.RPCTLSKey, "rpc_tls_key",
+			cfg.RPCTLSCA, "rpc_tls_ca",
+			cfg.FuzzerTLSCert, "fuzzer_tls_cert",
+			cfg.FuzzerTLSKey, "fuzzer_tls_key",
+		); err != nil {
+			return err
+		}
+		cfg.RPCTLSCert = osutil.Abs(cfg.RPCTLSCert)
+		cfg.RPCTLSKey = osutil.Abs(cfg.RPCTLSKey)
+		cfg.RPCTLSCA = osutil.Abs(cfg.RPCTLSCA)
+		cfg.FuzzerTLSCert = osutil.Abs(cfg.FuzzerTLSCert)
+		cfg.FuzzerTLSKey = osutil.Abs(cfg.FuzzerTLSKey)
+	}
 	if cfg.HubDomain != "" &&
 		!regexp.MustCompile(`^[a-zA-Z0-9-_.]{2,50}(/[a-zA-Z0-9-_.]{2,50})?$`).MatchString(cfg.HubDomain) {
 		return fmt.Errorf("bad value for hub_domain")
@@ -171,6 +231,15 @@ func Complete(cfg *Config) error {
 	if cfg.FuzzingVMs < 0 {
 		return fmt.Errorf("fuzzing_vms cannot be less than 0")
 	}
+	if cfg.ReproRuns < 0 {
+		return fmt.Errorf("repro_runs cannot be less than 0")
+	}
+	if cfg.SnapshotResetPeriod < 0 {
+		return fmt.Errorf("snapshot_reset_period cannot be less than 0")
+	}
+	if cfg.SnapshotResets && cfg.SnapshotResetPeriod == 0 {
+		cfg.SnapshotResetPeriod = 10000
+	}
 
 	var err error
 	cfg.Syscalls, err = ParseEnabledSyscalls(cfg.Target, cfg.EnabledSyscalls, cfg.DisabledSyscalls)
@@ -49,7 +49,10 @@ func (ctx *akaros) Symbolize(rep *Report) error {
 	if ctx.objfile == "" {
 		return nil
 	}
-	symb := symbolizer.NewSymbolizer(ctx.config.target)
+	symb, err := ctx.config.newSymbolizer()
+	if err != nil {
+		return err
+	}
 	defer symb.Close()
 	var symbolized []byte
 	s := bufio.NewScanner(bytes.NewReader(rep.Report))
@@ -13,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/google/syzkaller/pkg/mgrconfig"
+	"github.com/google/syzkaller/pkg/symbolizer"
 	"github.com/google/syzkaller/pkg/vcs"
 	"github.com/google/syzkaller/sys/targets"
 )
@@ -122,8 +123,12 @@ func NewReporter(cfg *mgrconfig.Config) (*Reporter, error) {
 		kernelSrc:      cfg.KernelSrc,
 		kernelBuildSrc: cfg.KernelBuildSrc,
 		kernelObj:      cfg.KernelObj,
+		symbolizer:     cfg.Symbolizer,
 		ignores:        ignores,
 	}
+	if _, err := config.newSymbolizer(); err != nil {
+		return nil, err
+	}
 	rep, suppressions, err := ctor(config)
 	if err != nil {
 		return nil, err
@@ -177,9 +182,15 @@ type config struct {
 	kernelSrc      string
 	kernelBuildSrc string
 	kernelObj      string
+	symbolizer     string
 	ignores        []*regexp.Regexp
 }
 
+// newSymbolizer creates a symbolizer of the kind configured in mgrconfig.Config.Symbolizer.
+func (cfg *config) newSymbolizer() (*symbolizer.Symbolizer, error) {
+	return symbolizer.Make(cfg.target, cfg.symbolizer)
+}
+
 type fn func(cfg *config) (reporterImpl, []string, error)
 
 func compileRegexps(list []string) ([]*regexp.Regexp, error) {
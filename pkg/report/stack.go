@@ -0,0 +1,139 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/google/syzkaller/sys/targets"
+)
+
+// StackSignature is a normalized representation of the stack trace in a report.
+// Titles are sometimes different for the same bug (e.g. the crash happened in a different
+// inlined function or in a different caller), but stacks of such crashes are usually near-identical.
+// Signatures can be compared with StackSimilarity to detect such duplicates.
+type StackSignature struct {
+	// Function names from the top of the stack with offsets, compiler suffixes
+	// and uninteresting (reporting/sanitizer) frames removed.
+	Frames []string
+}
+
+// SimilarStackThreshold is the StackSimilarity value starting from which
+// two reports are considered to be about the same bug.
+const SimilarStackThreshold = 0.8
+
+const maxSignatureFrames = 16
+
+// GCC/LLVM clones: foo.isra.0, foo.constprop.3, foo.part.1, foo.cold, foo.llvm.123.
+var funcSuffixRe = regexp.MustCompile(`(\.(isra|constprop|part|cold|lto_priv|llvm|clone)(\.[0-9]+)*)+$`)
+
+// stackSignatureFormats are stack formats of the report types that support stack signatures.
+var stackSignatureFormats = map[string]*stackSignatureFormat{
+	targets.Akaros:  makeStackSignatureFormat(akarosStackParams),
+	targets.Linux:   makeStackSignatureFormat(linuxStackParams),
+	targets.Fuchsia: makeStackSignatureFormat(zirconStackParams),
+	"starnix":       makeStackSignatureFormat(zirconStackParams),
+}
+
+type stackSignatureFormat struct {
+	params *stackParams
+	// skipRe is compiled params.skipPatterns (nil if there are none).
+	skipRe *regexp.Regexp
+}
+
+func makeStackSignatureFormat(params *stackParams) *stackSignatureFormat {
+	format := &stackSignatureFormat{params: params}
+	if len(params.skipPatterns) != 0 {
+		format.skipRe = regexp.MustCompile(strings.Join(params.skipPatterns, "|"))
+	}
+	return format
+}
+
+// StackSignature computes stack signature of the report.
+// Frames are parsed and filtered with the same OS-specific frame formats and skip patterns
+// that are used to extract the guilty frame. The signature is empty for OSes
+// that don't describe their stack format.
+func (reporter *Reporter) StackSignature(rep *Report) StackSignature {
+	return extractStackSignature(stackSignatureFormats[reporter.typ], rep.Report)
+}
+
+func extractStackSignature(format *stackSignatureFormat, report []byte) StackSignature {
+	var sig StackSignature
+	if format == nil {
+		return sig
+	}
+	params := format.params
+	for s := bufio.NewScanner(bytes.NewReader(report)); s.Scan() && len(sig.Frames) < maxSignatureFrames; {
+		line := s.Bytes()
+		if matchesAny(line, params.corruptedLines) {
+			continue
+		}
+		var frames []string
+		for _, re := range params.frameRes {
+			if match := re.FindSubmatch(line); match != nil {
+				frames = appendStackFrame(frames, match, params, format.skipRe)
+				break
+			}
+		}
+		for _, frame := range frames {
+			frame = funcSuffixRe.ReplaceAllString(frame, "")
+			// The same function can be mentioned several times in a row
+			// (e.g. in RIP line and in the call trace).
+			if n := len(sig.Frames); n != 0 && sig.Frames[n-1] == frame {
+				continue
+			}
+			sig.Frames = append(sig.Frames, frame)
+		}
+	}
+	if len(sig.Frames) > maxSignatureFrames {
+		sig.Frames = sig.Frames[:maxSignatureFrames]
+	}
+	return sig
+}
+
+// ParseStackSignature parses signature serialized with StackSignature.String.
+func ParseStackSignature(data []byte) StackSignature {
+	var sig StackSignature
+	for _, frame := range bytes.Fields(data) {
+		sig.Frames = append(sig.Frames, string(frame))
+	}
+	return sig
+}
+
+func (sig StackSignature) String() string {
+	return strings.Join(sig.Frames, "\n")
+}
+
+func (sig StackSignature) Empty() bool {
+	return len(sig.Frames) == 0
+}
+
+// StackSimilarity returns similarity score of two stack signatures in [0, 1] range.
+// The score is based on the longest common subsequence of frames, so it tolerates
+// few added/removed frames (e.g. due to different inlining decisions) but not reordering.
+func StackSimilarity(a, b StackSignature) float64 {
+	if a.Empty() || b.Empty() {
+		return 0
+	}
+	lcs := make([][]int, len(a.Frames)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b.Frames)+1)
+	}
+	for i := len(a.Frames) - 1; i >= 0; i-- {
+		for j := len(b.Frames) - 1; j >= 0; j-- {
+			switch {
+			case a.Frames[i] == b.Frames[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] > lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	return 2 * float64(lcs[0][0]) / float64(len(a.Frames)+len(b.Frames))
+}
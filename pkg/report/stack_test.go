@@ -0,0 +1,75 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package report
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func TestExtractStackSignature(t *testing.T) {
+	report := []byte(`
+BUG: KASAN: use-after-free in ext4_xattr_set_entry.isra.0+0x1a/0x2b fs/ext4/xattr.c:1586
+Read of size 4 at addr ffff8880a5b2c1d0 by task syz-executor.0/8512
+
+CPU: 0 PID: 8512 Comm: syz-executor.0 Not tainted 5.4.0-rc1+ #0
+Call Trace:
+ __dump_stack lib/dump_stack.c:77 [inline]
+ dump_stack+0x172/0x1f0 lib/dump_stack.c:113
+ print_address_description.constprop.0.cold+0xd4/0x30b mm/kasan/report.c:374
+ __kasan_report.cold+0x1b/0x41 mm/kasan/report.c:506
+ kasan_report+0x12/0x20 mm/kasan/common.c:634
+ ? ext4_xattr_ibody_set+0x80/0x290 fs/ext4/xattr.c:2218
+ ext4_xattr_set_entry.isra.0+0x1a/0x2b fs/ext4/xattr.c:1586
+ ext4_xattr_ibody_set+0x80/0x290 fs/ext4/xattr.c:2218
+ ext4_xattr_set_handle+0x90c/0x1280 fs/ext4/xattr.c:2377
+ ext4_xattr_set+0x13f/0x340 fs/ext4/xattr.c:2489
+ __vfs_setxattr+0x11f/0x180 fs/xattr.c:150
+ do_syscall_64+0xfa/0x760 arch/x86/entry/common.c:290
+ entry_SYSCALL_64_after_hwframe+0x49/0xbe
+`)
+	want := []string{
+		"ext4_xattr_set_entry",
+		"ext4_xattr_ibody_set",
+		"ext4_xattr_set_handle",
+		"ext4_xattr_set",
+		"__vfs_setxattr",
+		"do_syscall_64",
+		"entry_SYSCALL_64_after_hwframe",
+	}
+	sig := extractStackSignature(stackSignatureFormats[targets.Linux], report)
+	if !reflect.DeepEqual(sig.Frames, want) {
+		t.Fatalf("got frames:\n%q\nwant:\n%q", sig.Frames, want)
+	}
+	if parsed := ParseStackSignature([]byte(sig.String() + "\n")); !reflect.DeepEqual(parsed, sig) {
+		t.Fatalf("signature does not roundtrip: %q", parsed.Frames)
+	}
+}
+
+func TestStackSimilarity(t *testing.T) {
+	sig := func(frames ...string) StackSignature {
+		return StackSignature{Frames: frames}
+	}
+	tests := []struct {
+		a, b StackSignature
+		sim  float64
+	}{
+		{sig("a", "b", "c", "d"), sig("a", "b", "c", "d"), 1},
+		{sig("a", "b", "c", "d", "e"), sig("b", "c", "d", "e"), 8.0 / 9},
+		{sig("a", "b", "c", "d"), sig("x", "y", "z"), 0},
+		{sig("a", "b", "c", "d"), sig("d", "c", "b", "a"), 0.25},
+		{sig(), sig("a"), 0},
+		{sig(), sig(), 0},
+	}
+	for i, test := range tests {
+		if got := StackSimilarity(test.a, test.b); got != test.sim {
+			t.Errorf("test #%v: got similarity %v, want %v", i, got, test.sim)
+		}
+		if got := StackSimilarity(test.b, test.a); got != test.sim {
+			t.Errorf("test #%v: similarity is not symmetric: %v", i, got)
+		}
+	}
+}
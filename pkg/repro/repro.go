@@ -16,6 +16,7 @@ import (
 	"github.com/google/syzkaller/pkg/log"
 	"github.com/google/syzkaller/pkg/mgrconfig"
 	"github.com/google/syzkaller/pkg/report"
+	"github.com/google/syzkaller/pkg/stats"
 	"github.com/google/syzkaller/prog"
 	"github.com/google/syzkaller/sys/targets"
 	"github.com/google/syzkaller/vm"
@@ -29,6 +30,9 @@ type Result struct {
 	// Information about the final (non-symbolized) crash that we reproduced.
 	// Can be different from what we started reproducing.
 	Report *report.Report
+	// How reliably the final reproducer (C if CRepro, syz otherwise) triggers the crash.
+	// Nil if the reliability was not estimated.
+	Reliability *Reliability
 }
 
 type Stats struct {
@@ -38,11 +42,46 @@ type Stats struct {
 	SimplifyProgTime time.Duration
 	ExtractCTime     time.Duration
 	SimplifyCTime    time.Duration
+	ReliabilityTime  time.Duration
+	// Reliability of the final syz and C reproducers (nil if not estimated/not extracted).
+	ProgReliability *Reliability
+	CReliability    *Reliability
+}
+
+// Reliability is the estimated probability that a reproducer triggers the crash in a single run.
+type Reliability struct {
+	Runs    int
+	Crashes int
+	Rate    float64
+	// 95% confidence interval for Rate.
+	Lower float64
+	Upper float64
+}
+
+func newReliability(crashes, runs int) *Reliability {
+	lower, upper := stats.BinomialCI(crashes, runs)
+	return &Reliability{
+		Runs:    runs,
+		Crashes: crashes,
+		Rate:    float64(crashes) / float64(runs),
+		Lower:   lower,
+		Upper:   upper,
+	}
+}
+
+func (r *Reliability) String() string {
+	if r == nil {
+		return "unknown"
+	}
+	return fmt.Sprintf("%v/%v runs (%.0f%%, 95%% CI %.0f%%-%.0f%%)",
+		r.Crashes, r.Runs, 100*r.Rate, 100*r.Lower, 100*r.Upper)
 }
 
 type reproInstance struct {
 	index    int
 	execProg *instance.ExecProgInstance
+	// The VM state was saved right after setup and is restored after each test.
+	snapshot bool
 }
 
 type context struct {
@@ -55,8 +94,15 @@ type context struct {
 	testTimeouts []time.Duration
 	startOpts    csource.Options
 	stats        *Stats
-	report       *report.Report
 	timeouts     targets.Timeouts
+	// Number of VMs that can be used to run tests concurrently.
+	parallel int
+	// Number of runs used to estimate reliability of reproducers.
+	reliabilityRuns int
+
+	// Protects report and stats.Log, which are updated by concurrently running tests.
+	mu     sync.Mutex
+	report *report.Report
 }
 
 func Run(crashLog []byte, cfg *mgrconfig.Config, features *host.Features, reporter *report.Reporter,
@@ -95,16 +141,18 @@ func Run(crashLog []byte, cfg *mgrconfig.Config, features *host.Features, report
 		testTimeouts = testTimeouts[2:]
 	}
 	ctx := &context{
-		target:       cfg.SysTarget,
-		reporter:     reporter,
-		crashTitle:   crashTitle,
-		crashType:    crashType,
-		instances:    make(chan *reproInstance, len(vmIndexes)),
-		bootRequests: make(chan int, len(vmIndexes)),
-		testTimeouts: testTimeouts,
-		startOpts:    createStartOptions(cfg, features, crashType),
-		stats:        new(Stats),
-		timeouts:     cfg.Timeouts,
+		target:          cfg.SysTarget,
+		reporter:        reporter,
+		crashTitle:      crashTitle,
+		crashType:       crashType,
+		instances:       make(chan *reproInstance, len(vmIndexes)),
+		bootRequests:    make(chan int, len(vmIndexes)),
+		testTimeouts:    testTimeouts,
+		startOpts:       createStartOptions(cfg, features, crashType),
+		stats:           new(Stats),
+		timeouts:        cfg.Timeouts,
+		parallel:        len(vmIndexes),
+		reliabilityRuns: cfg.ReproRuns,
 	}
 	ctx.reproLogf(0, "%v programs, %v VMs, timeouts %v", len(entries), len(vmIndexes), testTimeouts)
 	var wg sync.WaitGroup
@@ -136,7 +184,15 @@ func Run(crashLog []byte, cfg *mgrconfig.Config, features *host.Features, report
 				if inst == nil {
 					break
 				}
-				ctx.instances <- &reproInstance{execProg: inst, index: vmIndex}
+				snapshot := false
+				if cfg.SnapshotResets {
+					if err := inst.VMInstance.Snapshot(); err != nil {
+						ctx.reproLogf(0, "failed to save VM snapshot: %v", err)
+					} else {
+						snapshot = true
+					}
+				}
+				ctx.instances <- &reproInstance{execProg: inst, index: vmIndex, snapshot: snapshot}
 			}
 		}()
 	}
@@ -173,6 +229,13 @@ func Run(crashLog []byte, cfg *mgrconfig.Config, features *host.Features, report
 		ctx.reproLogf(3, "final repro crashed as (corrupted=%v):\n%s",
 			ctx.report.Corrupted, ctx.report.Report)
 		res.Report = ctx.report
+		if err := ctx.estimateFinalReliability(res); err != nil {
+			// Reliability is optional, the reproducer is still good.
+			ctx.reproLogf(0, "failed to estimate reproducer reliability: %v", err)
+			res.Reliability = nil
+			ctx.stats.ProgReliability = nil
+			ctx.stats.CReliability = nil
+		}
 	}
 	return res, ctx.stats, nil
 }
@@ -324,20 +387,21 @@ func (ctx *context) extractProgSingle(entries []*prog.LogEntry, duration time.Du
 	ctx.reproLogf(3, "single: executing %d programs separately with timeout %s", len(entries), duration)
 
 	opts := ctx.startOpts
-	for _, ent := range entries {
-		crashed, err := ctx.testProg(ent.P, duration, opts)
-		if err != nil {
-			return nil, err
-		}
-		if crashed {
-			res := &Result{
-				Prog:     ent.P,
-				Duration: duration * 3 / 2,
-				Opts:     opts,
-			}
-			ctx.reproLogf(3, "single: successfully extracted reproducer")
-			return res, nil
+	// Programs are tested concurrently, but the earlier programs are preferred.
+	idx, err := ctx.firstSuccess(len(entries), func(i int, stop <-chan bool) (*report.Report, error) {
+		return ctx.runProgs(entries[i:i+1], duration, opts, stop)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if idx != -1 {
+		res := &Result{
+			Prog:     entries[idx].P,
+			Duration: duration * 3 / 2,
+			Opts:     opts,
 		}
+		ctx.reproLogf(3, "single: successfully extracted reproducer")
+		return res, nil
 	}
 
 	ctx.reproLogf(3, "single: failed to extract reproducer")
@@ -353,8 +417,8 @@ func (ctx *context) extractProgBisect(entries []*prog.LogEntry, baseDuration tim
 	}
 
 	// Bisect the log to find multiple guilty programs.
-	entries, err := ctx.bisectProgs(entries, func(progs []*prog.LogEntry) (bool, error) {
-		return ctx.testProgs(progs, duration(len(progs)), opts)
+	entries, err := ctx.bisectProgs(entries, func(progs []*prog.LogEntry, stop <-chan bool) (*report.Report, error) {
+		return ctx.runProgs(progs, duration(len(progs)), opts, stop)
 	})
 	if err != nil {
 		return nil, err
@@ -425,11 +489,13 @@ func (ctx *context) simplifyProg(res *Result) (*Result, error) {
 	}()
 
 	// Do further simplifications.
+	var cur *Reliability
 	for _, simplify := range progSimplifies {
 		opts := res.Opts
 		if !simplify(&opts) || !checkOpts(&opts, ctx.timeouts, res.Duration) {
 			continue
 		}
+		curRep := ctx.lastReport()
 		crashed, err := ctx.testProg(res.Prog, res.Duration, opts)
 		if err != nil {
 			return nil, err
@@ -437,6 +503,16 @@ func (ctx *context) simplifyProg(res *Result) (*Result, error) {
 		if !crashed {
 			continue
 		}
+		var ok bool
+		cur, ok, err = ctx.checkReliability(cur, res.Opts, opts, curRep, func(opts csource.Options) (bool, error) {
+			return ctx.testProg(res.Prog, res.Duration, opts)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
 		res.Opts = opts
 		// Simplification successful, try extracting C repro.
 		res, err = ctx.extractC(res)
@@ -475,11 +551,13 @@ func (ctx *context) simplifyC(res *Result) (*Result, error) {
 		ctx.stats.SimplifyCTime = time.Since(start)
 	}()
 
+	var cur *Reliability
 	for _, simplify := range cSimplifies {
 		opts := res.Opts
 		if !simplify(&opts) || !checkOpts(&opts, ctx.timeouts, res.Duration) {
 			continue
 		}
+		curRep := ctx.lastReport()
 		crashed, err := ctx.testCProg(res.Prog, res.Duration, opts)
 		if err != nil {
 			return nil, err
@@ -487,11 +565,135 @@ func (ctx *context) simplifyC(res *Result) (*Result, error) {
 		if !crashed {
 			continue
 		}
+		var ok bool
+		cur, ok, err = ctx.checkReliability(cur, res.Opts, opts, curRep, func(opts csource.Options) (bool, error) {
+			return ctx.testCProg(res.Prog, res.Duration, opts)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
 		res.Opts = opts
 	}
 	return res, nil
 }
 
+// checkReliability is called when a reproducer with simplified options newOpts triggered the crash once.
+// It re-runs it to estimate its reliability and rejects the simplification if the reproducer
+// becomes significantly less reliable than with the current options curOpts.
+// cur is the reliability of the current options (estimated lazily if nil),
+// returns reliability of the options that should be used further.
+// The re-runs overwrite ctx.report, so it's restored to the report of the run with the accepted options:
+// the first crash with newOpts if they are accepted, or curRep (the report of curOpts) otherwise.
+func (ctx *context) checkReliability(cur *Reliability, curOpts, newOpts csource.Options, curRep *report.Report,
+	test func(opts csource.Options) (bool, error)) (*Reliability, bool, error) {
+	if ctx.reliabilityRuns <= 1 {
+		return nil, true, nil
+	}
+	newRep := ctx.lastReport()
+	accepted := false
+	defer func() {
+		if accepted {
+			ctx.setReport(newRep)
+		} else {
+			ctx.setReport(curRep)
+		}
+	}()
+	var err error
+	if cur == nil {
+		cur, err = ctx.estimateReliability(ctx.reliabilityRuns, 0, func() (bool, error) {
+			return test(curOpts)
+		})
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	// The first crash is already known, so do one run less.
+	rel, err := ctx.estimateReliability(ctx.reliabilityRuns-1, 1, func() (bool, error) {
+		return test(newOpts)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if rel.Upper < cur.Rate {
+		ctx.reproLogf(3, "simplified options are less reliable: %v vs %v", rel, cur)
+		return cur, false, nil
+	}
+	accepted = true
+	return rel, true, nil
+}
+
+// estimateFinalReliability re-runs the final syz and C reproducers and records their reliability.
+func (ctx *context) estimateFinalReliability(res *Result) error {
+	if ctx.reliabilityRuns == 0 {
+		return nil
+	}
+	ctx.reproLogf(2, "estimating reproducer reliability")
+	start := time.Now()
+	defer func() {
+		ctx.stats.ReliabilityTime = time.Since(start)
+	}()
+	var err error
+	ctx.stats.ProgReliability, err = ctx.estimateReliability(ctx.reliabilityRuns, 0, func() (bool, error) {
+		return ctx.testProg(res.Prog, res.Duration, res.Opts)
+	})
+	if err != nil {
+		return err
+	}
+	res.Reliability = ctx.stats.ProgReliability
+	if res.CRepro {
+		ctx.stats.CReliability, err = ctx.estimateReliability(ctx.reliabilityRuns, 0, func() (bool, error) {
+			return ctx.testCProg(res.Prog, res.Duration, res.Opts)
+		})
+		if err != nil {
+			return err
+		}
+		res.Reliability = ctx.stats.CReliability
+	}
+	ctx.reproLogf(2, "reproducer reliability: syz %v, C %v", ctx.stats.ProgReliability, ctx.stats.CReliability)
+	return nil
+}
+
+// estimateReliability runs the test the given number of times concurrently on all VMs
+// and returns the reliability estimation taking into account previously known results.
+func (ctx *context) estimateReliability(runs, knownCrashes int, test func() (bool, error)) (*Reliability, error) {
+	parallel := ctx.parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	sem := make(chan bool, parallel)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	crashes := knownCrashes
+	var firstErr error
+	for i := 0; i < runs; i++ {
+		sem <- true
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			crashed, err := test()
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			if crashed {
+				crashes++
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return newReliability(crashes, runs+knownCrashes), nil
+}
+
 func checkOpts(opts *csource.Options, timeouts targets.Timeouts, timeout time.Duration) bool {
 	if !opts.Repeat && timeout >= time.Minute {
 		// If we have a non-repeating C reproducer with timeout > vm.NoOutputTimeout and it hangs
@@ -517,34 +719,147 @@ func checkOpts(opts *csource.Options, timeouts targets.Timeouts, timeout time.Du
 
 func (ctx *context) testProg(p *prog.Prog, duration time.Duration, opts csource.Options) (crashed bool, err error) {
 	entry := prog.LogEntry{P: p}
-	return ctx.testProgs([]*prog.LogEntry{&entry}, duration, opts)
+	rep, err := ctx.runProgs([]*prog.LogEntry{&entry}, duration, opts, nil)
+	return ctx.crashed(rep), err
 }
 
-func (ctx *context) testWithInstance(callback func(inst *instance.ExecProgInstance) (rep *instance.RunResult,
-	err error)) (bool, error) {
-	inst := <-ctx.instances
+// crashed records the report of a test that is run on its own (not concurrently with competing tests),
+// and returns whether the test crashed.
+func (ctx *context) crashed(rep *report.Report) bool {
+	if rep == nil {
+		return false
+	}
+	ctx.setReport(rep)
+	return true
+}
+
+// testWithInstance runs the callback on a free instance and returns the crash report (nil if the test
+// did not crash). If stop is closed before or during the test, the test is aborted and its result is ignored.
+func (ctx *context) testWithInstance(stop <-chan bool, callback func(inst *instance.ExecProgInstance) (
+	rep *instance.RunResult, err error)) (*report.Report, error) {
+	var inst *reproInstance
+	select {
+	case inst = <-ctx.instances:
+	case <-stop:
+		return nil, nil
+	}
 	if inst == nil {
-		return false, fmt.Errorf("all VMs failed to boot")
+		return nil, fmt.Errorf("all VMs failed to boot")
 	}
 	defer ctx.returnInstance(inst)
+	inst.execProg.Stop = stop
 	result, err := callback(inst.execProg)
+	inst.execProg.Stop = nil
+	if isStopped(stop) {
+		return nil, nil
+	}
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	rep := result.Report
 	if rep == nil {
-		return false, nil
+		return nil, nil
 	}
 	if rep.Suppressed {
 		ctx.reproLogf(2, "suppressed program crash: %v", rep.Title)
-		return false, nil
+		return nil, nil
 	}
 	if ctx.crashType == report.MemoryLeak && rep.Type != report.MemoryLeak {
 		ctx.reproLogf(2, "not a leak crash: %v", rep.Title)
-		return false, nil
+		return nil, nil
 	}
+	return rep, nil
+}
+
+func (ctx *context) lastReport() *report.Report {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	return ctx.report
+}
+
+func (ctx *context) setReport(rep *report.Report) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
 	ctx.report = rep
-	return true, nil
+}
+
+func isStopped(stop <-chan bool) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// firstSuccess runs tests 0..n-1 concurrently on up to ctx.parallel VMs and returns index
+// of the first test (in the order of indices) that crashed (returned a report), or -1 if none did.
+// Tests are started in the order of indices; once a test succeeds, all tests with larger
+// indices are cancelled, but tests with smaller indices are still waited for.
+// With a single VM this is equivalent to running the tests sequentially until the first success.
+// Only the report of the returned test is recorded as ctx.report.
+func (ctx *context) firstSuccess(n int, test func(i int, stop <-chan bool) (*report.Report, error)) (int, error) {
+	type result struct {
+		idx int
+		rep *report.Report
+		err error
+	}
+	parallel := ctx.parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	results := make(chan result, n)
+	stops := make([]chan bool, n)
+	stopped := make([]bool, n)
+	cancel := func(from int) {
+		for i := from; i < n; i++ {
+			if stops[i] != nil && !stopped[i] {
+				stopped[i] = true
+				close(stops[i])
+			}
+		}
+	}
+	best, next, running := n, 0, 0
+	var bestRep *report.Report
+	var firstErr error
+	for {
+		for firstErr == nil && next < best && running < parallel {
+			stops[next] = make(chan bool)
+			go func(i int, stop <-chan bool) {
+				rep, err := test(i, stop)
+				results <- result{i, rep, err}
+			}(next, stops[next])
+			next++
+			running++
+		}
+		if running == 0 {
+			break
+		}
+		res := <-results
+		running--
+		if stopped[res.idx] {
+			continue
+		}
+		if res.err != nil {
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			cancel(0)
+			continue
+		}
+		if res.rep != nil && res.idx < best {
+			best, bestRep = res.idx, res.rep
+			cancel(best + 1)
+		}
+	}
+	if firstErr != nil {
+		return -1, firstErr
+	}
+	if best == n {
+		return -1, nil
+	}
+	ctx.setReport(bestRep)
+	return best, nil
 }
 
 func encodeEntries(entries []*prog.LogEntry) []byte {
@@ -555,10 +870,12 @@ func encodeEntries(entries []*prog.LogEntry) []byte {
 	return buf.Bytes()
 }
 
-func (ctx *context) testProgs(entries []*prog.LogEntry, duration time.Duration, opts csource.Options) (
-	crashed bool, err error) {
+// runProgs runs the programs and returns the crash report (nil if they did not crash).
+// The report is not recorded as ctx.report, it's up to the caller to decide if the result is used.
+func (ctx *context) runProgs(entries []*prog.LogEntry, duration time.Duration, opts csource.Options,
+	stop <-chan bool) (*report.Report, error) {
 	if len(entries) == 0 {
-		return false, fmt.Errorf("no programs to execute")
+		return nil, fmt.Errorf("no programs to execute")
 	}
 	pstr := encodeEntries(entries)
 	program := entries[0].P.String()
@@ -574,18 +891,29 @@ func (ctx *context) testProgs(entries []*prog.LogEntry, duration time.Duration,
 	}
 	ctx.reproLogf(2, "testing program (duration=%v, %+v): %s", duration, opts, program)
 	ctx.reproLogf(3, "detailed listing:\n%s", pstr)
-	return ctx.testWithInstance(func(inst *instance.ExecProgInstance) (*instance.RunResult, error) {
+	return ctx.testWithInstance(stop, func(inst *instance.ExecProgInstance) (*instance.RunResult, error) {
 		return inst.RunSyzProg(pstr, duration, opts)
 	})
 }
 
 func (ctx *context) testCProg(p *prog.Prog, duration time.Duration, opts csource.Options) (crashed bool, err error) {
-	return ctx.testWithInstance(func(inst *instance.ExecProgInstance) (*instance.RunResult, error) {
+	rep, err := ctx.testWithInstance(nil, func(inst *instance.ExecProgInstance) (*instance.RunResult, error) {
 		return inst.RunCProg(p, duration, opts)
 	})
+	return ctx.crashed(rep), err
 }
 
 func (ctx *context) returnInstance(inst *reproInstance) {
+	if inst.snapshot {
+		// Restoring the snapshot is faster than reboot and gives exactly the same
+		// initial state for every test, which makes the results more deterministic.
+		err := inst.execProg.VMInstance.Restore()
+		if err == nil {
+			ctx.instances <- inst
+			return
+		}
+		ctx.reproLogf(0, "failed to restore VM snapshot: %v", err)
+	}
 	ctx.bootRequests <- inst.index
 	inst.execProg.VMInstance.Close()
 }
@@ -593,19 +921,21 @@ func (ctx *context) returnInstance(inst *reproInstance) {
 func (ctx *context) reproLogf(level int, format string, args ...interface{}) {
 	prefix := fmt.Sprintf("reproducing crash '%v': ", ctx.crashTitle)
 	log.Logf(level, prefix+format, args...)
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
 	ctx.stats.Log = append(ctx.stats.Log, []byte(fmt.Sprintf(format, args...)+"\n")...)
 }
 
-func (ctx *context) bisectProgs(progs []*prog.LogEntry, pred func([]*prog.LogEntry) (bool, error)) (
-	[]*prog.LogEntry, error) {
+func (ctx *context) bisectProgs(progs []*prog.LogEntry,
+	pred func([]*prog.LogEntry, <-chan bool) (*report.Report, error)) ([]*prog.LogEntry, error) {
 	ctx.reproLogf(3, "bisect: bisecting %d programs", len(progs))
 
 	ctx.reproLogf(3, "bisect: executing all %d programs", len(progs))
-	crashed, err := pred(progs)
+	rep, err := pred(progs, nil)
 	if err != nil {
 		return nil, err
 	}
-	if !crashed {
+	if !ctx.crashed(rep) {
 		ctx.reproLogf(3, "bisect: didn't crash")
 		return nil, nil
 	}
@@ -631,38 +961,23 @@ again:
 
 		chunk1 := chunk[0 : len(chunk)/2]
 		chunk2 := chunk[len(chunk)/2:]
-		ctx.reproLogf(3, "bisect: chunk split: <%v> => <%v>, <%v>",
-			len(chunk), len(chunk1), len(chunk2))
-
-		ctx.reproLogf(3, "bisect: triggering crash without chunk #1")
-		progs = flatenChunks(guilty1, guilty2, chunk2)
-		crashed, err := pred(progs)
-		if err != nil {
-			return nil, err
-		}
-
-		if crashed {
-			guilty = nil
-			guilty = append(guilty, guilty1...)
-			guilty = append(guilty, chunk2)
-			guilty = append(guilty, guilty2...)
-			ctx.reproLogf(3, "bisect: crashed, chunk #1 evicted")
-			goto again
-		}
-
-		ctx.reproLogf(3, "bisect: triggering crash without chunk #2")
-		progs = flatenChunks(guilty1, guilty2, chunk1)
-		crashed, err = pred(progs)
+		hypotheses := bisectHypotheses(chunk, ctx.parallel)
+		ctx.reproLogf(3, "bisect: testing %v hypotheses for chunk <%v>", len(hypotheses), len(chunk))
+		idx, err := ctx.firstSuccess(len(hypotheses), func(h int, stop <-chan bool) (*report.Report, error) {
+			ctx.reproLogf(3, "bisect: triggering crash with <%v> of <%v> programs of the chunk",
+				len(hypotheses[h]), len(chunk))
+			return pred(flatenChunks(guilty1, guilty2, hypotheses[h]), stop)
+		})
 		if err != nil {
 			return nil, err
 		}
 
-		if crashed {
+		if idx != -1 {
 			guilty = nil
 			guilty = append(guilty, guilty1...)
-			guilty = append(guilty, chunk1)
+			guilty = append(guilty, hypotheses[idx])
 			guilty = append(guilty, guilty2...)
-			ctx.reproLogf(3, "bisect: crashed, chunk #2 evicted")
+			ctx.reproLogf(3, "bisect: crashed, chunk reduced <%v> => <%v>", len(chunk), len(hypotheses[idx]))
 			goto again
 		}
 
@@ -689,6 +1004,29 @@ again:
 	return progs, nil
 }
 
+// bisectHypotheses returns parts of the chunk that may be enough to trigger the crash on their own
+// (with the rest of the guilty chunks), in the order of preference.
+// The basic hypotheses are the second and the first halves of the chunk. If there are enough VMs
+// to test more hypotheses concurrently, it also adds quarters, eighths, etc of the chunk.
+// Finer parts give larger reduction, so they are preferred.
+func bisectHypotheses(chunk []*prog.LogEntry, parallel int) [][]*prog.LogEntry {
+	var levels [][][]*prog.LogEntry
+	total := 0
+	for parts := 2; parts <= len(chunk) && (parts == 2 || total+parts <= parallel); parts *= 2 {
+		var level [][]*prog.LogEntry
+		for j := parts - 1; j >= 0; j-- {
+			level = append(level, chunk[j*len(chunk)/parts:(j+1)*len(chunk)/parts])
+		}
+		levels = append(levels, level)
+		total += parts
+	}
+	var res [][]*prog.LogEntry
+	for i := len(levels) - 1; i >= 0; i-- {
+		res = append(res, levels[i]...)
+	}
+	return res
+}
+
 func flatenChunks(guilty1, guilty2 [][]*prog.LogEntry, chunk []*prog.LogEntry) []*prog.LogEntry {
 	var progs []*prog.LogEntry
 	for _, c := range guilty1 {
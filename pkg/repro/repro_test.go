@@ -4,10 +4,14 @@
 package repro
 
 import (
+	"fmt"
 	"math/rand"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/syzkaller/pkg/csource"
+	"github.com/google/syzkaller/pkg/report"
 	"github.com/google/syzkaller/pkg/testutil"
 	"github.com/google/syzkaller/prog"
 	"github.com/google/syzkaller/sys/targets"
@@ -22,8 +26,18 @@ func initTest(t *testing.T) (*rand.Rand, int) {
 }
 
 func TestBisect(t *testing.T) {
+	for _, parallel := range []int{1, 3, 8} {
+		parallel := parallel
+		t.Run(fmt.Sprint(parallel), func(t *testing.T) {
+			testBisect(t, parallel)
+		})
+	}
+}
+
+func testBisect(t *testing.T, parallel int) {
 	ctx := &context{
-		stats: new(Stats),
+		stats:    new(Stats),
+		parallel: parallel,
 	}
 
 	rd, iters := initTest(t)
@@ -45,14 +59,17 @@ func TestBisect(t *testing.T) {
 			progs = append(progs, &prog)
 			numGuilty++
 		}
-		progs, _ = ctx.bisectProgs(progs, func(p []*prog.LogEntry) (bool, error) {
+		progs, _ = ctx.bisectProgs(progs, func(p []*prog.LogEntry, stop <-chan bool) (*report.Report, error) {
 			guilty := 0
 			for _, prog := range p {
 				if prog.Proc == 42 {
 					guilty++
 				}
 			}
-			return guilty == numGuilty, nil
+			if guilty != numGuilty {
+				return nil, nil
+			}
+			return &report.Report{Title: "crash"}, nil
 		})
 		if numGuilty > 8 && len(progs) == 0 {
 			// Bisection has been aborted.
@@ -69,6 +86,128 @@ func TestBisect(t *testing.T) {
 	}
 }
 
+func TestFirstSuccess(t *testing.T) {
+	for _, parallel := range []int{1, 2, 5} {
+		ctx := &context{
+			stats:    new(Stats),
+			parallel: parallel,
+		}
+		for _, test := range []struct {
+			crashes []bool
+			want    int
+		}{
+			{[]bool{false, false, false}, -1},
+			{[]bool{false, true, true, false}, 1},
+			{[]bool{true, true}, 0},
+			{[]bool{false, false, false, false, false, false, true}, 6},
+			{nil, -1},
+		} {
+			var mu sync.Mutex
+			started := make(map[int]bool)
+			ctx.report = nil
+			idx, err := ctx.firstSuccess(len(test.crashes), func(i int, stop <-chan bool) (*report.Report, error) {
+				mu.Lock()
+				started[i] = true
+				mu.Unlock()
+				if !test.crashes[i] {
+					// Let tests that crash finish first, so that cancellation is exercised.
+					select {
+					case <-stop:
+					case <-time.After(10 * time.Millisecond):
+					}
+				}
+				if !test.crashes[i] {
+					return nil, nil
+				}
+				return &report.Report{Title: fmt.Sprint(i)}, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if idx != test.want {
+				t.Fatalf("parallel=%v crashes=%v: got %v, want %v", parallel, test.crashes, idx, test.want)
+			}
+			if idx == -1 && ctx.report != nil || idx != -1 && ctx.report.Title != fmt.Sprint(idx) {
+				t.Fatalf("parallel=%v crashes=%v: recorded report %+v of a wrong test", parallel, test.crashes, ctx.report)
+			}
+			if parallel == 1 {
+				// Sequential execution must not start tests after the first success.
+				for i := range started {
+					if test.want != -1 && i > test.want {
+						t.Fatalf("crashes=%v: test %v was started after success", test.crashes, i)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestCheckReliability(t *testing.T) {
+	ctx := &context{
+		stats:           new(Stats),
+		parallel:        3,
+		reliabilityRuns: 10,
+	}
+	reliable := csource.Options{Threaded: true}
+	flaky := csource.Options{Threaded: true, Repeat: true}
+	unreliable := csource.Options{}
+	var mu sync.Mutex
+	runs := make(map[csource.Options]int)
+	test := func(opts csource.Options) (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		runs[opts]++
+		crashed := false
+		switch opts {
+		case reliable:
+			crashed = true
+		case flaky:
+			crashed = runs[opts]%2 == 0
+		}
+		if crashed {
+			ctx.setReport(&report.Report{Title: "re-run"})
+		}
+		return crashed, nil
+	}
+	curRep := &report.Report{Title: "current"}
+	newRep := &report.Report{Title: "simplified"}
+	ctx.setReport(newRep)
+	cur, ok, err := ctx.checkReliability(nil, reliable, unreliable, curRep, test)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok || cur.Rate != 1 || cur.Runs != 10 || runs[reliable] != 10 || runs[unreliable] != 9 {
+		t.Fatalf("unreliable options accepted: ok=%v cur=%v runs=%v", ok, cur, runs)
+	}
+	if ctx.report != curRep {
+		t.Fatalf("report of rejected options is kept: %q", ctx.report.Title)
+	}
+	ctx.setReport(newRep)
+	cur, ok, err = ctx.checkReliability(cur, reliable, flaky, curRep, test)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok || cur.Rate != 1 || runs[reliable] != 10 {
+		t.Fatalf("flaky options accepted: ok=%v cur=%v runs=%v", ok, cur, runs)
+	}
+	rel := newReliability(5, 10)
+	ctx.setReport(newRep)
+	cur, ok, err = ctx.checkReliability(rel, flaky, reliable, curRep, test)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || cur.Rate != 1 || cur.Runs != 10 || cur.Crashes != 10 {
+		t.Fatalf("reliable options rejected: ok=%v cur=%v", ok, cur)
+	}
+	if ctx.report != newRep {
+		t.Fatalf("report of accepted options is not kept: %q", ctx.report.Title)
+	}
+	ctx.reliabilityRuns = 1
+	if _, ok, _ := ctx.checkReliability(nil, reliable, unreliable, curRep, test); !ok {
+		t.Fatalf("options rejected with disabled reliability estimation")
+	}
+}
+
 func TestSimplifies(t *testing.T) {
 	opts := csource.Options{
 		Threaded:     true,
@@ -4,7 +4,9 @@
 package rpctype
 
 import (
+	"bufio"
 	"compress/flate"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
@@ -16,11 +18,15 @@ import (
 )
 
 type RPCServer struct {
-	ln net.Listener
-	s  *rpc.Server
+	ln     net.Listener
+	s      *rpc.Server
+	tls    *tls.Config
+	stream func(*Stream)
 }
 
-func NewRPCServer(addr, name string, receiver interface{}) (*RPCServer, error) {
+// NewRPCServer creates a server for the receiver. If tlsCfg is not nil,
+// connections are authenticated and encrypted with TLS (see ServerTLSConfig).
+func NewRPCServer(addr, name string, receiver interface{}, tlsCfg *tls.Config) (*RPCServer, error) {
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to listen on %v: %v", addr, err)
@@ -30,12 +36,19 @@ func NewRPCServer(addr, name string, receiver interface{}) (*RPCServer, error) {
 		return nil, err
 	}
 	serv := &RPCServer{
-		ln: ln,
-		s:  s,
+		ln:  ln,
+		s:   s,
+		tls: tlsCfg,
 	}
 	return serv, nil
 }
 
+// HandleStreams makes the server accept Stream connections (see DialStream) on the same address
+// and pass them to handler. Must be called before Serve.
+func (serv *RPCServer) HandleStreams(handler func(*Stream)) {
+	serv.stream = handler
+}
+
 func (serv *RPCServer) Serve() {
 	for {
 		conn, err := serv.ln.Accept()
@@ -44,10 +57,29 @@ func (serv *RPCServer) Serve() {
 			continue
 		}
 		setupKeepAlive(conn, time.Minute)
-		go serv.s.ServeConn(newFlateConn(conn))
+		if serv.tls != nil {
+			// The handshake happens on the first read in ServeConn.
+			conn = tls.Server(conn, serv.tls)
+		}
+		if serv.stream == nil {
+			go serv.s.ServeConn(newFlateConn(conn))
+			continue
+		}
+		go serv.serveConn(conn)
 	}
 }
 
+// serveConn serves either an rpc or a stream connection depending on the first bytes.
+func (serv *RPCServer) serveConn(conn net.Conn) {
+	bconn := &bufConn{Conn: conn, r: bufio.NewReader(conn)}
+	if magic, err := bconn.r.Peek(len(streamMagic)); err == nil && string(magic) == streamMagic {
+		bconn.r.Discard(len(streamMagic))
+		serv.stream(newStream(bconn))
+		return
+	}
+	serv.s.ServeConn(newFlateConn(bconn))
+}
+
 func (serv *RPCServer) Addr() net.Addr {
 	return serv.ln.Addr()
 }
@@ -75,11 +107,18 @@ func Dial(addr string, timeScale time.Duration) (net.Conn, error) {
 	return conn, nil
 }
 
-func NewRPCClient(addr string, timeScale time.Duration) (*RPCClient, error) {
+// NewRPCClient connects to the server at addr. If tlsCfg is not nil,
+// the connection is authenticated and encrypted with TLS (see ClientTLSConfig).
+func NewRPCClient(addr string, timeScale time.Duration, tlsCfg *tls.Config) (*RPCClient, error) {
 	conn, err := Dial(addr, timeScale)
 	if err != nil {
 		return nil, err
 	}
+	if tlsCfg != nil {
+		if conn, err = tlsHandshake(conn, addr, timeScale, tlsCfg); err != nil {
+			return nil, err
+		}
+	}
 	cli := &RPCClient{
 		conn:      conn,
 		c:         rpc.NewClient(newFlateConn(conn)),
@@ -99,8 +138,8 @@ func (cli *RPCClient) Close() {
 	cli.c.Close()
 }
 
-func RPCCall(addr string, timeScale time.Duration, method string, args, reply interface{}) error {
-	c, err := NewRPCClient(addr, timeScale)
+func RPCCall(addr string, timeScale time.Duration, tlsCfg *tls.Config, method string, args, reply interface{}) error {
+	c, err := NewRPCClient(addr, timeScale, tlsCfg)
 	if err != nil {
 		return err
 	}
@@ -108,11 +147,41 @@ func RPCCall(addr string, timeScale time.Duration, method string, args, reply in
 	return c.Call(method, args, reply)
 }
 
+func tlsHandshake(conn net.Conn, addr string, timeScale time.Duration, tlsCfg *tls.Config) (net.Conn, error) {
+	if tlsCfg.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		tlsCfg = tlsCfg.Clone()
+		tlsCfg.ServerName = host
+	}
+	tlsConn := tls.Client(conn, tlsCfg)
+	tlsConn.SetDeadline(time.Now().Add(time.Minute * timeScale))
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("TLS handshake with %v failed: %v", addr, err)
+	}
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
 func setupKeepAlive(conn net.Conn, keepAlive time.Duration) {
 	conn.(*net.TCPConn).SetKeepAlive(true)
 	conn.(*net.TCPConn).SetKeepAlivePeriod(keepAlive)
 }
 
+// bufConn is net.Conn with buffered reads (used to peek at the stream magic).
+type bufConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (bc *bufConn) Read(data []byte) (int, error) {
+	return bc.r.Read(data)
+}
+
 // flateConn wraps net.Conn in flate.Reader/Writer for compressed traffic.
 type flateConn struct {
 	r io.ReadCloser
@@ -7,6 +7,7 @@ package rpctype
 
 import (
 	"math"
+	"time"
 
 	"github.com/google/syzkaller/pkg/host"
 	"github.com/google/syzkaller/pkg/ipc"
@@ -20,12 +21,38 @@ type Input struct {
 	Cover    []uint32
 	CallID   int // seq number of call in the prog to which the item is related (-1 for extra)
 	RawCover []uint32
+	// Distance to directed fuzzing targets as computed by the fuzzer that found the input
+	// (see ConnectRes.TargetDistances).
+	Distance uint32
 }
 
 type Candidate struct {
 	Prog      []byte
 	Minimized bool
 	Smashed   bool
+	// Results of previous fault injection sweeps of the program, if any.
+	Faults *FaultSweep
+}
+
+// FaultSweep holds results of systematic fault injection into all calls
+// of a corpus program (see mgrconfig.Config.FaultCampaign).
+type FaultSweep struct {
+	Sig string // hash of the program
+	// Set if all fault sites of the program were explored.
+	Done  bool
+	Calls []FaultCall
+}
+
+// FaultCall describes fault sites of a single call of the program.
+// Fault sites are identified by fail_nth values.
+type FaultCall struct {
+	Call int
+	// Number of fault sites (the largest fail_nth that resulted in an injected fault).
+	Sites int
+	// Fault sites that gave new signal.
+	NewSignal []int
+	// Fault sites that crashed the kernel (detected by the manager).
+	Crashed []int
 }
 
 type ExecTask struct {
@@ -49,6 +76,13 @@ type ConnectRes struct {
 	MemoryLeakFrames  []string
 	DataRaceFrames    []string
 	CoverFilterBitmap []byte
+	// Distances from coverage PCs to directed fuzzing targets (see mgrconfig.Config.Directed),
+	// scaled by 100. PCs that can't reach the targets are not present. Nil if directed fuzzing is disabled.
+	TargetDistances map[uint32]uint32
+	// State checkpointed by the previous fuzzer that ran on the same instance, if any.
+	State *FuzzerState
+	// The manager accepts Stream connections, older managers support only Poll.
+	Stream bool
 }
 
 type CheckArgs struct {
@@ -75,6 +109,40 @@ type PollArgs struct {
 	NeedCandidates bool
 	MaxSignal      signal.Serial
 	Stats          map[string]uint64
+	// Fault injection sweeps finished since the last poll.
+	FaultSweeps []FaultSweep
+	// Per-syscall execution time since the last poll.
+	CallTimes map[string]CallTime
+}
+
+// CallTime is execution time of a syscall accumulated over a number of executions.
+type CallTime struct {
+	Execs uint64
+	Total time.Duration
+	Max   time.Duration
+}
+
+func (ct *CallTime) Add(d time.Duration) {
+	ct.Execs++
+	ct.Total += d
+	if ct.Max < d {
+		ct.Max = d
+	}
+}
+
+func (ct *CallTime) Merge(other CallTime) {
+	ct.Execs += other.Execs
+	ct.Total += other.Total
+	if ct.Max < other.Max {
+		ct.Max = other.Max
+	}
+}
+
+func (ct CallTime) Average() time.Duration {
+	if ct.Execs == 0 {
+		return 0
+	}
+	return ct.Total / time.Duration(ct.Execs)
 }
 
 type PollRes struct {
@@ -83,6 +151,62 @@ type PollRes struct {
 	MaxSignal  signal.Serial
 }
 
+// StreamMsg is a message of the streaming manager-fuzzer protocol (see Stream).
+// It reuses the Poll messages, only some of the fields are set in each message.
+// The fuzzer sends Name in the first message, then streams NewInput and Poll (stats, new max signal
+// and requests for candidates) without waiting for replies. The manager pushes Res as soon as
+// it has new max signal, inputs from other fuzzers or requested candidates.
+type StreamMsg struct {
+	// Fuzzer->manager.
+	Name     string
+	NewInput *NewInputArgs
+	Poll     *PollArgs
+	// Manager->fuzzer.
+	Res *PollRes
+	// Res is a reply to a Poll with NeedCandidates, if it contains no candidates,
+	// the manager has no more candidates.
+	CandidatesReply bool
+}
+
+// FuzzerState is a checkpoint of the fuzzer work queue.
+// Fuzzers periodically send it to the manager, and the manager hands it
+// to the next fuzzer started on the same instance (e.g. after a VM crash),
+// so that pending triage and smash work is not lost.
+type FuzzerState struct {
+	// Max signal discovered since the last poll.
+	MaxSignal  signal.Serial
+	Candidates []Candidate
+	Triage     []TriageItem
+	Smash      []SmashItem
+	Faults     []FaultSweepItem
+}
+
+// TriageItem is a program with potential new signal in a single call that is not triaged yet.
+type TriageItem struct {
+	Prog   []byte
+	CallID int // -1 for extra signal
+	Signal []uint32
+	Errno  int
+	Flags  int
+}
+
+// SmashItem is a new corpus program that is not smashed yet.
+type SmashItem struct {
+	Prog   []byte
+	CallID int
+}
+
+// FaultSweepItem is a corpus program that is not swept with fault injection yet.
+type FaultSweepItem struct {
+	Prog   []byte
+	Faults *FaultSweep
+}
+
+type CheckpointArgs struct {
+	Name  string
+	State FuzzerState
+}
+
 type RunnerConnectArgs struct {
 	Pool, VM int
 }
@@ -160,6 +284,8 @@ type HubSyncArgs struct {
 	Del []string
 	// Repros found since last sync.
 	Repros [][]byte
+	// What the manager did with inputs received from hub since last sync.
+	Results []HubInputResult
 }
 
 type HubSyncRes struct {
@@ -180,6 +306,15 @@ type HubInput struct {
 	Prog   []byte
 }
 
+type HubInputResult struct {
+	// Hash of the input program.
+	Sig string
+	// The input was rejected (e.g. contains disabled syscalls).
+	Dropped bool
+	// The input was accepted as a candidate that needs minimization.
+	Minimized bool
+}
+
 type RunTestPollReq struct {
 	Name string
 }
@@ -0,0 +1,85 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package rpctype
+
+import (
+	"crypto/tls"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// streamMagic is sent by stream clients before anything else. It allows RPCServer to serve
+// both rpc and stream connections on the same address: rpc connections start with
+// a non-final flate block, the first byte of which has the lowest bit unset.
+const streamMagic = "SYZSTRM1"
+
+// Stream is a bidirectional message stream between manager and fuzzer.
+// Messages are gob-encoded StreamMsg's sent over a compressed connection,
+// both sides can send at any time.
+type Stream struct {
+	conn io.ReadWriteCloser
+	enc  *gob.Encoder
+	dec  *gob.Decoder
+	mu   sync.Mutex
+}
+
+// CanDialStream says if a stream can be opened to addr in addition to the rpc connection.
+// vm/gvisor passes the only manager connection in stdin (the "stdin" address, see Dial)
+// and it's already used by the rpc client, so streams are not possible there.
+func CanDialStream(addr string) bool {
+	return addr != "stdin"
+}
+
+// DialStream connects to a server that handles streams (see RPCServer.HandleStreams).
+func DialStream(addr string, timeScale time.Duration, tlsCfg *tls.Config) (*Stream, error) {
+	if !CanDialStream(addr) {
+		return nil, fmt.Errorf("can't open a stream to %v", addr)
+	}
+	conn, err := Dial(addr, timeScale)
+	if err != nil {
+		return nil, err
+	}
+	if tlsCfg != nil {
+		if conn, err = tlsHandshake(conn, addr, timeScale, tlsCfg); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := conn.Write([]byte(streamMagic)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to start stream: %v", err)
+	}
+	return newStream(conn), nil
+}
+
+func newStream(conn io.ReadWriteCloser) *Stream {
+	fc := newFlateConn(conn)
+	return &Stream{
+		conn: fc,
+		enc:  gob.NewEncoder(fc),
+		dec:  gob.NewDecoder(fc),
+	}
+}
+
+// Send sends a message. It's safe to call Send concurrently with Send and Recv.
+func (s *Stream) Send(msg *StreamMsg) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(msg)
+}
+
+// Recv receives the next message. It must not be called concurrently with itself.
+func (s *Stream) Recv() (*StreamMsg, error) {
+	msg := new(StreamMsg)
+	if err := s.dec.Decode(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (s *Stream) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package rpctype
+
+import (
+	"testing"
+)
+
+func TestDialStreamStdin(t *testing.T) {
+	if CanDialStream("stdin") || !CanDialStream("127.0.0.1:1234") {
+		t.Fatalf("wrong stream support")
+	}
+	// Stream must not be opened over the rpc connection passed in stdin (vm/gvisor).
+	if stream, err := DialStream("stdin", 1, nil); err == nil {
+		stream.Close()
+		t.Fatalf("opened stream over stdin")
+	}
+}
+
+func TestStream(t *testing.T) {
+	serv, err := NewRPCServer("127.0.0.1:0", "Test", new(testReceiver), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Echo server that replies to every poll with the number of the message.
+	serv.HandleStreams(func(s *Stream) {
+		defer s.Close()
+		for n := 0; ; n++ {
+			msg, err := s.Recv()
+			if err != nil {
+				return
+			}
+			if msg.Poll == nil {
+				continue
+			}
+			res := &StreamMsg{
+				Res:             &PollRes{Candidates: make([]Candidate, n)},
+				CandidatesReply: msg.Poll.NeedCandidates,
+			}
+			if err := s.Send(res); err != nil {
+				return
+			}
+		}
+	})
+	go serv.Serve()
+	addr := serv.Addr().String()
+
+	stream, err := DialStream(addr, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	if err := stream.Send(&StreamMsg{Name: "fuzzer"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < 5; i++ {
+		if err := stream.Send(&StreamMsg{Poll: &PollArgs{NeedCandidates: i%2 == 0}}); err != nil {
+			t.Fatal(err)
+		}
+		msg, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Res == nil || len(msg.Res.Candidates) != i || msg.CandidatesReply != (i%2 == 0) {
+			t.Fatalf("bad reply %v: %+v", i, msg)
+		}
+	}
+
+	// Plain rpc must still work on the same address.
+	var reply string
+	if err := RPCCall(addr, 1, nil, "Test.Echo", "hello", &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply != "hello" {
+		t.Fatalf("got reply %q", reply)
+	}
+}
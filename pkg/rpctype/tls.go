@@ -0,0 +1,64 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package rpctype
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// ManagerTLSServerName is the name that fuzzers expect in the manager certificate.
+// Fuzzers reach the manager via VM-specific forwarded addresses, so the address can't be used.
+const ManagerTLSServerName = "syz-manager"
+
+// ServerTLSConfig returns config for a server that requires client certificates signed by the CA.
+// cert/key are PEM files with the server certificate and private key,
+// ca is a PEM file with the CA certificate(s) used to verify clients.
+func ServerTLSConfig(cert, key, ca string) (*tls.Config, error) {
+	certs, pool, err := loadTLSFiles(cert, key, ca)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: certs,
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// ClientTLSConfig returns config for a client that presents the certificate
+// and verifies the server certificate with the CA (see ServerTLSConfig).
+func ClientTLSConfig(cert, key, ca string) (*tls.Config, error) {
+	certs, pool, err := loadTLSFiles(cert, key, ca)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: certs,
+		RootCAs:      pool,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+func loadTLSFiles(cert, key, ca string) ([]tls.Certificate, *x509.CertPool, error) {
+	if cert == "" || key == "" || ca == "" {
+		return nil, nil, fmt.Errorf("TLS certificate, key and CA files are required")
+	}
+	pair, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load TLS certificate: %v", err)
+	}
+	caData, err := os.ReadFile(ca)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read TLS CA: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caData) {
+		return nil, nil, fmt.Errorf("no certificates in TLS CA file %v", ca)
+	}
+	return []tls.Certificate{pair}, pool, nil
+}
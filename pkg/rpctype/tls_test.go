@@ -0,0 +1,159 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package rpctype
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/syzkaller/pkg/osutil"
+)
+
+type testReceiver struct{}
+
+func (*testReceiver) Echo(args, reply *string) error {
+	*reply = *args
+	return nil
+}
+
+func TestTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := genCert(t, dir, "ca", nil, nil)
+	genCert(t, dir, "server", ca, caKey)
+	genCert(t, dir, "client", ca, caKey)
+	otherCA, otherKey := genCert(t, dir, "other-ca", nil, nil)
+	genCert(t, dir, "other-client", otherCA, otherKey)
+	file := func(name string) string { return filepath.Join(dir, name) }
+
+	serverCfg, err := ServerTLSConfig(file("server.crt"), file("server.key"), file("ca.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	serv, err := NewRPCServer("127.0.0.1:0", "Test", new(testReceiver), serverCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go serv.Serve()
+	addr := serv.Addr().String()
+
+	clientCfg, err := ClientTLSConfig(file("client.crt"), file("client.key"), file("ca.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reply string
+	if err := RPCCall(addr, 1, clientCfg, "Test.Echo", "hello", &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply != "hello" {
+		t.Fatalf("got reply %q", reply)
+	}
+
+	// Streams use the same TLS config, fuzzers verify the fixed manager name.
+	serv.HandleStreams(func(s *Stream) {
+		defer s.Close()
+		if msg, err := s.Recv(); err == nil {
+			s.Send(msg)
+		}
+	})
+	fuzzerCfg := clientCfg.Clone()
+	fuzzerCfg.ServerName = ManagerTLSServerName
+	stream, err := DialStream(addr, 1, fuzzerCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	if err := stream.Send(&StreamMsg{Name: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := stream.Recv(); err != nil || msg.Name != "hello" {
+		t.Fatalf("got stream reply %+v, err %v", msg, err)
+	}
+
+	// Client with a certificate signed by an unknown CA.
+	otherCfg, err := ClientTLSConfig(file("other-client.crt"), file("other-client.key"), file("ca.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RPCCall(addr, 1, otherCfg, "Test.Echo", "hello", &reply); err == nil {
+		t.Fatalf("call with untrusted client certificate succeeded")
+	}
+	// Client that does not trust the server.
+	untrustingCfg, err := ClientTLSConfig(file("client.crt"), file("client.key"), file("other-ca.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRPCClient(addr, 1, untrustingCfg); err == nil {
+		t.Fatalf("connected to untrusted server")
+	}
+	// Client without TLS.
+	cli, err := NewRPCClient(addr, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	if err := cli.Call("Test.Echo", "hello", &reply); err == nil {
+		t.Fatalf("call without TLS succeeded")
+	}
+
+	if _, err := ServerTLSConfig(file("server.crt"), "", file("ca.crt")); err == nil {
+		t.Fatalf("loaded TLS config without key")
+	}
+}
+
+// genCert generates a key and a certificate signed by parent (self-signed CA if parent is nil)
+// and writes them to dir/name.crt and dir/name.key.
+func genCert(t *testing.T, dir, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (
+	*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:     []string{ManagerTLSServerName},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := osutil.WriteFile(certFile, certPEM); err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err := osutil.WriteFile(keyFile, keyPEM); err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
@@ -0,0 +1,23 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package stats
+
+import "math"
+
+// BinomialCI returns the 95% confidence interval for the probability of success
+// given the number of successes in a number of independent trials.
+// It uses the Wilson score interval, which behaves well for small number of trials
+// and for probabilities close to 0 and 1 (unlike the normal approximation).
+func BinomialCI(successes, trials int) (lower, upper float64) {
+	if trials <= 0 {
+		return 0, 1
+	}
+	const z = 1.96 // 95% confidence
+	n := float64(trials)
+	p := float64(successes) / n
+	denom := 1 + z*z/n
+	center := (p + z*z/(2*n)) / denom
+	margin := z * math.Sqrt(p*(1-p)/n+z*z/(4*n*n)) / denom
+	return math.Max(0, center-margin), math.Min(1, center+margin)
+}
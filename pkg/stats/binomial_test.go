@@ -0,0 +1,32 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBinomialCI(t *testing.T) {
+	tests := []struct {
+		successes int
+		trials    int
+		lower     float64
+		upper     float64
+	}{
+		{0, 0, 0, 1},
+		{0, 10, 0, 0.2775},
+		{10, 10, 0.7225, 1},
+		{5, 10, 0.2366, 0.7634},
+		{1, 5, 0.0362, 0.6245},
+		{95, 100, 0.8882, 0.9785},
+	}
+	for _, test := range tests {
+		lower, upper := BinomialCI(test.successes, test.trials)
+		if math.Abs(lower-test.lower) > 1e-4 || math.Abs(upper-test.upper) > 1e-4 {
+			t.Errorf("BinomialCI(%v, %v) = [%.4f, %.4f], want [%.4f, %.4f]",
+				test.successes, test.trials, lower, upper, test.lower, test.upper)
+		}
+	}
+}
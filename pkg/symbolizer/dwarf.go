@@ -0,0 +1,432 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package symbolizer
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The native symbolizer parses DWARF debug info in-process instead of running addr2line.
+// It produces the same frames as addr2line -afi: inlined frames come first, the file:line
+// of the innermost frame comes from the line table, file:line of outer frames come from
+// the call site attributes of the inlined subroutines.
+// Parsed binaries are cached across Symbolizer instances, compile units are parsed lazily.
+
+// Max number of binaries kept in the cache.
+const dwarfCacheSize = 4
+
+var dwarfCache struct {
+	mu   sync.Mutex
+	bins map[string]*dwarfBinary
+}
+
+type dwarfBinary struct {
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	used    time.Time
+	data    *dwarf.Data
+	units   []dwarfUnitRange // sorted by start address
+	symbols []elf.Symbol     // function symbols sorted by address
+	names   map[dwarf.Offset]string
+}
+
+type dwarfUnitRange struct {
+	start uint64
+	end   uint64
+	unit  *dwarfUnit
+}
+
+type dwarfUnit struct {
+	entry  *dwarf.Entry
+	parsed bool
+	files  []string     // absolute file names
+	lines  []dwarfLine  // sorted by address
+	funcs  []*dwarfFunc // out-of-line functions
+}
+
+type dwarfLine struct {
+	pc   uint64
+	end  bool // end of sequence (the row does not correspond to any code)
+	file int  // index in dwarfUnit.files
+	line int
+}
+
+type dwarfFunc struct {
+	name     string
+	ranges   [][2]uint64
+	callFile int // for inlined functions
+	callLine int
+	inlined  []*dwarfFunc
+}
+
+func (fn *dwarfFunc) contains(pc uint64) bool {
+	for _, r := range fn.ranges {
+		if pc >= r[0] && pc < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func symbolizeNative(bin string, pcs []uint64) ([]Frame, error) {
+	b, err := getDWARFBinary(bin)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var frames []Frame
+	for _, pc := range pcs {
+		frames1, err := b.symbolize(pc)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, frames1...)
+	}
+	return frames, nil
+}
+
+func getDWARFBinary(bin string) (*dwarfBinary, error) {
+	stat, err := os.Stat(bin)
+	if err != nil {
+		return nil, err
+	}
+	dwarfCache.mu.Lock()
+	defer dwarfCache.mu.Unlock()
+	if b := dwarfCache.bins[bin]; b != nil && b.modTime.Equal(stat.ModTime()) && b.size == stat.Size() {
+		b.used = time.Now()
+		return b, nil
+	}
+	b, err := parseDWARFBinary(bin)
+	if err != nil {
+		return nil, err
+	}
+	b.modTime, b.size, b.used = stat.ModTime(), stat.Size(), time.Now()
+	if dwarfCache.bins == nil {
+		dwarfCache.bins = make(map[string]*dwarfBinary)
+	}
+	dwarfCache.bins[bin] = b
+	for len(dwarfCache.bins) > dwarfCacheSize {
+		oldest := ""
+		for name, b1 := range dwarfCache.bins {
+			if oldest == "" || b1.used.Before(dwarfCache.bins[oldest].used) {
+				oldest = name
+			}
+		}
+		delete(dwarfCache.bins, oldest)
+	}
+	return b, nil
+}
+
+func parseDWARFBinary(bin string) (*dwarfBinary, error) {
+	file, err := elf.Open(bin)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := file.DWARF()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DWARF in %v: %v", bin, err)
+	}
+	b := &dwarfBinary{
+		data:  data,
+		names: make(map[dwarf.Offset]string),
+	}
+	symbols, err := file.Symbols()
+	if err != nil && err != elf.ErrNoSymbols {
+		return nil, fmt.Errorf("failed to read symbols in %v: %v", bin, err)
+	}
+	for _, sym := range symbols {
+		if elf.ST_TYPE(sym.Info) == elf.STT_FUNC {
+			b.symbols = append(b.symbols, sym)
+		}
+	}
+	sort.Slice(b.symbols, func(i, j int) bool {
+		return b.symbols[i].Value < b.symbols[j].Value
+	})
+	for r := data.Reader(); ; {
+		entry, err := r.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DWARF in %v: %v", bin, err)
+		}
+		if entry == nil {
+			break
+		}
+		if entry.Tag != dwarf.TagCompileUnit {
+			r.SkipChildren()
+			continue
+		}
+		ranges, err := data.Ranges(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DWARF in %v: %v", bin, err)
+		}
+		unit := &dwarfUnit{entry: entry}
+		for _, rng := range ranges {
+			b.units = append(b.units, dwarfUnitRange{rng[0], rng[1], unit})
+		}
+		r.SkipChildren()
+	}
+	sort.Slice(b.units, func(i, j int) bool {
+		return b.units[i].start < b.units[j].start
+	})
+	return b, nil
+}
+
+func (b *dwarfBinary) symbolize(pc uint64) ([]Frame, error) {
+	idx := sort.Search(len(b.units), func(i int) bool {
+		return b.units[i].start > pc
+	}) - 1
+	if idx < 0 || pc >= b.units[idx].end {
+		return nil, nil
+	}
+	unit := b.units[idx].unit
+	if err := b.parseUnit(unit); err != nil {
+		return nil, err
+	}
+	var stack []*dwarfFunc
+	for _, fn := range unit.funcs {
+		if fn.contains(pc) {
+			stack = append(stack, fn)
+			break
+		}
+	}
+	if len(stack) == 0 {
+		// Some code is not covered by any function in DWARF (e.g. padding between functions),
+		// like addr2line we take the name of the preceding function symbol in such case.
+		name := b.symbolName(pc)
+		if name == "" {
+			return nil, nil
+		}
+		stack = append(stack, &dwarfFunc{name: name})
+	}
+	for fns := stack[0].inlined; ; {
+		var next *dwarfFunc
+		for _, fn := range fns {
+			if fn.contains(pc) {
+				next = fn
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		stack = append(stack, next)
+		fns = next.inlined
+	}
+	file, line := unit.lookupLine(pc)
+	var frames []Frame
+	for i := len(stack) - 1; i >= 0; i-- {
+		fn := stack[i]
+		if fn.name != "" && file != "" && line > 0 {
+			frames = append(frames, Frame{
+				PC:     pc,
+				Func:   fn.name,
+				File:   file,
+				Line:   line,
+				Inline: i != 0,
+			})
+		}
+		file, line = unit.fileName(fn.callFile), fn.callLine
+	}
+	return frames, nil
+}
+
+func (b *dwarfBinary) symbolName(pc uint64) string {
+	idx := sort.Search(len(b.symbols), func(i int) bool {
+		return b.symbols[i].Value > pc
+	}) - 1
+	if idx < 0 {
+		return ""
+	}
+	return b.symbols[idx].Name
+}
+
+func (unit *dwarfUnit) lookupLine(pc uint64) (string, int) {
+	idx := sort.Search(len(unit.lines), func(i int) bool {
+		return unit.lines[i].pc > pc
+	}) - 1
+	if idx < 0 || unit.lines[idx].end {
+		return "", 0
+	}
+	return unit.fileName(unit.lines[idx].file), unit.lines[idx].line
+}
+
+func (unit *dwarfUnit) fileName(idx int) string {
+	if idx < 0 || idx >= len(unit.files) {
+		return ""
+	}
+	return unit.files[idx]
+}
+
+func (b *dwarfBinary) parseUnit(unit *dwarfUnit) error {
+	if unit.parsed {
+		return nil
+	}
+	unit.parsed = true
+	lr, err := b.data.LineReader(unit.entry)
+	if err != nil {
+		return err
+	}
+	if lr != nil {
+		var lineFiles []*dwarf.LineFile
+		for {
+			var entry dwarf.LineEntry
+			if err := lr.Next(&entry); err != nil {
+				break
+			}
+			// Note: Files must be called after reading the whole table because DWARF<5 line programs
+			// can define files along the way, so we remember the line file objects for now.
+			lineFiles = append(lineFiles, entry.File)
+			unit.lines = append(unit.lines, dwarfLine{
+				pc:   entry.Address,
+				end:  entry.EndSequence,
+				line: entry.Line,
+			})
+		}
+		files := lr.Files()
+		index := make(map[*dwarf.LineFile]int)
+		compDir, _ := unit.entry.Val(dwarf.AttrCompDir).(string)
+		for i, file := range files {
+			name := ""
+			if file != nil {
+				index[file] = i
+				name = file.Name
+				if !filepath.IsAbs(name) && compDir != "" {
+					name = filepath.Join(compDir, name)
+				}
+			}
+			unit.files = append(unit.files, name)
+		}
+		for i, file := range lineFiles {
+			unit.lines[i].file = -1
+			if idx, ok := index[file]; ok {
+				unit.lines[i].file = idx
+			}
+		}
+		// End of one sequence may have the same address as start of another sequence,
+		// so ends go first.
+		sort.SliceStable(unit.lines, func(i, j int) bool {
+			l1, l2 := unit.lines[i], unit.lines[j]
+			return l1.pc < l2.pc || l1.pc == l2.pc && l1.end && !l2.end
+		})
+	}
+	return b.parseFuncs(unit)
+}
+
+func (b *dwarfBinary) parseFuncs(unit *dwarfUnit) error {
+	r := b.data.Reader()
+	r.Seek(unit.entry.Offset)
+	if _, err := r.Next(); err != nil {
+		return err
+	}
+	if !unit.entry.Children {
+		return nil
+	}
+	// The nearest enclosing function for each nesting level (nil outside of functions).
+	stack := []*dwarfFunc{nil}
+	for len(stack) != 0 {
+		entry, err := r.Next()
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			break
+		}
+		if entry.Tag == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		parent := stack[len(stack)-1]
+		switch entry.Tag {
+		case dwarf.TagSubprogram, dwarf.TagInlinedSubroutine:
+			fn, err := b.parseFunc(entry)
+			if err != nil {
+				return err
+			}
+			if fn != nil {
+				if entry.Tag == dwarf.TagInlinedSubroutine && parent != nil {
+					parent.inlined = append(parent.inlined, fn)
+				} else if entry.Tag == dwarf.TagSubprogram {
+					unit.funcs = append(unit.funcs, fn)
+				}
+				parent = fn
+			}
+		}
+		if entry.Children {
+			stack = append(stack, parent)
+		}
+	}
+	return nil
+}
+
+func (b *dwarfBinary) parseFunc(entry *dwarf.Entry) (*dwarfFunc, error) {
+	ranges, err := b.data.Ranges(entry)
+	if err != nil {
+		return nil, err
+	}
+	if len(ranges) == 0 {
+		// Declaration or an abstract instance of an inline function.
+		return nil, nil
+	}
+	fn := &dwarfFunc{
+		name:   b.funcName(entry),
+		ranges: ranges,
+	}
+	if file, ok := entry.Val(dwarf.AttrCallFile).(int64); ok {
+		fn.callFile = int(file)
+	}
+	if line, ok := entry.Val(dwarf.AttrCallLine).(int64); ok {
+		fn.callLine = int(line)
+	}
+	return fn, nil
+}
+
+// funcName returns name of the function, following references to abstract instances
+// of inlined functions and to declarations.
+func (b *dwarfBinary) funcName(entry *dwarf.Entry) string {
+	var refs []dwarf.Offset
+	name := ""
+	for len(refs) < 10 {
+		// Like addr2line, we prefer mangled names.
+		if n, ok := entry.Val(dwarf.AttrLinkageName).(string); ok {
+			name = n
+			break
+		}
+		if n, ok := entry.Val(dwarf.AttrName).(string); ok {
+			name = n
+			break
+		}
+		ref, ok := entry.Val(dwarf.AttrAbstractOrigin).(dwarf.Offset)
+		if !ok {
+			ref, ok = entry.Val(dwarf.AttrSpecification).(dwarf.Offset)
+		}
+		if !ok {
+			break
+		}
+		if n, ok := b.names[ref]; ok {
+			name = n
+			break
+		}
+		refs = append(refs, ref)
+		r := b.data.Reader()
+		r.Seek(ref)
+		next, err := r.Next()
+		if err != nil || next == nil {
+			break
+		}
+		entry = next
+	}
+	for _, ref := range refs {
+		b.names[ref] = name
+	}
+	return name
+}
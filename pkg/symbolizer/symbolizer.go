@@ -21,6 +21,7 @@ import (
 
 type Symbolizer struct {
 	target   *targets.Target
+	native   bool
 	subprocs map[string]*subprocess
 }
 
@@ -40,15 +41,36 @@ type subprocess struct {
 	scanner *bufio.Scanner
 }
 
+// Supported symbolizer implementations (see Make).
+const (
+	Addr2Line = "addr2line" // runs addr2line from binutils
+	Native    = "native"    // parses DWARF in-process (see dwarf.go)
+)
+
 func NewSymbolizer(target *targets.Target) *Symbolizer {
 	return &Symbolizer{target: target}
 }
 
+// Make creates a symbolizer of the given kind, empty kind means Addr2Line.
+func Make(target *targets.Target, kind string) (*Symbolizer, error) {
+	switch kind {
+	case "", Addr2Line:
+		return NewSymbolizer(target), nil
+	case Native:
+		return &Symbolizer{target: target, native: true}, nil
+	default:
+		return nil, fmt.Errorf("unknown symbolizer %q", kind)
+	}
+}
+
 func (s *Symbolizer) Symbolize(bin string, pc uint64) ([]Frame, error) {
 	return s.SymbolizeArray(bin, []uint64{pc})
 }
 
 func (s *Symbolizer) SymbolizeArray(bin string, pcs []uint64) ([]Frame, error) {
+	if s.native {
+		return symbolizeNative(bin, pcs)
+	}
 	sub, err := s.getSubprocess(bin)
 	if err != nil {
 		return nil, err
@@ -7,9 +7,15 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"testing"
+	"time"
+
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/sys/targets"
 )
 
 func TestParse(t *testing.T) {
@@ -193,3 +199,87 @@ func TestParse(t *testing.T) {
 		t.Fatalf("want %v frames, got %v", want, len(frames))
 	}
 }
+
+const nativeTestSource = `
+#include <stdlib.h>
+
+static inline __attribute__((always_inline)) int inner(int x)
+{
+	if (x > 10)
+		abort();
+	return x * 3;
+}
+
+static inline __attribute__((always_inline)) int middle(int x)
+{
+	int y = inner(x + 1);
+	return y + inner(x - 1);
+}
+
+__attribute__((noinline)) int outer(int x)
+{
+	return middle(x) + middle(x * 2);
+}
+
+int main(int argc, char** argv)
+{
+	return outer(argc) + middle(argc);
+}
+`
+
+func TestNativeSymbolizer(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc is not available")
+	}
+	if _, err := exec.LookPath("addr2line"); err != nil {
+		t.Skip("addr2line is not available")
+	}
+	target := targets.Get(targets.Linux, targets.AMD64)
+	dir := t.TempDir()
+	src := filepath.Join(dir, "test.c")
+	bin := filepath.Join(dir, "test")
+	if err := osutil.WriteFile(src, []byte(nativeTestSource)); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := osutil.RunCmd(time.Minute, dir, "gcc", "-g", "-O2", "-o", bin, src); err != nil {
+		t.Fatalf("failed to build the test binary: %v\n%s", err, out)
+	}
+	addr2line := NewSymbolizer(target)
+	defer addr2line.Close()
+	native, err := Make(target, Native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	symbols, err := addr2line.ReadTextSymbols(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inlined := false
+	for _, name := range []string{"outer", "main"} {
+		if len(symbols[name]) != 1 {
+			t.Fatalf("no %v symbol in the binary", name)
+		}
+		sym := symbols[name][0]
+		var pcs []uint64
+		for pc := sym.Addr; pc < sym.Addr+uint64(sym.Size); pc++ {
+			pcs = append(pcs, pc)
+		}
+		want, err := addr2line.SymbolizeArray(bin, pcs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := native.SymbolizeArray(bin, pcs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("%v: native frames differ from addr2line:\nwant:\n%+v\ngot:\n%+v", name, want, got)
+		}
+		for _, frame := range got {
+			inlined = inlined || frame.Inline && frame.Func == "inner"
+		}
+	}
+	if !inlined {
+		t.Fatalf("no inlined frames")
+	}
+}
@@ -25,6 +25,44 @@ const maxBlobLen = uint64(100 << 10)
 // noMutate:    Set of IDs of syscalls which should not be mutated.
 // corpus:      The entire corpus, including original program p.
 func (p *Prog) Mutate(rs rand.Source, ncalls int, ct *ChoiceTable, noMutate map[int]bool, corpus []*Prog) {
+	p.MutateWithScheduler(rs, ncalls, ct, noMutate, corpus, nil)
+}
+
+// MutationOp identifies a single mutation operator applied by Mutate.
+type MutationOp int
+
+const (
+	MutateSquashAny MutationOp = iota
+	MutateSplice
+	MutateInsertCall
+	MutateArg
+	MutateRemoveCall
+	MutationOpCount
+)
+
+var mutationOpNames = [MutationOpCount]string{
+	MutateSquashAny:  "squashAny",
+	MutateSplice:     "splice",
+	MutateInsertCall: "insertCall",
+	MutateArg:        "mutateArg",
+	MutateRemoveCall: "removeCall",
+}
+
+func (op MutationOp) String() string {
+	return mutationOpNames[op]
+}
+
+// MutationScheduler decides which mutation operator to apply next.
+type MutationScheduler interface {
+	// ChooseOp returns the next operator to apply to p.
+	ChooseOp(r *rand.Rand, p *Prog) MutationOp
+}
+
+// MutateWithScheduler is the same as Mutate, but uses sched to choose mutation operators.
+// If sched is nil, the default fixed operator probabilities are used.
+// Returns the list of operators that were successfully applied to p.
+func (p *Prog) MutateWithScheduler(rs rand.Source, ncalls int, ct *ChoiceTable, noMutate map[int]bool,
+	corpus []*Prog, sched MutationScheduler) []MutationOp {
 	r := newRand(p.Target, rs)
 	if ncalls < len(p.Calls) {
 		ncalls = len(p.Calls)
@@ -37,20 +75,17 @@ func (p *Prog) Mutate(rs rand.Source, ncalls int, ct *ChoiceTable, noMutate map[
 		noMutate: noMutate,
 		corpus:   corpus,
 	}
+	var ops []MutationOp
 	for stop, ok := false, false; !stop; stop = ok && len(p.Calls) != 0 && r.oneOf(3) {
-		switch {
-		case r.oneOf(5):
-			// Not all calls have anything squashable,
-			// so this has lower priority in reality.
-			ok = ctx.squashAny()
-		case r.nOutOf(1, 100):
-			ok = ctx.splice()
-		case r.nOutOf(20, 31):
-			ok = ctx.insertCall()
-		case r.nOutOf(10, 11):
-			ok = ctx.mutateArg()
-		default:
-			ok = ctx.removeCall()
+		var op MutationOp
+		if sched != nil {
+			op = sched.ChooseOp(r.Rand, p)
+		} else {
+			op = r.chooseMutationOp()
+		}
+		ok = ctx.apply(op)
+		if ok {
+			ops = append(ops, op)
 		}
 	}
 	p.sanitizeFix()
@@ -58,6 +93,41 @@ func (p *Prog) Mutate(rs rand.Source, ncalls int, ct *ChoiceTable, noMutate map[
 	if got := len(p.Calls); got < 1 || got > ncalls {
 		panic(fmt.Sprintf("bad number of calls after mutation: %v, want [1, %v]", got, ncalls))
 	}
+	return ops
+}
+
+func (r *randGen) chooseMutationOp() MutationOp {
+	switch {
+	case r.oneOf(5):
+		// Not all calls have anything squashable,
+		// so this has lower priority in reality.
+		return MutateSquashAny
+	case r.nOutOf(1, 100):
+		return MutateSplice
+	case r.nOutOf(20, 31):
+		return MutateInsertCall
+	case r.nOutOf(10, 11):
+		return MutateArg
+	default:
+		return MutateRemoveCall
+	}
+}
+
+func (ctx *mutator) apply(op MutationOp) bool {
+	switch op {
+	case MutateSquashAny:
+		return ctx.squashAny()
+	case MutateSplice:
+		return ctx.splice()
+	case MutateInsertCall:
+		return ctx.insertCall()
+	case MutateArg:
+		return ctx.mutateArg()
+	case MutateRemoveCall:
+		return ctx.removeCall()
+	default:
+		panic(fmt.Sprintf("unknown mutation op %v", op))
+	}
 }
 
 // Internal state required for performing mutations -- currently this matches
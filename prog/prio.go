@@ -243,6 +243,32 @@ func (target *Target) BuildChoiceTable(corpus []*Prog, enabled map[*Syscall]bool
 	return &ChoiceTable{target, run, generatableCalls, noGenerateCalls}
 }
 
+// Deprioritize returns a copy of the table where priorities of choosing the given syscalls
+// are divided by div. Priorities don't drop to zero, so the syscalls are still chosen sometimes.
+func (ct *ChoiceTable) Deprioritize(calls map[int]bool, div int32) *ChoiceTable {
+	runs := make([][]int32, len(ct.runs))
+	for i, run := range ct.runs {
+		if run == nil {
+			continue
+		}
+		runs[i] = make([]int32, len(run))
+		var sum, prev int32
+		for j, v := range run {
+			prio := v - prev
+			prev = v
+			if calls[j] && prio > 0 {
+				prio /= div
+				if prio == 0 {
+					prio = 1
+				}
+			}
+			sum += prio
+			runs[i][j] = sum
+		}
+	}
+	return &ChoiceTable{ct.target, runs, ct.calls, ct.noGenerateCalls}
+}
+
 func (ct *ChoiceTable) Enabled(call int) bool {
 	return ct.Generatable(call) || ct.noGenerateCalls[call]
 }
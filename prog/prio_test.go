@@ -70,6 +70,32 @@ func TestStaticPriorities(t *testing.T) {
 	}
 }
 
+func TestDeprioritize(t *testing.T) {
+	target := initTargetTest(t, "linux", "amd64")
+	r := rand.New(rand.NewSource(0))
+	ct := target.DefaultChoiceTable()
+	open := target.SyscallMap["open"].ID
+	read := target.SyscallMap["read"].ID
+	ct1 := ct.Deprioritize(map[int]bool{read: true}, 10)
+	const iters = 1e5
+	count, count1 := 0, 0
+	for i := 0; i < iters; i++ {
+		if ct.choose(r, open) == read {
+			count++
+		}
+		if ct1.choose(r, open) == read {
+			count1++
+		}
+	}
+	t.Logf("read chosen after open %v times, deprioritized %v times", count, count1)
+	if count1 == 0 || count1*3 > count {
+		t.Fatalf("read is not deprioritized: chosen %v times, originally %v times", count1, count)
+	}
+	if !reflect.DeepEqual(ct.runs[open][:read], ct1.runs[open][:read]) {
+		t.Fatalf("priorities of other calls have changed")
+	}
+}
+
 func TestPrioDeterminism(t *testing.T) {
 	if testutil.RaceEnabled {
 		t.Skip("skipping in race mode, too slow")
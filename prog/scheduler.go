@@ -0,0 +1,125 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// BanditScheduler is a MutationScheduler that learns which mutation operators
+// produce new signal for which syscalls.
+//
+// For every syscall it tracks how many times each operator was applied to programs
+// containing the syscall and how many times the result gave new signal.
+// Operators are then chosen with probability proportional to the default
+// operator probability multiplied by the estimated success rate of the operator
+// for syscalls of the program being mutated. Without any feedback this degenerates
+// to the default operator probabilities used by Mutate.
+type BanditScheduler struct {
+	mu    sync.RWMutex
+	calls [][MutationOpCount]MutationOpStats
+	total [MutationOpCount]MutationOpStats
+}
+
+type MutationOpStats struct {
+	Applied   uint64 // number of executed programs the operator was applied to
+	NewSignal uint64 // number of such programs that gave new signal
+}
+
+// Default operator weights, these correspond to probabilities in randGen.chooseMutationOp.
+var defaultMutationOpWeights = [MutationOpCount]float64{
+	MutateSquashAny:  0.2,
+	MutateSplice:     0.8 * 0.01,
+	MutateInsertCall: 0.8 * 0.99 * 20 / 31,
+	MutateArg:        0.8 * 0.99 * 11 / 31 * 10 / 11,
+	MutateRemoveCall: 0.8 * 0.99 * 11 / 31 * 1 / 11,
+}
+
+func NewBanditScheduler(target *Target) *BanditScheduler {
+	return &BanditScheduler{
+		calls: make([][MutationOpCount]MutationOpStats, len(target.Syscalls)),
+	}
+}
+
+func (sched *BanditScheduler) ChooseOp(r *rand.Rand, p *Prog) MutationOp {
+	sched.mu.RLock()
+	var stats [MutationOpCount]MutationOpStats
+	for _, c := range p.Calls {
+		for op, st := range sched.calls[c.Meta.ID] {
+			stats[op].Applied += st.Applied
+			stats[op].NewSignal += st.NewSignal
+		}
+	}
+	sched.mu.RUnlock()
+	var weights [MutationOpCount]float64
+	sum := 0.0
+	for op := range weights {
+		// Mean of the Beta(1, 1) posterior, i.e. 0.5 for operators without feedback.
+		rate := float64(stats[op].NewSignal+1) / float64(stats[op].Applied+2)
+		sum += defaultMutationOpWeights[op] * rate
+		weights[op] = sum
+	}
+	v := r.Float64() * sum
+	for op, w := range weights {
+		if v < w {
+			return MutationOp(op)
+		}
+	}
+	return MutationOpCount - 1
+}
+
+// Feedback records the result of execution of program p after application of ops.
+func (sched *BanditScheduler) Feedback(p *Prog, ops []MutationOp, newSignal bool) {
+	if len(ops) == 0 {
+		return
+	}
+	// Count each operator and each syscall once per program.
+	var applied [MutationOpCount]bool
+	for _, op := range ops {
+		applied[op] = true
+	}
+	seen := make(map[int]bool)
+	sched.mu.Lock()
+	defer sched.mu.Unlock()
+	for op, ok := range applied {
+		if !ok {
+			continue
+		}
+		sched.total[op].Applied++
+		if newSignal {
+			sched.total[op].NewSignal++
+		}
+	}
+	for _, c := range p.Calls {
+		if seen[c.Meta.ID] {
+			continue
+		}
+		seen[c.Meta.ID] = true
+		stats := &sched.calls[c.Meta.ID]
+		for op, ok := range applied {
+			if !ok {
+				continue
+			}
+			stats[op].Applied++
+			if newSignal {
+				stats[op].NewSignal++
+			}
+		}
+	}
+}
+
+// Stats returns per-operator statistics aggregated over all syscalls.
+func (sched *BanditScheduler) Stats() [MutationOpCount]MutationOpStats {
+	sched.mu.RLock()
+	defer sched.mu.RUnlock()
+	return sched.total
+}
+
+// CallStats returns per-operator statistics for the syscall with the given ID.
+func (sched *BanditScheduler) CallStats(id int) [MutationOpCount]MutationOpStats {
+	sched.mu.RLock()
+	defer sched.mu.RUnlock()
+	return sched.calls[id]
+}
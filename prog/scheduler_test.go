@@ -0,0 +1,64 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestBanditSchedulerMutate(t *testing.T) {
+	target, rs, iters := initTest(t)
+	ct := target.DefaultChoiceTable()
+	sched := NewBanditScheduler(target)
+	r := rand.New(rs)
+	for i := 0; i < iters; i++ {
+		p := target.Generate(rs, 10, ct)
+		ops := p.MutateWithScheduler(rs, 10, ct, nil, nil, sched)
+		if len(ops) == 0 {
+			t.Fatalf("no mutation operators were applied")
+		}
+		sched.Feedback(p, ops, r.Intn(2) == 0)
+	}
+	total := uint64(0)
+	for _, st := range sched.Stats() {
+		if st.NewSignal > st.Applied {
+			t.Fatalf("bad stats: %+v", st)
+		}
+		total += st.Applied
+	}
+	if total == 0 {
+		t.Fatalf("no feedback recorded")
+	}
+}
+
+func TestBanditSchedulerLearns(t *testing.T) {
+	target, rs, _ := initTest(t)
+	ct := target.DefaultChoiceTable()
+	r := rand.New(rs)
+	p := target.Generate(rs, 5, ct)
+	count := func(sched *BanditScheduler) int {
+		n := 0
+		for i := 0; i < 10000; i++ {
+			if sched.ChooseOp(r, p) == MutateInsertCall {
+				n++
+			}
+		}
+		return n
+	}
+	sched := NewBanditScheduler(target)
+	before := count(sched)
+	for i := 0; i < 1000; i++ {
+		sched.Feedback(p, []MutationOp{MutateInsertCall}, false)
+		sched.Feedback(p, []MutationOp{MutateArg}, true)
+	}
+	after := count(sched)
+	if after*4 > before {
+		t.Fatalf("insertCall was not deprioritized: chosen %v times before feedback, %v after", before, after)
+	}
+	stats := sched.CallStats(p.Calls[0].Meta.ID)
+	if stats[MutateInsertCall].Applied != 1000 || stats[MutateArg].NewSignal != 1000 {
+		t.Fatalf("bad call stats: %+v", stats)
+	}
+}
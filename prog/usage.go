@@ -0,0 +1,135 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"fmt"
+)
+
+type DescElemKind int
+
+const (
+	DescField       DescElemKind = iota // struct field or syscall argument
+	DescUnionOption                     // union option
+	DescFlagsValue                      // flags value
+)
+
+// DescElem identifies an element of syscall descriptions that programs may or may not use.
+// Elements are identified by names rather than by types, so all instantiations
+// of a template are attributed to the same element.
+type DescElem struct {
+	Kind DescElemKind
+	// Template name of the struct/union, syscall name for syscall arguments,
+	// or name of the flags.
+	Type string
+	// Field/option name (empty for flags values).
+	Name string
+	// Flags value.
+	Val uint64
+}
+
+func (elem DescElem) String() string {
+	switch elem.Kind {
+	case DescFlagsValue:
+		return fmt.Sprintf("%v = 0x%x", elem.Type, elem.Val)
+	default:
+		return fmt.Sprintf("%v.%v", elem.Type, elem.Name)
+	}
+}
+
+// DescElems returns all input description elements reachable from the syscall arguments:
+// struct fields and syscall arguments that can be set by programs (i.e. not const, len, csum or padding),
+// union options and flags values. Elements reachable only in the out direction are ignored,
+// because programs don't control their values.
+func (meta *Syscall) DescElems() []DescElem {
+	var res []DescElem
+	dedup := make(map[DescElem]bool)
+	add := func(elem DescElem) {
+		if !dedup[elem] {
+			dedup[elem] = true
+			res = append(res, elem)
+		}
+	}
+	addFields := func(typ string, fields []Field, dir Dir) {
+		for i := range fields {
+			if field := &fields[i]; field.Dir(dir) != DirOut && settableField(field.Type) {
+				add(DescElem{Kind: DescField, Type: typ, Name: field.Name})
+			}
+		}
+	}
+	addFields(meta.Name, meta.Args, DirIn)
+	ForeachCallType(meta, func(typ Type, ctx *TypeCtx) {
+		if ctx.Dir == DirOut {
+			return
+		}
+		switch t := typ.(type) {
+		case *StructType:
+			addFields(t.TemplateName(), t.Fields, ctx.Dir)
+		case *UnionType:
+			for _, field := range t.Fields {
+				add(DescElem{Kind: DescUnionOption, Type: t.TemplateName(), Name: field.Name})
+			}
+		case *FlagsType:
+			for _, v := range t.Vals {
+				add(DescElem{Kind: DescFlagsValue, Type: t.TemplateName(), Val: v})
+			}
+		}
+	})
+	return res
+}
+
+func settableField(typ Type) bool {
+	switch typ.(type) {
+	case *ConstType, *LenType, *CsumType:
+		return false
+	}
+	return true
+}
+
+// UsedDescElems returns description elements used by the call: fields that have non-default values,
+// selected union options and flags values that are set.
+// If the program is minimized, these are the elements that affect coverage of the call.
+func (c *Call) UsedDescElems() map[DescElem]bool {
+	res := make(map[DescElem]bool)
+	addFields := func(typ string, fields []Field, args []Arg) {
+		for i, arg := range args {
+			if field := &fields[i]; arg.Dir() != DirOut && settableField(field.Type) && !isDefault(arg) {
+				res[DescElem{Kind: DescField, Type: typ, Name: field.Name}] = true
+			}
+		}
+	}
+	addFields(c.Meta.Name, c.Meta.Args, c.Args)
+	ForeachArg(c, func(arg Arg, ctx *ArgCtx) {
+		if arg.Dir() == DirOut {
+			return
+		}
+		switch a := arg.(type) {
+		case *GroupArg:
+			if t, ok := a.Type().(*StructType); ok {
+				addFields(t.TemplateName(), t.Fields, a.Inner)
+			}
+		case *UnionArg:
+			t := a.Type().(*UnionType)
+			res[DescElem{Kind: DescUnionOption, Type: t.TemplateName(), Name: t.Fields[a.Index].Name}] = true
+		case *ConstArg:
+			t, ok := a.Type().(*FlagsType)
+			if !ok {
+				return
+			}
+			for _, v := range t.Vals {
+				if flagsValueSet(t, a.Val, v) {
+					res[DescElem{Kind: DescFlagsValue, Type: t.TemplateName(), Val: v}] = true
+				}
+			}
+		}
+	})
+	return res
+}
+
+func flagsValueSet(t *FlagsType, arg, v uint64) bool {
+	if !t.BitMask || v == 0 {
+		return arg == v
+	}
+	return arg&v == v
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDescElems(t *testing.T) {
+	target := initTargetTest(t, "test", "64")
+	tests := []struct {
+		prog  string
+		elems []string
+		used  []string
+	}{
+		{
+			prog: `test$union0(&(0x7f0000000000)={0x0, @f2=0x1})`,
+			elems: []string{
+				"syz_union0.f0",
+				"syz_union0.f1",
+				"syz_union0.f2",
+				"syz_union0_struct.f",
+				"syz_union0_struct.u",
+				"test$union0.a0",
+			},
+			used: []string{
+				"syz_union0.f2",
+				"syz_union0_struct.u",
+				"test$union0.a0",
+			},
+		},
+		{
+			prog: `mutate_flags(&(0x7f0000000000)='./file0\x00', 0x0, 0x0, 0x9)`,
+			elems: []string{
+				"bitmask_flags = 0x1",
+				"bitmask_flags = 0x10",
+				"bitmask_flags = 0x8",
+				"mutate_flags.b1",
+				"mutate_flags.filename",
+				"mutate_flags.flags",
+				"mutate_flags.i1",
+			},
+			used: []string{
+				"bitmask_flags = 0x1",
+				"bitmask_flags = 0x8",
+				"mutate_flags.filename",
+				"mutate_flags.flags",
+			},
+		},
+	}
+	for i, test := range tests {
+		p, err := target.Deserialize([]byte(test.prog), Strict)
+		if err != nil {
+			t.Fatalf("#%v: failed to deserialize: %v", i, err)
+		}
+		c := p.Calls[0]
+		var elems, used []string
+		for _, elem := range c.Meta.DescElems() {
+			elems = append(elems, elem.String())
+		}
+		for elem := range c.UsedDescElems() {
+			used = append(used, elem.String())
+		}
+		sort.Strings(elems)
+		sort.Strings(used)
+		if diff := cmp.Diff(test.elems, elems); diff != "" {
+			t.Errorf("#%v: wrong elems:\n%v", i, diff)
+		}
+		if diff := cmp.Diff(test.used, used); diff != "" {
+			t.Errorf("#%v: wrong used elems:\n%v", i, diff)
+		}
+	}
+}
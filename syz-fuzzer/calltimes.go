@@ -0,0 +1,113 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/google/syzkaller/pkg/ipc"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/rpctype"
+	"github.com/google/syzkaller/prog"
+)
+
+const (
+	// Syscalls with fewer executions are never considered slow.
+	slowCallMinExecs = 20
+	// Priorities of slow syscalls are divided by this number (see prog.ChoiceTable.Deprioritize).
+	slowCallPrioDiv = 10
+	// How often the set of slow syscalls is updated.
+	slowCallsPeriod = 5 * time.Minute
+)
+
+// callTimes accumulates per-syscall execution time (see ipc.CallInfo.Duration).
+type callTimes struct {
+	mu    sync.Mutex
+	delta map[int]rpctype.CallTime // since the last poll, keyed by syscall ID
+	total map[int]rpctype.CallTime // since start
+}
+
+func newCallTimes() *callTimes {
+	return &callTimes{
+		delta: make(map[int]rpctype.CallTime),
+		total: make(map[int]rpctype.CallTime),
+	}
+}
+
+func (ct *callTimes) record(p *prog.Prog, info *ipc.ProgInfo) {
+	if info == nil {
+		return
+	}
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	for i, inf := range info.Calls {
+		if inf.Flags&ipc.CallExecuted == 0 {
+			continue
+		}
+		id := p.Calls[i].Meta.ID
+		delta, total := ct.delta[id], ct.total[id]
+		delta.Add(inf.Duration)
+		total.Add(inf.Duration)
+		ct.delta[id], ct.total[id] = delta, total
+	}
+}
+
+// grab returns execution times accumulated since the previous call keyed by syscall name.
+func (ct *callTimes) grab(target *prog.Target) map[string]rpctype.CallTime {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if len(ct.delta) == 0 {
+		return nil
+	}
+	res := make(map[string]rpctype.CallTime, len(ct.delta))
+	for id, t := range ct.delta {
+		res[target.Syscalls[id].Name] = t
+	}
+	ct.delta = make(map[int]rpctype.CallTime)
+	return res
+}
+
+// slowCalls returns syscalls that take at least threshold on average.
+func (ct *callTimes) slowCalls(threshold time.Duration) map[int]bool {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	res := make(map[int]bool)
+	for id, t := range ct.total {
+		if t.Execs >= slowCallMinExecs && t.Average() >= threshold {
+			res[id] = true
+		}
+	}
+	return res
+}
+
+// deprioritizeSlowCalls makes syscalls that are slower than the syscall timeout on average
+// less likely to be chosen. prev is the set of slow syscalls returned by the previous invocation.
+func (fuzzer *Fuzzer) deprioritizeSlowCalls(prev map[int]bool) map[int]bool {
+	slow := fuzzer.callTimes.slowCalls(fuzzer.timeouts.Syscall)
+	if reflect.DeepEqual(slow, prev) {
+		return prev
+	}
+	for id := range slow {
+		if !prev[id] {
+			log.Logf(0, "deprioritizing slow syscall %v", fuzzer.target.Syscalls[id].Name)
+		}
+	}
+	ct := fuzzer.choiceTable.Deprioritize(slow, slowCallPrioDiv)
+	fuzzer.ctMu.Lock()
+	fuzzer.adjustedChoiceTable = ct
+	fuzzer.ctMu.Unlock()
+	return slow
+}
+
+// currentChoiceTable returns the choice table to use for generation and mutation.
+func (fuzzer *Fuzzer) currentChoiceTable() *prog.ChoiceTable {
+	fuzzer.ctMu.RLock()
+	defer fuzzer.ctMu.RUnlock()
+	if fuzzer.adjustedChoiceTable != nil {
+		return fuzzer.adjustedChoiceTable
+	}
+	return fuzzer.choiceTable
+}
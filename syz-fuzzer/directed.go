@@ -0,0 +1,105 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/syzkaller/pkg/log"
+)
+
+const (
+	// Priorities of inputs are multiplied/divided by at most this number.
+	directedMaxFactor = 32
+	// Inputs are mutated at most this number of times in a row.
+	directedMaxEnergy = 8
+	// Time constant of the exploration phase: initially inputs are chosen regardless
+	// of their distance, after this time the distance dominates.
+	// Priorities are updated as the exploration phase progresses (see updateCorpusPrios).
+	directedExploreTime = time.Hour
+)
+
+// directedFuzzing biases the fuzzer towards inputs that are closer to the targets
+// of directed fuzzing (see mgrconfig.Config.Directed). Distances are computed by the manager
+// over the kernel call graph for each coverage PC (function-level, all PCs of a function
+// have the same distance), the distance of an input is the average distance of the covered PCs.
+// Closer inputs are chosen for mutation more frequently and are mutated more times in a row.
+// This follows the simulated annealing power schedule of AFLGo.
+type directedFuzzing struct {
+	distances   map[uint32]uint32 // coverage PC -> distance (see rpctype.ConnectRes.TargetDistances)
+	maxDistance uint32
+	start       time.Time
+}
+
+func newDirectedFuzzing(distances map[uint32]uint32) *directedFuzzing {
+	if len(distances) == 0 {
+		return nil
+	}
+	d := &directedFuzzing{
+		distances: distances,
+		start:     time.Now(),
+	}
+	targets := 0
+	for _, dist := range distances {
+		if d.maxDistance < dist {
+			d.maxDistance = dist
+		}
+		if dist == 0 {
+			targets++
+		}
+	}
+	log.Logf(0, "directed fuzzing: %v target PCs, %v PCs reach them", targets, len(distances))
+	return d
+}
+
+// inputDistance returns the average distance of the covered PCs to the targets.
+// If none of the PCs reach the targets, returns math.MaxUint32.
+func (d *directedFuzzing) inputDistance(cover []uint32) uint32 {
+	sum, n := uint64(0), uint64(0)
+	for _, pc := range cover {
+		if dist, ok := d.distances[pc]; ok {
+			sum += uint64(dist)
+			n++
+		}
+	}
+	if n == 0 {
+		return math.MaxUint32
+	}
+	return uint32(sum / n)
+}
+
+// powerFactor returns the factor in [1/directedMaxFactor, directedMaxFactor] for an input
+// with the given distance after the given time of fuzzing.
+func (d *directedFuzzing) powerFactor(distance uint32, elapsed time.Duration) float64 {
+	norm := 1.0
+	if distance < d.maxDistance {
+		norm = float64(distance) / float64(d.maxDistance)
+	}
+	// The temperature decreases exponentially from 1 to 0.05 during the exploration time.
+	temp := math.Pow(20, -float64(elapsed)/float64(directedExploreTime))
+	p := (1-norm)*(1-temp) + 0.5*temp
+	return math.Pow(2, 2*math.Log2(directedMaxFactor)*(p-0.5))
+}
+
+// prio adjusts signal-based priority of an input with the given distance.
+func (d *directedFuzzing) prio(prio int64, distance uint32) int64 {
+	res := int64(float64(prio) * d.powerFactor(distance, time.Since(d.start)) * directedMaxFactor)
+	if res < 1 {
+		res = 1
+	}
+	return res
+}
+
+// energy returns how many times in a row an input with the given distance is mutated.
+func (d *directedFuzzing) energy(distance uint32) int {
+	energy := int(d.powerFactor(distance, time.Since(d.start)))
+	if energy < 1 {
+		energy = 1
+	}
+	if energy > directedMaxEnergy {
+		energy = directedMaxEnergy
+	}
+	return energy
+}
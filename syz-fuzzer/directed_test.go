@@ -0,0 +1,58 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDirectedFuzzing(t *testing.T) {
+	d := newDirectedFuzzing(map[uint32]uint32{
+		0x10: 0,
+		0x20: 100,
+		0x30: 300,
+		0x40: 1000,
+	})
+	if d.maxDistance != 1000 {
+		t.Fatalf("max distance %v, want 1000", d.maxDistance)
+	}
+	if dist := d.inputDistance([]uint32{0x10, 0x30, 0x50}); dist != 150 {
+		t.Errorf("input distance %v, want 150", dist)
+	}
+	if dist := d.inputDistance([]uint32{0x50}); dist != math.MaxUint32 {
+		t.Errorf("input distance %v, want MaxUint32", dist)
+	}
+	// During the exploration phase distance does not matter.
+	for _, dist := range []uint32{0, 100, math.MaxUint32} {
+		if f := d.powerFactor(dist, 0); math.Abs(f-1) > 1e-9 {
+			t.Errorf("factor for distance %v at start is %v, want 1", dist, f)
+		}
+	}
+	// Later closer inputs are preferred.
+	for _, elapsed := range []time.Duration{time.Minute, directedExploreTime, 10 * directedExploreTime} {
+		prev := math.Inf(1)
+		for _, dist := range []uint32{0, 100, 300, 1000, math.MaxUint32} {
+			f := d.powerFactor(dist, elapsed)
+			if f > directedMaxFactor || f < 1.0/directedMaxFactor {
+				t.Errorf("factor %v is out of range", f)
+			}
+			if f > prev {
+				t.Errorf("factor for distance %v at %v is %v, higher than for a closer input %v", dist, elapsed, f, prev)
+			}
+			prev = f
+		}
+	}
+	late := 10 * directedExploreTime
+	if f := d.powerFactor(0, late); f < directedMaxFactor*0.9 {
+		t.Errorf("factor for target input is %v, want ~%v", f, directedMaxFactor)
+	}
+	if f := d.powerFactor(math.MaxUint32, late); f > 1.0/directedMaxFactor*1.1 {
+		t.Errorf("factor for unrelated input is %v, want ~%v", f, 1.0/directedMaxFactor)
+	}
+	if newDirectedFuzzing(nil) != nil {
+		t.Errorf("directed fuzzing is enabled without distances")
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/syzkaller/pkg/ipc"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/prog"
+)
+
+const forkBenchDuration = 2 * time.Second
+
+// checkForkTemplate disables the fork template mode (see ipc.FlagForkTemplate)
+// if it does not work on the machine. If bench is set, it also compares the execution speed
+// of the normal fork server mode and of the template mode on a simple program and logs the results.
+// The benchmark takes a while, so the manager asks for it only on the first fuzzer start in a VM.
+func (fuzzer *Fuzzer) checkForkTemplate(bench bool) {
+	p := fuzzer.target.DataMmapProg()
+	duration := time.Duration(0)
+	if bench {
+		duration = forkBenchDuration
+	}
+	template, err := benchmarkExec(fuzzer.config, fuzzer.execOpts, p, duration)
+	if err != nil {
+		log.Logf(0, "fork template mode does not work, disabling it: %v", err)
+		fuzzer.config.Flags &^= ipc.FlagForkTemplate
+		return
+	}
+	if !bench {
+		return
+	}
+	normalCfg := *fuzzer.config
+	normalCfg.Flags &^= ipc.FlagForkTemplate
+	normal, err := benchmarkExec(&normalCfg, fuzzer.execOpts, p, duration)
+	if err != nil {
+		log.Fatalf("fork server benchmark failed: %v", err)
+	}
+	secs := duration.Seconds()
+	gain := 0.0
+	if normal != 0 {
+		gain = (float64(template)/float64(normal) - 1) * 100
+	}
+	log.Logf(0, "fork template: %.1f exec/sec, fork server: %.1f exec/sec (%+.1f%%)",
+		float64(template)/secs, float64(normal)/secs, gain)
+}
+
+// benchmarkExec returns the number of times p was executed within the duration.
+func benchmarkExec(config *ipc.Config, opts *ipc.ExecOpts, p *prog.Prog, duration time.Duration) (uint64, error) {
+	env, err := ipc.MakeEnv(config, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer env.Close()
+	exec := func() error {
+		output, _, hanged, err := env.Exec(opts, p)
+		if err == nil && hanged {
+			err = fmt.Errorf("program hanged:\n%s", output)
+		}
+		return err
+	}
+	// The first execution starts the executor and is not counted.
+	if err := exec(); err != nil {
+		return 0, err
+	}
+	execs := uint64(0)
+	for start := time.Now(); time.Since(start) < duration; execs++ {
+		if err := exec(); err != nil {
+			return 0, err
+		}
+	}
+	return execs, nil
+}
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"math/rand"
@@ -41,24 +42,59 @@ type Fuzzer struct {
 	needPoll    chan struct{}
 	choiceTable *prog.ChoiceTable
 	noMutate    map[int]bool
+	// The choice table with deprioritized slow syscalls (see deprioritizeSlowCalls).
+	ctMu                sync.RWMutex
+	adjustedChoiceTable *prog.ChoiceTable
+	callTimes           *callTimes
+	deprioritizeSlow    bool
+	// mutationSched learns which mutation operators give new signal.
+	mutationSched *prog.BanditScheduler
 	// The stats field cannot unfortunately be just an uint64 array, because it
 	// results in "unaligned 64-bit atomic operation" errors on 32-bit platforms.
 	stats             []uint64
 	manager           *rpctype.RPCClient
+	stream            *rpctype.Stream // nil if the manager does not support streaming
+	managerTLS        *tls.Config     // nil if the manager does not use TLS
 	target            *prog.Target
 	triagedCandidates uint32
 	timeouts          targets.Timeouts
 
+	// Set while a stream request for candidates is not answered yet (cleared by streamLoop).
+	candidatesRequested uint32
+
+	// If non-zero, the fuzzer stops after executing this many programs,
+	// so that the manager can restore the VM snapshot (see mgrconfig.Config.SnapshotResets).
+	resetAfter   uint64
+	execs        uint64
+	procsStopped uint32
+
 	faultInjectionEnabled    bool
 	comparisonTracingEnabled bool
 	fetchRawCover            bool
 
+	// See mgrconfig.Config.FaultCampaign.
+	faultCampaign  bool
+	faultMu        sync.Mutex
+	knownFaults    map[string]*rpctype.FaultSweep // results of previous sweeps keyed by program hash
+	newFaultSweeps []rpctype.FaultSweep           // sweeps finished since the last poll
+
 	corpusMu     sync.RWMutex
 	corpus       []*prog.Prog
 	corpusHashes map[hash.Sig]struct{}
 	corpusPrios  []int64
 	sumPrios     int64
 
+	corpusBasePrios []int64       // signal-based priorities of corpus inputs
+	corpusStats     []*inputStats // mutation statistics of corpus inputs
+
+	// See schedule.go.
+	power         *powerSchedule // nil unless the power schedule is used
+	fuzzNewSignal uint64         // number of mutated programs that gave new signal
+
+	// See directed.go.
+	directed        *directedFuzzing // nil if directed fuzzing is disabled
+	corpusDistances []uint32         // distances of corpus inputs to directed fuzzing targets
+
 	signalMu     sync.RWMutex
 	corpusSignal signal.Signal // signal of inputs in corpus
 	maxSignal    signal.Signal // max signal ever observed including flakes
@@ -69,9 +105,11 @@ type Fuzzer struct {
 }
 
 type FuzzerSnapshot struct {
-	corpus      []*prog.Prog
-	corpusPrios []int64
-	sumPrios    int64
+	corpus          []*prog.Prog
+	corpusPrios     []int64
+	sumPrios        int64
+	corpusStats     []*inputStats
+	corpusDistances []uint32
 }
 
 type Stat int
@@ -87,6 +125,7 @@ const (
 	StatSeed
 	StatCollide
 	StatBufferTooSmall
+	StatFaultSweep
 	StatCount
 )
 
@@ -101,6 +140,7 @@ var statNames = [StatCount]string{
 	StatSeed:           "exec seeds",
 	StatCollide:        "exec collide",
 	StatBufferTooSmall: "buffer too small",
+	StatFaultSweep:     "exec fault sweep",
 }
 
 type OutputType int
@@ -156,10 +196,22 @@ func main() {
 		flagTest     = flag.Bool("test", false, "enable image testing mode")      // used by syz-ci
 		flagRunTest  = flag.Bool("runtest", false, "enable program testing mode") // used by pkg/runtest
 		flagRawCover = flag.Bool("raw_cover", false, "fetch raw coverage")
+		flagReset    = flag.Int("reset_after", 0, "exit after executing this many programs to reset VM state")
+		flagFaults   = flag.Bool("fault_campaign", false, "systematically inject faults into all corpus programs")
+		flagTemplate = flag.Bool("fork_template", false, "fork test processes from a pre-initialized template")
+		flagBench    = flag.Bool("fork_bench", false, "benchmark fork template mode against fork server on start")
+		flagSlow     = flag.Bool("deprioritize_slow", false, "generate pathologically slow syscalls less frequently")
+		flagSchedule = flag.String("schedule", scheduleSignal, "policy of choosing corpus inputs (signal, power)")
+		flagTLSCert  = flag.String("tls_cert", "", "fuzzer certificate for TLS connection to manager")
+		flagTLSKey   = flag.String("tls_key", "", "fuzzer private key for TLS connection to manager")
+		flagTLSCA    = flag.String("tls_ca", "", "CA certificate used to verify manager certificate")
 	)
 	defer tool.Init()()
 	outputType := parseOutputType(*flagOutput)
 	log.Logf(0, "fuzzer started")
+	if err := checkSchedule(*flagSchedule); err != nil {
+		log.Fatalf("%v", err)
+	}
 
 	target, err := prog.GetTarget(*flagOS, *flagArch)
 	if err != nil {
@@ -199,8 +251,15 @@ func main() {
 
 	machineInfo, modules := collectMachineInfos(target)
 
+	var tlsCfg *tls.Config
+	if *flagTLSCert != "" {
+		if tlsCfg, err = rpctype.ClientTLSConfig(*flagTLSCert, *flagTLSKey, *flagTLSCA); err != nil {
+			log.Fatalf("failed to load TLS config: %v", err)
+		}
+		tlsCfg.ServerName = rpctype.ManagerTLSServerName
+	}
 	log.Logf(0, "dialing manager at %v", *flagManager)
-	manager, err := rpctype.NewRPCClient(*flagManager, timeouts.Scale)
+	manager, err := rpctype.NewRPCClient(*flagManager, timeouts.Scale, tlsCfg)
 	if err != nil {
 		log.Fatalf("failed to create an RPC client: %v ", err)
 	}
@@ -255,6 +314,9 @@ func main() {
 		log.Logf(0, "%v: %v", feat.Name, feat.Reason)
 	}
 	createIPCConfig(r.CheckResult.Features, config)
+	if *flagTemplate {
+		config.Flags |= ipc.FlagForkTemplate
+	}
 
 	if *flagRunTest {
 		runTest(target, manager, *flagName, config.Executor)
@@ -271,6 +333,7 @@ func main() {
 		workQueue:                newWorkQueue(*flagProcs, needPoll),
 		needPoll:                 needPoll,
 		manager:                  manager,
+		managerTLS:               tlsCfg,
 		target:                   target,
 		timeouts:                 timeouts,
 		faultInjectionEnabled:    r.CheckResult.Features[host.FeatureFault].Enabled,
@@ -278,11 +341,24 @@ func main() {
 		corpusHashes:             make(map[hash.Sig]struct{}),
 		checkResult:              r.CheckResult,
 		fetchRawCover:            *flagRawCover,
+		resetAfter:               uint64(*flagReset),
+		faultCampaign:            *flagFaults && r.CheckResult.Features[host.FeatureFault].Enabled,
+		knownFaults:              make(map[string]*rpctype.FaultSweep),
 		noMutate:                 r.NoMutateCalls,
+		mutationSched:            prog.NewBanditScheduler(target),
+		callTimes:                newCallTimes(),
+		deprioritizeSlow:         *flagSlow,
 		stats:                    make([]uint64, StatCount),
+		directed:                 newDirectedFuzzing(r.TargetDistances),
+	}
+	if *flagSchedule == schedulePower {
+		fuzzer.power = newPowerSchedule()
 	}
 	gateCallback := fuzzer.useBugFrames(r, *flagProcs)
 	fuzzer.gate = ipc.NewGate(2**flagProcs, gateCallback)
+	if r.State != nil {
+		fuzzer.restoreState(r.State)
+	}
 
 	for needCandidates, more := true, true; more; needCandidates = false {
 		more = fuzzer.poll(needCandidates, nil)
@@ -290,6 +366,9 @@ func main() {
 		log.Logf(0, "fetching corpus: %v, signal %v/%v (executing program)",
 			len(fuzzer.corpus), len(fuzzer.corpusSignal), len(fuzzer.maxSignal))
 	}
+	if r.Stream && rpctype.CanDialStream(*flagManager) {
+		fuzzer.startStream(*flagManager)
+	}
 	calls := make(map[*prog.Syscall]bool)
 	for _, id := range r.CheckResult.EnabledCalls[sandbox] {
 		calls[target.Syscalls[id]] = true
@@ -300,6 +379,10 @@ func main() {
 		fuzzer.execOpts.Flags |= ipc.FlagEnableCoverageFilter
 	}
 
+	if fuzzer.config.Flags&ipc.FlagForkTemplate != 0 {
+		fuzzer.checkForkTemplate(*flagBench)
+	}
+
 	log.Logf(0, "starting %v fuzzer processes", *flagProcs)
 	for pid := 0; pid < *flagProcs; pid++ {
 		proc, err := newProc(fuzzer, pid)
@@ -377,8 +460,18 @@ func (fuzzer *Fuzzer) filterDataRaceFrames(frames []string) {
 
 func (fuzzer *Fuzzer) pollLoop() {
 	var execTotal uint64
+	var lastMutationStats [prog.MutationOpCount]prog.MutationOpStats
 	var lastPoll time.Time
 	var lastPrint time.Time
+	lastCheckpoint := time.Now()
+	lastSlowCheck := time.Now()
+	lastPrioUpdate := time.Now()
+	var slowCalls map[int]bool
+	pollPeriod := 10 * time.Second * fuzzer.timeouts.Scale
+	if fuzzer.stream != nil {
+		// Stream polls don't wait for replies, so send new signal and stats on every tick.
+		pollPeriod = 0
+	}
 	ticker := time.NewTicker(3 * time.Second * fuzzer.timeouts.Scale).C
 	for {
 		poll := false
@@ -387,13 +480,19 @@ func (fuzzer *Fuzzer) pollLoop() {
 		case <-fuzzer.needPoll:
 			poll = true
 		}
+		reset := fuzzer.resetAfter != 0 && int(atomic.LoadUint32(&fuzzer.procsStopped)) == len(fuzzer.procs)
 		if fuzzer.outputType != OutputStdout && time.Since(lastPrint) > 10*time.Second*fuzzer.timeouts.Scale {
 			// Keep-alive for manager.
 			log.Logf(0, "alive, executed %v", execTotal)
 			lastPrint = time.Now()
 		}
-		if poll || time.Since(lastPoll) > 10*time.Second*fuzzer.timeouts.Scale {
+		if poll || reset || time.Since(lastPoll) > pollPeriod {
 			needCandidates := fuzzer.workQueue.wantCandidates()
+			if needCandidates && fuzzer.stream != nil &&
+				!atomic.CompareAndSwapUint32(&fuzzer.candidatesRequested, 0, 1) {
+				// Stream polls are sent on every tick, don't ask again until the manager replies.
+				needCandidates = false
+			}
 			if poll && !needCandidates {
 				continue
 			}
@@ -407,24 +506,130 @@ func (fuzzer *Fuzzer) pollLoop() {
 				stats[statNames[stat]] = v
 				execTotal += v
 			}
+			mutationStats := fuzzer.mutationSched.Stats()
+			for op, st := range mutationStats {
+				last := lastMutationStats[op]
+				stats[fmt.Sprintf("mutate %v", prog.MutationOp(op))] = st.Applied - last.Applied
+				stats[fmt.Sprintf("mutate %v new signal", prog.MutationOp(op))] = st.NewSignal - last.NewSignal
+			}
+			lastMutationStats = mutationStats
+			stats["fuzz new signal"] = atomic.SwapUint64(&fuzzer.fuzzNewSignal, 0)
 			if !fuzzer.poll(needCandidates, stats) {
 				lastPoll = time.Now()
 			}
 		}
+		if reset {
+			// All procs are stopped, save the pending work and let the manager restore the VM.
+			fuzzer.checkpoint()
+			log.Logf(0, "executed %v programs, exiting to reset VM state", atomic.LoadUint64(&fuzzer.execs))
+			os.Exit(0)
+		}
+		if time.Since(lastCheckpoint) > time.Minute*fuzzer.timeouts.Scale {
+			fuzzer.checkpoint()
+			lastCheckpoint = time.Now()
+		}
+		if fuzzer.deprioritizeSlow && time.Since(lastSlowCheck) > slowCallsPeriod {
+			slowCalls = fuzzer.deprioritizeSlowCalls(slowCalls)
+			lastSlowCheck = time.Now()
+		}
+		if (fuzzer.power != nil || fuzzer.directed != nil) && time.Since(lastPrioUpdate) > corpusPrioUpdatePeriod {
+			fuzzer.updateCorpusPrios()
+			lastPrioUpdate = time.Now()
+		}
+	}
+}
+
+// needReset returns true if procs need to stop to let the manager reset VM state.
+func (fuzzer *Fuzzer) needReset() bool {
+	return fuzzer.resetAfter != 0 && atomic.LoadUint64(&fuzzer.execs) >= fuzzer.resetAfter
+}
+
+// checkpoint sends the pending work queue to the manager, so that it's not lost
+// if the VM crashes. The manager hands it to the next fuzzer on this instance.
+func (fuzzer *Fuzzer) checkpoint() {
+	a := &rpctype.CheckpointArgs{
+		Name:  fuzzer.name,
+		State: *fuzzer.workQueue.checkpoint(),
+	}
+	a.State.MaxSignal = fuzzer.grabNewSignal().Serialize()
+	if err := fuzzer.manager.Call("Manager.Checkpoint", a, nil); err != nil {
+		log.Fatalf("Manager.Checkpoint call failed: %v", err)
+	}
+}
+
+// restoreState enqueues work items checkpointed by the previous fuzzer.
+// Note: hint seeds and fault injection are part of smashing,
+// so they are restored with the smash items.
+func (fuzzer *Fuzzer) restoreState(state *rpctype.FuzzerState) {
+	for _, candidate := range state.Candidates {
+		fuzzer.addCandidateInput(candidate)
+	}
+	for _, item := range state.Triage {
+		p := fuzzer.deserializeInput(item.Prog)
+		if p == nil || item.CallID >= len(p.Calls) {
+			continue
+		}
+		fuzzer.workQueue.enqueue(&WorkTriage{
+			p:    p,
+			call: item.CallID,
+			info: ipc.CallInfo{
+				Signal: item.Signal,
+				Errno:  item.Errno,
+			},
+			flags: ProgTypes(item.Flags),
+		})
+	}
+	for _, item := range state.Smash {
+		p := fuzzer.deserializeInput(item.Prog)
+		if p == nil || item.CallID >= len(p.Calls) {
+			continue
+		}
+		fuzzer.workQueue.enqueue(&WorkSmash{p, item.CallID})
+	}
+	for _, item := range state.Faults {
+		p := fuzzer.deserializeInput(item.Prog)
+		if p == nil {
+			continue
+		}
+		fuzzer.workQueue.enqueue(&WorkFaultSweep{p, item.Faults})
 	}
+	log.Logf(0, "restored fuzzer state: candidates=%v triage=%v smash=%v faults=%v",
+		len(state.Candidates), len(state.Triage), len(state.Smash), len(state.Faults))
 }
 
 func (fuzzer *Fuzzer) poll(needCandidates bool, stats map[string]uint64) bool {
+	if stats == nil {
+		// Fault sweep stats are added below.
+		stats = make(map[string]uint64)
+	}
 	a := &rpctype.PollArgs{
 		Name:           fuzzer.name,
 		NeedCandidates: needCandidates,
 		MaxSignal:      fuzzer.grabNewSignal().Serialize(),
 		Stats:          stats,
+		FaultSweeps:    fuzzer.grabFaultSweeps(),
+		CallTimes:      fuzzer.callTimes.grab(fuzzer.target),
+	}
+	for name, v := range faultSweepStats(a.FaultSweeps) {
+		stats[name] += v
+	}
+	if fuzzer.stream != nil {
+		// The reply is handled by streamLoop.
+		if err := fuzzer.stream.Send(&rpctype.StreamMsg{Poll: a}); err != nil {
+			log.Fatalf("failed to send poll to manager stream: %v", err)
+		}
+		return false
 	}
 	r := &rpctype.PollRes{}
 	if err := fuzzer.manager.Call("Manager.Poll", a, r); err != nil {
 		log.Fatalf("Manager.Poll call failed: %v", err)
 	}
+	return fuzzer.handlePollRes(r, needCandidates)
+}
+
+// handlePollRes adds work received from the manager and returns true if there was any.
+// needCandidates says that r is a reply to a request for candidates.
+func (fuzzer *Fuzzer) handlePollRes(r *rpctype.PollRes, needCandidates bool) bool {
 	maxSignal := r.MaxSignal.Deserialize()
 	log.Logf(1, "poll: candidates=%v inputs=%v signal=%v",
 		len(r.Candidates), len(r.NewInputs), maxSignal.Len())
@@ -446,11 +651,47 @@ func (fuzzer *Fuzzer) sendInputToManager(inp rpctype.Input) {
 		Name:  fuzzer.name,
 		Input: inp,
 	}
+	if fuzzer.stream != nil {
+		if err := fuzzer.stream.Send(&rpctype.StreamMsg{NewInput: a}); err != nil {
+			log.Fatalf("failed to send input to manager stream: %v", err)
+		}
+		return
+	}
 	if err := fuzzer.manager.Call("Manager.NewInput", a, nil); err != nil {
 		log.Fatalf("Manager.NewInput call failed: %v", err)
 	}
 }
 
+// startStream switches communication with the manager from polling to a stream:
+// the manager pushes new max signal, inputs and candidates as soon as they appear,
+// and new inputs, signal and stats are sent without waiting for replies.
+func (fuzzer *Fuzzer) startStream(addr string) {
+	stream, err := rpctype.DialStream(addr, fuzzer.timeouts.Scale, fuzzer.managerTLS)
+	if err != nil {
+		log.Fatalf("failed to open manager stream: %v", err)
+	}
+	if err := stream.Send(&rpctype.StreamMsg{Name: fuzzer.name}); err != nil {
+		log.Fatalf("failed to send to manager stream: %v", err)
+	}
+	fuzzer.stream = stream
+	go fuzzer.streamLoop()
+}
+
+func (fuzzer *Fuzzer) streamLoop() {
+	for {
+		msg, err := fuzzer.stream.Recv()
+		if err != nil {
+			log.Fatalf("manager stream failed: %v", err)
+		}
+		if msg.Res != nil {
+			fuzzer.handlePollRes(msg.Res, msg.CandidatesReply)
+		}
+		if msg.CandidatesReply {
+			atomic.StoreUint32(&fuzzer.candidatesRequested, 0)
+		}
+	}
+}
+
 func (fuzzer *Fuzzer) addInputFromAnotherFuzzer(inp rpctype.Input) {
 	p := fuzzer.deserializeInput(inp.Prog)
 	if p == nil {
@@ -458,7 +699,12 @@ func (fuzzer *Fuzzer) addInputFromAnotherFuzzer(inp rpctype.Input) {
 	}
 	sig := hash.Hash(inp.Prog)
 	sign := inp.Signal.Deserialize()
-	fuzzer.addInputToCorpus(p, sign, sig)
+	distance := inp.Distance
+	if fuzzer.directed != nil && len(inp.Cover) != 0 {
+		// Inputs from the manager corpus have coverage, but no distance.
+		distance = fuzzer.directed.inputDistance(inp.Cover)
+	}
+	fuzzer.addInputToCorpus(p, sign, sig, distance)
 }
 
 func (fuzzer *Fuzzer) addCandidateInput(candidate rpctype.Candidate) {
@@ -473,12 +719,62 @@ func (fuzzer *Fuzzer) addCandidateInput(candidate rpctype.Candidate) {
 	if candidate.Smashed {
 		flags |= ProgSmashed
 	}
+	if candidate.Faults != nil && fuzzer.faultCampaign {
+		fuzzer.faultMu.Lock()
+		fuzzer.knownFaults[candidate.Faults.Sig] = candidate.Faults
+		fuzzer.faultMu.Unlock()
+	}
 	fuzzer.workQueue.enqueue(&WorkCandidate{
 		p:     p,
 		flags: flags,
 	})
 }
 
+// enqueueFaultSweep schedules fault injection sweep of a new corpus program,
+// unless the program was already swept completely.
+func (fuzzer *Fuzzer) enqueueFaultSweep(p *prog.Prog, sig hash.Sig) {
+	for _, c := range p.Calls {
+		if c.Props.FailNth != 0 {
+			// The program was found by a sweep of another program.
+			return
+		}
+	}
+	fuzzer.faultMu.Lock()
+	faults := fuzzer.knownFaults[sig.String()]
+	fuzzer.faultMu.Unlock()
+	if faults != nil && faults.Done {
+		return
+	}
+	fuzzer.workQueue.enqueue(&WorkFaultSweep{p, faults})
+}
+
+func (fuzzer *Fuzzer) addFaultSweep(sweep *rpctype.FaultSweep) {
+	fuzzer.faultMu.Lock()
+	defer fuzzer.faultMu.Unlock()
+	fuzzer.knownFaults[sweep.Sig] = sweep
+	fuzzer.newFaultSweeps = append(fuzzer.newFaultSweeps, *sweep)
+}
+
+func (fuzzer *Fuzzer) grabFaultSweeps() []rpctype.FaultSweep {
+	fuzzer.faultMu.Lock()
+	defer fuzzer.faultMu.Unlock()
+	sweeps := fuzzer.newFaultSweeps
+	fuzzer.newFaultSweeps = nil
+	return sweeps
+}
+
+func faultSweepStats(sweeps []rpctype.FaultSweep) map[string]uint64 {
+	stats := make(map[string]uint64)
+	for _, sweep := range sweeps {
+		stats["fault swept progs"]++
+		for _, call := range sweep.Calls {
+			stats["fault sites"] += uint64(call.Sites)
+			stats["fault sites new signal"] += uint64(len(call.NewSignal))
+		}
+	}
+	return stats
+}
+
 func (fuzzer *Fuzzer) deserializeInput(inp []byte) *prog.Prog {
 	p, err := fuzzer.target.Deserialize(inp, prog.NonStrict)
 	if err != nil {
@@ -515,14 +811,20 @@ func (fuzzer *Fuzzer) checkDisabledCalls(p *prog.Prog) {
 }
 
 func (fuzzer *FuzzerSnapshot) chooseProgram(r *rand.Rand) *prog.Prog {
+	return fuzzer.corpus[fuzzer.chooseIndex(r)]
+}
+
+// chooseIndex returns index of a random corpus input according to input priorities.
+func (fuzzer *FuzzerSnapshot) chooseIndex(r *rand.Rand) int {
 	randVal := r.Int63n(fuzzer.sumPrios + 1)
-	idx := sort.Search(len(fuzzer.corpusPrios), func(i int) bool {
+	return sort.Search(len(fuzzer.corpusPrios), func(i int) bool {
 		return fuzzer.corpusPrios[i] >= randVal
 	})
-	return fuzzer.corpus[idx]
 }
 
-func (fuzzer *Fuzzer) addInputToCorpus(p *prog.Prog, sign signal.Signal, sig hash.Sig) {
+// addInputToCorpus adds an input to corpus. distance is the distance of the input to
+// directed fuzzing targets (see directedFuzzing), it's ignored if directed fuzzing is disabled.
+func (fuzzer *Fuzzer) addInputToCorpus(p *prog.Prog, sign signal.Signal, sig hash.Sig, distance uint32) {
 	fuzzer.corpusMu.Lock()
 	if _, ok := fuzzer.corpusHashes[sig]; !ok {
 		fuzzer.corpus = append(fuzzer.corpus, p)
@@ -531,7 +833,13 @@ func (fuzzer *Fuzzer) addInputToCorpus(p *prog.Prog, sign signal.Signal, sig has
 		if sign.Empty() {
 			prio = 1
 		}
-		fuzzer.sumPrios += prio
+		fuzzer.corpusBasePrios = append(fuzzer.corpusBasePrios, prio)
+		fuzzer.corpusStats = append(fuzzer.corpusStats, new(inputStats))
+		fuzzer.corpusDistances = append(fuzzer.corpusDistances, distance)
+		if fuzzer.power != nil {
+			fuzzer.power.addInput(sign)
+		}
+		fuzzer.sumPrios += fuzzer.inputPrio(len(fuzzer.corpus) - 1)
 		fuzzer.corpusPrios = append(fuzzer.corpusPrios, fuzzer.sumPrios)
 	}
 	fuzzer.corpusMu.Unlock()
@@ -547,7 +855,8 @@ func (fuzzer *Fuzzer) addInputToCorpus(p *prog.Prog, sign signal.Signal, sig has
 func (fuzzer *Fuzzer) snapshot() FuzzerSnapshot {
 	fuzzer.corpusMu.RLock()
 	defer fuzzer.corpusMu.RUnlock()
-	return FuzzerSnapshot{fuzzer.corpus, fuzzer.corpusPrios, fuzzer.sumPrios}
+	return FuzzerSnapshot{fuzzer.corpus, fuzzer.corpusPrios, fuzzer.sumPrios,
+		fuzzer.corpusStats, fuzzer.corpusDistances}
 }
 
 func (fuzzer *Fuzzer) addMaxSignal(sign signal.Signal) {
@@ -4,11 +4,17 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
 	"math"
 	"math/rand"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/pkg/ipc"
+	"github.com/google/syzkaller/pkg/rpctype"
 	"github.com/google/syzkaller/pkg/signal"
 	"github.com/google/syzkaller/prog"
 	"github.com/google/syzkaller/sys/targets"
@@ -39,7 +45,7 @@ func TestChooseProgram(t *testing.T) {
 			sizeSig = 0
 		}
 		inp := generateInput(target, rs, 10, sizeSig)
-		fuzzer.addInputToCorpus(inp.p, inp.sign, inp.sig)
+		fuzzer.addInputToCorpus(inp.p, inp.sign, inp.sig, 0)
 		priorities[inp.p] = int64(len(inp.sign))
 	}
 	snapshot := fuzzer.snapshot()
@@ -56,6 +62,56 @@ func TestChooseProgram(t *testing.T) {
 	}
 }
 
+func TestPowerSchedule(t *testing.T) {
+	rs := rand.NewSource(0)
+	target := getTarget(t, targets.TestOS, targets.TestArch64)
+	fuzzer := &Fuzzer{
+		corpusHashes: make(map[hash.Sig]struct{}),
+		power:        newPowerSchedule(),
+	}
+	signals := [][]uint32{
+		{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+		{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+		{11, 12, 13, 14, 15},
+		{11},
+	}
+	for _, raw := range signals {
+		inp := generateInput(target, rs, 10, 0)
+		fuzzer.addInputToCorpus(inp.p, signal.FromRaw(raw, 0), inp.sig, 0)
+	}
+	prios := func() []int64 {
+		fuzzer.updateCorpusPrios()
+		var res []int64
+		prev := int64(0)
+		for _, sum := range fuzzer.corpusPrios {
+			res = append(res, sum-prev)
+			prev = sum
+		}
+		return res
+	}
+	// Shared signal is split between inputs.
+	if got, want := prios(), []int64{80, 80, 72, 8}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got prios %v, want %v", got, want)
+	}
+	// Unproductive inputs are chosen less frequently, productive inputs more frequently.
+	for i := 0; i < 100; i++ {
+		fuzzer.corpusStats[0].recordMutation(false)
+	}
+	for i := 0; i < 10; i++ {
+		fuzzer.corpusStats[1].recordMutation(true)
+	}
+	if got, want := prios(), []int64{7, 440, 72, 8}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got prios %v, want %v", got, want)
+	}
+	// The effect of old mutations decays.
+	for i := 0; i < 10; i++ {
+		prios()
+	}
+	if got, want := prios(), []int64{80, 80, 72, 8}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got prios %v, want %v", got, want)
+	}
+}
+
 func TestAddInputConcurrency(t *testing.T) {
 	target := getTarget(t, targets.TestOS, targets.TestArch64)
 	fuzzer := &Fuzzer{corpusHashes: make(map[hash.Sig]struct{})}
@@ -71,7 +127,7 @@ func TestAddInputConcurrency(t *testing.T) {
 			r := rand.New(rs)
 			for it := 0; it < iters; it++ {
 				inp := generateInput(target, rs, 10, it)
-				fuzzer.addInputToCorpus(inp.p, inp.sign, inp.sig)
+				fuzzer.addInputToCorpus(inp.p, inp.sign, inp.sig, 0)
 				snapshot := fuzzer.snapshot()
 				snapshot.chooseProgram(r).Clone()
 			}
@@ -79,6 +135,131 @@ func TestAddInputConcurrency(t *testing.T) {
 	}
 }
 
+func TestWorkQueueCheckpoint(t *testing.T) {
+	target := getTarget(t, targets.TestOS, targets.TestArch64)
+	rs := rand.NewSource(0)
+	wq := newWorkQueue(1, make(chan struct{}, 1))
+	wq.enqueue(&WorkTriage{
+		p:     generateInput(target, rs, 5, 0).p,
+		call:  1,
+		info:  ipc.CallInfo{Signal: []uint32{1, 2, 3}, Errno: 22},
+		flags: ProgCandidate | ProgMinimized,
+	})
+	wq.enqueue(&WorkTriage{
+		p:    generateInput(target, rs, 5, 0).p,
+		call: -1,
+		info: ipc.CallInfo{Signal: []uint32{4}},
+	})
+	wq.enqueue(&WorkCandidate{p: generateInput(target, rs, 5, 0).p, flags: ProgCandidate | ProgSmashed})
+	wq.enqueue(&WorkSmash{p: generateInput(target, rs, 5, 0).p, call: 2})
+	wq.enqueue(&WorkFaultSweep{p: generateInput(target, rs, 5, 0).p})
+	wq.enqueue(&WorkFaultSweep{
+		p: generateInput(target, rs, 5, 0).p,
+		faults: &rpctype.FaultSweep{
+			Sig:   "sig",
+			Calls: []rpctype.FaultCall{{Call: 1, Sites: 3, Crashed: []int{2}}},
+		},
+	})
+
+	fuzzer := &Fuzzer{target: target, workQueue: newWorkQueue(1, make(chan struct{}, 1))}
+	fuzzer.restoreState(wq.checkpoint())
+	for {
+		item0, item1 := wq.dequeue(), fuzzer.workQueue.dequeue()
+		if item0 == nil || item1 == nil {
+			if item0 != item1 {
+				t.Fatalf("different number of items: %#v vs %#v", item0, item1)
+			}
+			break
+		}
+		switch item0 := item0.(type) {
+		case *WorkTriage:
+			item1 := item1.(*WorkTriage)
+			if item0.call != item1.call || item0.flags != item1.flags ||
+				item0.info.Errno != item1.info.Errno ||
+				!reflect.DeepEqual(item0.info.Signal, item1.info.Signal) ||
+				!bytes.Equal(item0.p.Serialize(), item1.p.Serialize()) {
+				t.Fatalf("triage items differ:\n%#v\n%#v", item0, item1)
+			}
+		case *WorkCandidate:
+			item1 := item1.(*WorkCandidate)
+			if item0.flags != item1.flags || !bytes.Equal(item0.p.Serialize(), item1.p.Serialize()) {
+				t.Fatalf("candidate items differ:\n%#v\n%#v", item0, item1)
+			}
+		case *WorkSmash:
+			item1 := item1.(*WorkSmash)
+			if item0.call != item1.call || !bytes.Equal(item0.p.Serialize(), item1.p.Serialize()) {
+				t.Fatalf("smash items differ:\n%#v\n%#v", item0, item1)
+			}
+		case *WorkFaultSweep:
+			item1 := item1.(*WorkFaultSweep)
+			if !reflect.DeepEqual(item0.faults, item1.faults) || !bytes.Equal(item0.p.Serialize(), item1.p.Serialize()) {
+				t.Fatalf("fault sweep items differ:\n%#v\n%#v", item0, item1)
+			}
+		}
+	}
+}
+
+func TestEnqueueFaultSweep(t *testing.T) {
+	target := getTarget(t, targets.TestOS, targets.TestArch64)
+	rs := rand.NewSource(0)
+	fuzzer := &Fuzzer{
+		target:        target,
+		workQueue:     newWorkQueue(1, make(chan struct{}, 1)),
+		faultCampaign: true,
+		knownFaults:   make(map[string]*rpctype.FaultSweep),
+	}
+	swept := generateInput(target, rs, 5, 0)
+	partial := generateInput(target, rs, 5, 0)
+	fresh := generateInput(target, rs, 5, 0)
+	partialFaults := &rpctype.FaultSweep{
+		Sig:   partial.sig.String(),
+		Calls: []rpctype.FaultCall{{Call: 0, Crashed: []int{1}}},
+	}
+	fuzzer.addCandidateInput(rpctype.Candidate{
+		Prog:   swept.p.Serialize(),
+		Faults: &rpctype.FaultSweep{Sig: swept.sig.String(), Done: true},
+	})
+	fuzzer.addCandidateInput(rpctype.Candidate{
+		Prog:   partial.p.Serialize(),
+		Faults: partialFaults,
+	})
+	for fuzzer.workQueue.dequeue() != nil {
+	}
+	faulty := generateInput(target, rs, 5, 0)
+	faulty.p.Calls[0].Props.FailNth = 1
+	for _, inp := range []InputTest{swept, partial, fresh, faulty} {
+		fuzzer.enqueueFaultSweep(inp.p, inp.sig)
+	}
+	var sweeps []*WorkFaultSweep
+	for item := fuzzer.workQueue.dequeue(); item != nil; item = fuzzer.workQueue.dequeue() {
+		sweeps = append(sweeps, item.(*WorkFaultSweep))
+	}
+	if len(sweeps) != 2 || sweeps[0].p != fresh.p || sweeps[0].faults != nil ||
+		sweeps[1].p != partial.p || sweeps[1].faults != partialFaults {
+		t.Fatalf("wrong fault sweeps enqueued: %+v", sweeps)
+	}
+	fuzzer.addFaultSweep(&rpctype.FaultSweep{
+		Sig:  fresh.sig.String(),
+		Done: true,
+		Calls: []rpctype.FaultCall{
+			{Call: 0, Sites: 5, NewSignal: []int{1, 3}},
+			{Call: 2, Sites: 2, NewSignal: []int{2}},
+		},
+	})
+	stats := faultSweepStats(fuzzer.grabFaultSweeps())
+	want := map[string]uint64{"fault swept progs": 1, "fault sites": 7, "fault sites new signal": 3}
+	if !reflect.DeepEqual(stats, want) {
+		t.Fatalf("got stats %v, want %v", stats, want)
+	}
+	if len(fuzzer.grabFaultSweeps()) != 0 {
+		t.Fatalf("fault sweeps were not consumed")
+	}
+	fuzzer.enqueueFaultSweep(fresh.p, fresh.sig)
+	if item := fuzzer.workQueue.dequeue(); item != nil {
+		t.Fatalf("swept program was enqueued again: %#v", item)
+	}
+}
+
 func generateInput(target *prog.Target, rs rand.Source, ncalls, sizeSig int) (inp InputTest) {
 	inp.p = target.Generate(rs, ncalls, target.DefaultChoiceTable())
 	var raw []uint32
@@ -98,3 +279,59 @@ func getTarget(t *testing.T, os, arch string) *prog.Target {
 	}
 	return target
 }
+
+func TestCallTimes(t *testing.T) {
+	target := getTarget(t, targets.TestOS, targets.TestArch64)
+	p, err := target.Deserialize([]byte("test$res0()\ntest$int(0x1, 0x2, 0x3, 0x4, 0x5)\n"), prog.Strict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := newCallTimes()
+	for i := 0; i < slowCallMinExecs; i++ {
+		ct.record(p, &ipc.ProgInfo{Calls: []ipc.CallInfo{
+			{Flags: ipc.CallExecuted | ipc.CallFinished, Duration: time.Microsecond},
+			{Flags: ipc.CallExecuted, Duration: time.Duration(i) * time.Second},
+		}})
+		if i == 0 {
+			// Not executed calls are not accounted.
+			ct.record(p, &ipc.ProgInfo{Calls: make([]ipc.CallInfo, 2)})
+			ct.record(p, nil)
+		}
+	}
+	got := ct.grab(target)
+	want := map[string]rpctype.CallTime{
+		"test$res0": {Execs: slowCallMinExecs, Total: slowCallMinExecs * time.Microsecond, Max: time.Microsecond},
+		"test$int":  {Execs: slowCallMinExecs, Total: 190 * time.Second, Max: 19 * time.Second},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if got := ct.grab(target); got != nil {
+		t.Fatalf("second grab returned %+v", got)
+	}
+	slow := ct.slowCalls(time.Second)
+	if !reflect.DeepEqual(slow, map[int]bool{target.SyscallMap["test$int"].ID: true}) {
+		t.Fatalf("bad slow calls: %v", slow)
+	}
+}
+
+func TestBatchProgramLog(t *testing.T) {
+	target := getTarget(t, targets.TestOS, targets.TestArch64)
+	var progs []*prog.Prog
+	for i := 0; i < 2; i++ {
+		p, err := target.Deserialize([]byte("r0 = test$res0()\ntest$res1(r0)\n"), prog.Strict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		progs = append(progs, p)
+	}
+	log := fmt.Sprintf("executing program 3 (batch of 2 programs):\n%s\n", batchProgram(progs).Serialize())
+	entries := target.ParseLog([]byte(log))
+	if len(entries) != 1 || entries[0].Proc != 3 {
+		t.Fatalf("batch is not parsed as a single program:\n%s", log)
+	}
+	want := "r0 = test$res0()\ntest$res1(r0)\nr1 = test$res0()\ntest$res1(r1)\n"
+	if got := string(entries[0].P.Serialize()); got != want {
+		t.Fatalf("got program:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -67,6 +67,13 @@ func (proc *Proc) loop() {
 		generatePeriod = 2
 	}
 	for i := 0; ; i++ {
+		if proc.fuzzer.needReset() {
+			// The VM state is going to be restored, stop after finishing the current work item
+			// so that the remaining work items are checkpointed.
+			log.Logf(1, "#%v: stopping for VM reset", proc.pid)
+			atomic.AddUint32(&proc.fuzzer.procsStopped, 1)
+			return
+		}
 		item := proc.fuzzer.workQueue.dequeue()
 		if item != nil {
 			switch item := item.(type) {
@@ -76,13 +83,15 @@ func (proc *Proc) loop() {
 				proc.execute(proc.execOpts, item.p, item.flags, StatCandidate)
 			case *WorkSmash:
 				proc.smashInput(item)
+			case *WorkFaultSweep:
+				proc.sweepFaults(item)
 			default:
 				log.Fatalf("unknown work type: %#v", item)
 			}
 			continue
 		}
 
-		ct := proc.fuzzer.choiceTable
+		ct := proc.fuzzer.currentChoiceTable()
 		fuzzerSnapshot := proc.fuzzer.snapshot()
 		if len(fuzzerSnapshot.corpus) == 0 || i%generatePeriod == 0 {
 			// Generate a new prog.
@@ -91,10 +100,23 @@ func (proc *Proc) loop() {
 			proc.executeAndCollide(proc.execOpts, p, ProgNormal, StatGenerate)
 		} else {
 			// Mutate an existing prog.
-			p := fuzzerSnapshot.chooseProgram(proc.rnd).Clone()
-			p.Mutate(proc.rnd, prog.RecommendedCalls, ct, proc.fuzzer.noMutate, fuzzerSnapshot.corpus)
-			log.Logf(1, "#%v: mutated", proc.pid)
-			proc.executeAndCollide(proc.execOpts, p, ProgNormal, StatFuzz)
+			idx := fuzzerSnapshot.chooseIndex(proc.rnd)
+			energy := 1
+			if proc.fuzzer.directed != nil {
+				energy = proc.fuzzer.directed.energy(fuzzerSnapshot.corpusDistances[idx])
+			}
+			for n := 0; n < energy; n++ {
+				p := fuzzerSnapshot.corpus[idx].Clone()
+				ops := p.MutateWithScheduler(proc.rnd, prog.RecommendedCalls, ct, proc.fuzzer.noMutate,
+					fuzzerSnapshot.corpus, proc.fuzzer.mutationSched)
+				log.Logf(1, "#%v: mutated", proc.pid)
+				newSignal := proc.executeAndCollide(proc.execOpts, p, ProgNormal, StatFuzz)
+				proc.fuzzer.mutationSched.Feedback(p, ops, newSignal)
+				fuzzerSnapshot.corpusStats[idx].recordMutation(newSignal)
+				if newSignal {
+					atomic.AddUint64(&proc.fuzzer.fuzzNewSignal, 1)
+				}
+			}
 		}
 	}
 }
@@ -165,6 +187,10 @@ func (proc *Proc) triageInput(item *WorkTriage) {
 
 	data := item.p.Serialize()
 	sig := hash.Hash(data)
+	var distance uint32
+	if proc.fuzzer.directed != nil {
+		distance = proc.fuzzer.directed.inputDistance(inputCover.Serialize())
+	}
 
 	log.Logf(2, "added new input for %v to corpus:\n%s", logCallName, data)
 	proc.fuzzer.sendInputToManager(rpctype.Input{
@@ -174,9 +200,14 @@ func (proc *Proc) triageInput(item *WorkTriage) {
 		Signal:   inputSignal.Serialize(),
 		Cover:    inputCover.Serialize(),
 		RawCover: rawCover,
+		Distance: distance,
 	})
 
-	proc.fuzzer.addInputToCorpus(item.p, inputSignal, sig)
+	proc.fuzzer.addInputToCorpus(item.p, inputSignal, sig, distance)
+
+	if proc.fuzzer.faultCampaign {
+		proc.fuzzer.enqueueFaultSweep(item.p, sig)
+	}
 
 	if item.flags&ProgSmashed == 0 {
 		proc.fuzzer.workQueue.enqueue(&WorkSmash{item.p, item.call})
@@ -207,7 +238,8 @@ func getSignalAndCover(p *prog.Prog, info *ipc.ProgInfo, call int) (signal.Signa
 }
 
 func (proc *Proc) smashInput(item *WorkSmash) {
-	if proc.fuzzer.faultInjectionEnabled && item.call != -1 {
+	// In the fault campaign mode all calls are swept separately.
+	if proc.fuzzer.faultInjectionEnabled && !proc.fuzzer.faultCampaign && item.call != -1 {
 		proc.failCall(item.p, item.call)
 	}
 	if proc.fuzzer.comparisonTracingEnabled && item.call != -1 {
@@ -216,9 +248,11 @@ func (proc *Proc) smashInput(item *WorkSmash) {
 	fuzzerSnapshot := proc.fuzzer.snapshot()
 	for i := 0; i < 100; i++ {
 		p := item.p.Clone()
-		p.Mutate(proc.rnd, prog.RecommendedCalls, proc.fuzzer.choiceTable, proc.fuzzer.noMutate, fuzzerSnapshot.corpus)
+		ops := p.MutateWithScheduler(proc.rnd, prog.RecommendedCalls, proc.fuzzer.currentChoiceTable(),
+			proc.fuzzer.noMutate, fuzzerSnapshot.corpus, proc.fuzzer.mutationSched)
 		log.Logf(1, "#%v: smash mutated", proc.pid)
-		proc.executeAndCollide(proc.execOpts, p, ProgNormal, StatSmash)
+		newSignal := proc.executeAndCollide(proc.execOpts, p, ProgNormal, StatSmash)
+		proc.fuzzer.mutationSched.Feedback(p, ops, newSignal)
 	}
 }
 
@@ -234,6 +268,59 @@ func (proc *Proc) failCall(p *prog.Prog, call int) {
 	}
 }
 
+// sweepFaults injects faults into all fault sites of all calls of the program.
+// Fault sites that gave new signal are triaged as usual. Fault sites that crashed
+// the kernel during previous sweeps are skipped.
+func (proc *Proc) sweepFaults(item *WorkFaultSweep) {
+	sweep := &rpctype.FaultSweep{
+		Sig:  hash.String(item.p.Serialize()),
+		Done: true,
+	}
+	for call := range item.p.Calls {
+		res := rpctype.FaultCall{Call: call}
+		if item.faults != nil {
+			for _, known := range item.faults.Calls {
+				if known.Call == call {
+					res.Crashed = known.Crashed
+				}
+			}
+		}
+		for nth := 1; nth <= 100; nth++ {
+			if faultSiteCrashed(res.Crashed, nth) {
+				res.Sites = nth
+				continue
+			}
+			log.Logf(1, "#%v: sweeping fault into call %v/%v", proc.pid, call, nth)
+			newProg := item.p.Clone()
+			newProg.Calls[call].Props.FailNth = nth
+			info := proc.executeRaw(proc.execOpts, newProg, StatFaultSweep)
+			if info == nil || len(info.Calls) <= call {
+				continue
+			}
+			if info.Calls[call].Flags&ipc.CallFaultInjected == 0 {
+				break
+			}
+			res.Sites = nth
+			if proc.triageNewSignal(newProg, ProgNormal, info) {
+				res.NewSignal = append(res.NewSignal, nth)
+			}
+		}
+		if res.Sites != 0 {
+			sweep.Calls = append(sweep.Calls, res)
+		}
+	}
+	proc.fuzzer.addFaultSweep(sweep)
+}
+
+func faultSiteCrashed(crashed []int, nth int) bool {
+	for _, v := range crashed {
+		if v == nth {
+			return true
+		}
+	}
+	return false
+}
+
 func (proc *Proc) executeHintSeed(p *prog.Prog, call int) {
 	log.Logf(1, "#%v: collecting comparisons", proc.pid)
 	// First execute the original program to dump comparisons from KCOV.
@@ -245,10 +332,22 @@ func (proc *Proc) executeHintSeed(p *prog.Prog, call int) {
 	// Then mutate the initial program for every match between
 	// a syscall argument and a comparison operand.
 	// Execute each of such mutants to check if it gives new coverage.
+	// There are usually lots of mutants, so execute them in batches.
+	const batchSize = 16
+	var batch []*prog.Prog
 	p.MutateWithHints(call, info.Calls[call].Comps, func(p *prog.Prog) {
-		log.Logf(1, "#%v: executing comparison hint", proc.pid)
-		proc.execute(proc.execOpts, p, ProgNormal, StatHint)
+		// The same p is mutated for the next hint, so it needs to be copied.
+		batch = append(batch, p.Clone())
+		if len(batch) == batchSize {
+			log.Logf(1, "#%v: executing %v comparison hints", proc.pid, len(batch))
+			proc.executeBatch(proc.execOpts, batch, ProgNormal, StatHint)
+			batch = nil
+		}
 	})
+	if len(batch) != 0 {
+		log.Logf(1, "#%v: executing %v comparison hints", proc.pid, len(batch))
+		proc.executeBatch(proc.execOpts, batch, ProgNormal, StatHint)
+	}
 }
 
 func (proc *Proc) execute(execOpts *ipc.ExecOpts, p *prog.Prog, flags ProgTypes, stat Stat) *ipc.ProgInfo {
@@ -256,6 +355,23 @@ func (proc *Proc) execute(execOpts *ipc.ExecOpts, p *prog.Prog, flags ProgTypes,
 	if info == nil {
 		return nil
 	}
+	proc.triageNewSignal(p, flags, info)
+	return info
+}
+
+// executeBatch is like execute, but executes several programs in a single executor round-trip.
+func (proc *Proc) executeBatch(execOpts *ipc.ExecOpts, progs []*prog.Prog, flags ProgTypes, stat Stat) {
+	infos := proc.executeRawBatch(execOpts, progs, stat)
+	for i, info := range infos {
+		if info != nil {
+			proc.triageNewSignal(progs[i], flags, info)
+		}
+	}
+}
+
+// triageNewSignal enqueues triage of calls that gave new signal.
+// Returns true if there was any new signal.
+func (proc *Proc) triageNewSignal(p *prog.Prog, flags ProgTypes, info *ipc.ProgInfo) bool {
 	calls, extra := proc.fuzzer.checkNewSignal(p, info)
 	for _, callIndex := range calls {
 		proc.enqueueCallTriage(p, flags, callIndex, info.Calls[callIndex])
@@ -263,7 +379,7 @@ func (proc *Proc) execute(execOpts *ipc.ExecOpts, p *prog.Prog, flags ProgTypes,
 	if extra {
 		proc.enqueueCallTriage(p, flags, -1, info.Extra)
 	}
-	return info
+	return len(calls) != 0 || extra
 }
 
 func (proc *Proc) enqueueCallTriage(p *prog.Prog, flags ProgTypes, callIndex int, info ipc.CallInfo) {
@@ -280,17 +396,20 @@ func (proc *Proc) enqueueCallTriage(p *prog.Prog, flags ProgTypes, callIndex int
 	})
 }
 
-func (proc *Proc) executeAndCollide(execOpts *ipc.ExecOpts, p *prog.Prog, flags ProgTypes, stat Stat) {
-	proc.execute(execOpts, p, flags, stat)
+// executeAndCollide returns true if the program gave new signal.
+func (proc *Proc) executeAndCollide(execOpts *ipc.ExecOpts, p *prog.Prog, flags ProgTypes, stat Stat) bool {
+	info := proc.executeRaw(execOpts, p, stat)
+	newSignal := info != nil && proc.triageNewSignal(p, flags, info)
 
 	if proc.execOptsCollide.Flags&ipc.FlagThreaded == 0 {
 		// We cannot collide syscalls without being in the threaded mode.
-		return
+		return newSignal
 	}
 	const collideIterations = 2
 	for i := 0; i < collideIterations; i++ {
 		proc.executeRaw(proc.execOptsCollide, proc.randomCollide(p), StatCollide)
 	}
+	return newSignal
 }
 
 func (proc *Proc) randomCollide(origP *prog.Prog) *prog.Prog {
@@ -323,6 +442,7 @@ func (proc *Proc) executeRaw(opts *ipc.ExecOpts, p *prog.Prog, stat Stat) *ipc.P
 	defer proc.fuzzer.gate.Leave(ticket)
 
 	proc.logProgram(opts, p)
+	atomic.AddUint64(&proc.fuzzer.execs, 1)
 	for try := 0; ; try++ {
 		atomic.AddUint64(&proc.fuzzer.stats[stat], 1)
 		output, info, hanged, err := proc.env.Exec(opts, p)
@@ -343,16 +463,89 @@ func (proc *Proc) executeRaw(opts *ipc.ExecOpts, p *prog.Prog, stat Stat) *ipc.P
 			continue
 		}
 		log.Logf(2, "result hanged=%v: %s", hanged, output)
+		proc.fuzzer.callTimes.record(p, info)
 		return info
 	}
 }
 
+// executeRawBatch is like executeRaw for several programs (see ipc.Env.ExecBatch).
+// Infos of programs that could not be executed are nil.
+func (proc *Proc) executeRawBatch(opts *ipc.ExecOpts, progs []*prog.Prog, stat Stat) []*ipc.ProgInfo {
+	allOpts := make([]*ipc.ExecOpts, len(progs))
+	for i, p := range progs {
+		proc.fuzzer.checkDisabledCalls(p)
+		allOpts[i] = opts
+	}
+
+	ticket := proc.fuzzer.gate.Enter()
+	defer proc.fuzzer.gate.Leave(ticket)
+
+	proc.logBatch(opts, progs)
+	atomic.AddUint64(&proc.fuzzer.execs, uint64(len(progs)))
+	var res []*ipc.ProgInfo
+	for try := 0; len(res) < len(progs); {
+		atomic.AddUint64(&proc.fuzzer.stats[stat], uint64(len(progs)-len(res)))
+		output, infos, hanged, err := proc.env.ExecBatch(allOpts[len(res):], progs[len(res):])
+		res = append(res, infos...)
+		if err == prog.ErrExecBufferTooSmall {
+			// The next program does not fit into the input region, skip it (see executeRaw).
+			atomic.AddUint64(&proc.fuzzer.stats[StatBufferTooSmall], 1)
+			res = append(res, nil)
+			continue
+		}
+		if err != nil {
+			if try > 10 {
+				log.Fatalf("executor %v failed %v times: %v", proc.pid, try, err)
+			}
+			try++
+			log.Logf(4, "fuzzer detected executor failure='%v', retrying #%d", err, try)
+			debug.FreeOSMemory()
+			time.Sleep(time.Second)
+			continue
+		}
+		log.Logf(2, "result hanged=%v: %s", hanged, output)
+	}
+	for i, info := range res {
+		proc.fuzzer.callTimes.record(progs[i], info)
+	}
+	return res
+}
+
+// logBatch logs programs executed with executeRawBatch.
+// The executor runs the whole batch without returning to us, so we don't know which of the programs
+// is being executed. The batch is logged as a single program with calls of all programs,
+// so that crash log parsing and reproduction consider all of them rather than the last one.
+func (proc *Proc) logBatch(opts *ipc.ExecOpts, progs []*prog.Prog) {
+	if proc.fuzzer.outputType == OutputNone {
+		return
+	}
+	if len(progs) == 1 {
+		proc.logProgram(opts, progs[0])
+		return
+	}
+	proc.logProgramData(fmt.Sprintf(" (batch of %v programs)", len(progs)), batchProgram(progs).Serialize())
+}
+
+// batchProgram returns a program with calls of all the programs (for logging only).
+func batchProgram(progs []*prog.Prog) *prog.Prog {
+	batch := &prog.Prog{
+		Target: progs[0].Target,
+	}
+	for _, p := range progs {
+		batch.Calls = append(batch.Calls, p.Calls...)
+	}
+	return batch
+}
+
 func (proc *Proc) logProgram(opts *ipc.ExecOpts, p *prog.Prog) {
 	if proc.fuzzer.outputType == OutputNone {
 		return
 	}
+	proc.logProgramData("", p.Serialize())
+}
 
-	data := p.Serialize()
+// logProgramData logs serialized program, note is appended to the log header.
+func (proc *Proc) logProgramData(note string, data []byte) {
 
 	// The following output helps to understand what program crashed kernel.
 	// It must not be intermixed.
@@ -360,16 +553,16 @@ func (proc *Proc) logProgram(opts *ipc.ExecOpts, p *prog.Prog) {
 	case OutputStdout:
 		now := time.Now()
 		proc.fuzzer.logMu.Lock()
-		fmt.Printf("%02v:%02v:%02v executing program %v:\n%s\n",
+		fmt.Printf("%02v:%02v:%02v executing program %v%v:\n%s\n",
 			now.Hour(), now.Minute(), now.Second(),
-			proc.pid, data)
+			proc.pid, note, data)
 		proc.fuzzer.logMu.Unlock()
 	case OutputDmesg:
 		fd, err := syscall.Open("/dev/kmsg", syscall.O_WRONLY, 0)
 		if err == nil {
 			buf := new(bytes.Buffer)
-			fmt.Fprintf(buf, "syzkaller: executing program %v:\n%s\n",
-				proc.pid, data)
+			fmt.Fprintf(buf, "syzkaller: executing program %v%v:\n%s\n",
+				proc.pid, note, data)
 			syscall.Write(fd, buf.Bytes())
 			syscall.Close(fd)
 		}
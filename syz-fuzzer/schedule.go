@@ -0,0 +1,150 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/syzkaller/pkg/signal"
+)
+
+// Policies of choosing corpus inputs for mutation (see mgrconfig.Config.CorpusSchedule).
+const (
+	// Inputs are chosen proportionally to the size of their signal.
+	scheduleSignal = "signal"
+	// Inputs are chosen according to rarity of their signal and their recent productivity (see powerSchedule).
+	schedulePower = "power"
+)
+
+const (
+	// How often priorities of corpus inputs are recomputed.
+	corpusPrioUpdatePeriod = time.Minute
+	// Recent mutation statistics of inputs are halved on every update.
+	powerStatsDecay = 0.5
+	// The number of mutations without new signal that we assume for new inputs.
+	powerPriorMutations = 10
+	// Bounds of the productivity factor of an input.
+	powerMinFactor = 1.0 / 16
+	powerMaxFactor = 16
+	// Priorities of the power schedule are multiplied by this number to fit them into integers.
+	powerPrioScale = 16
+)
+
+func checkSchedule(schedule string) error {
+	switch schedule {
+	case "", scheduleSignal, schedulePower:
+		// Empty schedule means the default one (scheduleSignal).
+		return nil
+	default:
+		return fmt.Errorf("unknown corpus schedule %q", schedule)
+	}
+}
+
+// inputStats are mutation statistics of a corpus input.
+type inputStats struct {
+	mutations uint64 // updated atomically
+	newSignal uint64 // number of mutations that gave new signal, updated atomically
+
+	// The following fields are protected by Fuzzer.corpusMu.
+	lastMutations   uint64  // value of mutations on the previous update
+	lastNewSignal   uint64  // value of newSignal on the previous update
+	recentMutations float64 // exponentially decaying number of mutations
+	recentNewSignal float64 // exponentially decaying number of mutations that gave new signal
+}
+
+// recordMutation is called after a mutated version of the input was executed.
+func (st *inputStats) recordMutation(newSignal bool) {
+	atomic.AddUint64(&st.mutations, 1)
+	if newSignal {
+		atomic.AddUint64(&st.newSignal, 1)
+	}
+}
+
+func (st *inputStats) update() {
+	mutations, newSignal := atomic.LoadUint64(&st.mutations), atomic.LoadUint64(&st.newSignal)
+	st.recentMutations = st.recentMutations*powerStatsDecay + float64(mutations-st.lastMutations)
+	st.recentNewSignal = st.recentNewSignal*powerStatsDecay + float64(newSignal-st.lastNewSignal)
+	st.lastMutations, st.lastNewSignal = mutations, newSignal
+}
+
+// powerSchedule prefers inputs with rare signal and inputs whose mutations recently gave new signal.
+// The rarity of an input is the sum of 1/N over its signal elements, where N is the number of corpus
+// inputs that have the element. So an input is weighted by its own signal, while signal shared
+// by many inputs is split between them. The productivity factor is the ratio of recent mutations
+// that gave new signal (with a prior for new inputs), so inputs that were mutated many times
+// without any result are chosen less frequently. All fields are protected by Fuzzer.corpusMu.
+type powerSchedule struct {
+	inputSignal [][]uint32        // signal elements of each corpus input
+	signalCount map[uint32]uint32 // number of corpus inputs that have each signal element
+}
+
+func newPowerSchedule() *powerSchedule {
+	return &powerSchedule{
+		signalCount: make(map[uint32]uint32),
+	}
+}
+
+func (ps *powerSchedule) addInput(sign signal.Signal) {
+	elems := make([]uint32, 0, len(sign))
+	for elem := range sign {
+		elems = append(elems, uint32(elem))
+		ps.signalCount[uint32(elem)]++
+	}
+	ps.inputSignal = append(ps.inputSignal, elems)
+}
+
+func (ps *powerSchedule) prio(i int, st *inputStats) int64 {
+	rarity := 0.0
+	for _, elem := range ps.inputSignal[i] {
+		rarity += 1 / float64(ps.signalCount[elem])
+	}
+	if len(ps.inputSignal[i]) == 0 {
+		rarity = 1
+	}
+	// Relative to the productivity we assume for new inputs.
+	factor := (st.recentNewSignal + 1) / (st.recentMutations + powerPriorMutations) * powerPriorMutations
+	if factor < powerMinFactor {
+		factor = powerMinFactor
+	}
+	if factor > powerMaxFactor {
+		factor = powerMaxFactor
+	}
+	prio := int64(math.Round(rarity * factor * powerPrioScale))
+	if prio < 1 {
+		prio = 1
+	}
+	return prio
+}
+
+// inputPrio returns priority of the corpus input with index i. The caller must hold corpusMu.
+func (fuzzer *Fuzzer) inputPrio(i int) int64 {
+	prio := fuzzer.corpusBasePrios[i]
+	if fuzzer.power != nil {
+		prio = fuzzer.power.prio(i, fuzzer.corpusStats[i])
+	}
+	if fuzzer.directed != nil {
+		prio = fuzzer.directed.prio(prio, fuzzer.corpusDistances[i])
+	}
+	return prio
+}
+
+// updateCorpusPrios recomputes priorities of all corpus inputs, it's called periodically
+// if priorities change over time (with the power schedule or directed fuzzing).
+func (fuzzer *Fuzzer) updateCorpusPrios() {
+	fuzzer.corpusMu.Lock()
+	defer fuzzer.corpusMu.Unlock()
+	// Snapshots may still use the old slice, so we create a new one.
+	prios := make([]int64, len(fuzzer.corpusPrios))
+	sum := int64(0)
+	for i := range prios {
+		fuzzer.corpusStats[i].update()
+		sum += fuzzer.inputPrio(i)
+		prios[i] = sum
+	}
+	fuzzer.corpusPrios = prios
+	fuzzer.sumPrios = sum
+}
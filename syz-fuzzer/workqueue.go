@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/google/syzkaller/pkg/ipc"
+	"github.com/google/syzkaller/pkg/rpctype"
 	"github.com/google/syzkaller/prog"
 )
 
@@ -20,6 +21,7 @@ type WorkQueue struct {
 	candidate       []*WorkCandidate
 	triage          []*WorkTriage
 	smash           []*WorkSmash
+	faults          []*WorkFaultSweep
 
 	procs          int
 	needCandidates chan struct{}
@@ -61,6 +63,14 @@ type WorkSmash struct {
 	call int
 }
 
+// WorkFaultSweep are corpus programs that are not yet swept with fault injection
+// (see mgrconfig.Config.FaultCampaign). The sweep tries all fault sites of all calls,
+// faults contains results of previous sweeps (fault sites that crashed the kernel), if any.
+type WorkFaultSweep struct {
+	p      *prog.Prog
+	faults *rpctype.FaultSweep
+}
+
 func newWorkQueue(procs int, needCandidates chan struct{}) *WorkQueue {
 	return &WorkQueue{
 		procs:          procs,
@@ -82,6 +92,8 @@ func (wq *WorkQueue) enqueue(item interface{}) {
 		wq.candidate = append(wq.candidate, item)
 	case *WorkSmash:
 		wq.smash = append(wq.smash, item)
+	case *WorkFaultSweep:
+		wq.faults = append(wq.faults, item)
 	default:
 		panic("unknown work type")
 	}
@@ -89,7 +101,7 @@ func (wq *WorkQueue) enqueue(item interface{}) {
 
 func (wq *WorkQueue) dequeue() (item interface{}) {
 	wq.mu.RLock()
-	if len(wq.triageCandidate)+len(wq.candidate)+len(wq.triage)+len(wq.smash) == 0 {
+	if len(wq.triageCandidate)+len(wq.candidate)+len(wq.triage)+len(wq.smash)+len(wq.faults) == 0 {
 		wq.mu.RUnlock()
 		return nil
 	}
@@ -113,6 +125,10 @@ func (wq *WorkQueue) dequeue() (item interface{}) {
 		last := len(wq.smash) - 1
 		item = wq.smash[last]
 		wq.smash = wq.smash[:last]
+	} else if len(wq.faults) != 0 {
+		last := len(wq.faults) - 1
+		item = wq.faults[last]
+		wq.faults = wq.faults[:last]
 	}
 	wq.mu.Unlock()
 	if wantCandidates {
@@ -129,3 +145,42 @@ func (wq *WorkQueue) wantCandidates() bool {
 	defer wq.mu.RUnlock()
 	return len(wq.candidate) < wq.procs
 }
+
+// checkpoint serializes all pending work items, so that they can be restored
+// by the next fuzzer if this one dies.
+func (wq *WorkQueue) checkpoint() *rpctype.FuzzerState {
+	wq.mu.RLock()
+	defer wq.mu.RUnlock()
+	state := new(rpctype.FuzzerState)
+	for _, list := range [][]*WorkTriage{wq.triageCandidate, wq.triage} {
+		for _, item := range list {
+			state.Triage = append(state.Triage, rpctype.TriageItem{
+				Prog:   item.p.Serialize(),
+				CallID: item.call,
+				Signal: item.info.Signal,
+				Errno:  item.info.Errno,
+				Flags:  int(item.flags),
+			})
+		}
+	}
+	for _, item := range wq.candidate {
+		state.Candidates = append(state.Candidates, rpctype.Candidate{
+			Prog:      item.p.Serialize(),
+			Minimized: item.flags&ProgMinimized != 0,
+			Smashed:   item.flags&ProgSmashed != 0,
+		})
+	}
+	for _, item := range wq.smash {
+		state.Smash = append(state.Smash, rpctype.SmashItem{
+			Prog:   item.p.Serialize(),
+			CallID: item.call,
+		})
+	}
+	for _, item := range wq.faults {
+		state.Faults = append(state.Faults, rpctype.FaultSweepItem{
+			Prog:   item.p.Serialize(),
+			Faults: item.faults,
+		})
+	}
+	return state
+}
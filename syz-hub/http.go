@@ -44,6 +44,7 @@ func (hub *Hub) httpSummary(w http.ResponseWriter, r *http.Request) {
 		total.Added += mgr.Added
 		total.Deleted += mgr.Deleted
 		total.New += mgr.New
+		total.Dups += mgr.Dups
 		total.SentRepros += mgr.SentRepros
 		total.RecvRepros += mgr.RecvRepros
 		data.Managers = append(data.Managers, UIManager{
@@ -53,6 +54,7 @@ func (hub *Hub) httpSummary(w http.ResponseWriter, r *http.Request) {
 			Added:      mgr.Added,
 			Deleted:    mgr.Deleted,
 			New:        mgr.New,
+			Dups:       mgr.Dups,
 			SentRepros: mgr.SentRepros,
 			RecvRepros: mgr.RecvRepros,
 		})
@@ -61,6 +63,13 @@ func (hub *Hub) httpSummary(w http.ResponseWriter, r *http.Request) {
 		return data.Managers[i].Name < data.Managers[j].Name
 	})
 	data.Managers = append([]UIManager{total}, data.Managers...)
+	for _, stats := range hub.st.Stats {
+		data.Inputs.Total++
+		data.Inputs.Sent += stats.Sent
+		data.Inputs.Accepted += stats.Accepted
+		data.Inputs.Minimized += stats.Minimized
+		data.Inputs.Dropped += stats.Dropped
+	}
 	if err := summaryTemplate.Execute(w, data); err != nil {
 		log.Logf(0, "failed to execute template: %v", err)
 		http.Error(w, fmt.Sprintf("failed to execute template: %v", err), http.StatusInternalServerError)
@@ -74,9 +83,18 @@ func compileTemplate(html string) *template.Template {
 
 type UISummaryData struct {
 	Managers []UIManager
+	Inputs   UIInputs
 	Log      string
 }
 
+type UIInputs struct {
+	Total     int
+	Sent      int
+	Accepted  int
+	Minimized int
+	Dropped   int
+}
+
 type UIManager struct {
 	Name       string
 	Domain     string
@@ -84,6 +102,7 @@ type UIManager struct {
 	Added      int
 	Deleted    int
 	New        int
+	Dups       int
 	Repros     int
 	SentRepros int
 	RecvRepros int
@@ -109,6 +128,7 @@ var summaryTemplate = compileTemplate(`
 		<th>Added</th>
 		<th>Deleted</th>
 		<th>New</th>
+		<th>Dups</th>
 		<th>Repros</th>
 		<th>Sent</th>
 		<th>Recv</th>
@@ -121,6 +141,7 @@ var summaryTemplate = compileTemplate(`
 		<td>{{$m.Added}}</td>
 		<td>{{$m.Deleted}}</td>
 		<td>{{$m.New}}</td>
+		<td>{{$m.Dups}}</td>
 		<td>{{$m.Repros}}</td>
 		<td>{{$m.SentRepros}}</td>
 		<td>{{$m.RecvRepros}}</td>
@@ -129,6 +150,25 @@ var summaryTemplate = compileTemplate(`
 </table>
 <br><br>
 
+<table>
+	<caption>Inputs:</caption>
+	<tr>
+		<th>Total</th>
+		<th>Sent</th>
+		<th>Accepted</th>
+		<th>Minimized</th>
+		<th>Dropped</th>
+	</tr>
+	<tr>
+		<td>{{$.Inputs.Total}}</td>
+		<td>{{$.Inputs.Sent}}</td>
+		<td>{{$.Inputs.Accepted}}</td>
+		<td>{{$.Inputs.Minimized}}</td>
+		<td>{{$.Inputs.Dropped}}</td>
+	</tr>
+</table>
+<br><br>
+
 Log:
 <br>
 <textarea id="log_textarea" readonly rows="50">
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"strings"
@@ -25,6 +26,11 @@ type Config struct {
 	HTTP    string
 	RPC     string
 	Workdir string
+	// PEM files with the hub certificate/private key and the CA certificate used
+	// to verify managers (optional). If specified, managers must connect with mutual TLS.
+	TLSCert string `json:"tls_cert"`
+	TLSKey  string `json:"tls_key"`
+	TLSCA   string `json:"tls_ca"`
 	Clients []struct {
 		Name string
 		Key  string
@@ -61,7 +67,13 @@ func main() {
 
 	hub.initHTTP(cfg.HTTP)
 
-	s, err := rpctype.NewRPCServer(cfg.RPC, "Hub", hub)
+	var tlsCfg *tls.Config
+	if cfg.TLSCert != "" || cfg.TLSKey != "" || cfg.TLSCA != "" {
+		if tlsCfg, err = rpctype.ServerTLSConfig(cfg.TLSCert, cfg.TLSKey, cfg.TLSCA); err != nil {
+			log.Fatalf("failed to load TLS config: %v", err)
+		}
+	}
+	s, err := rpctype.NewRPCServer(cfg.RPC, "Hub", hub, tlsCfg)
 	if err != nil {
 		log.Fatalf("failed to create rpc server: %v", err)
 	}
@@ -94,6 +106,10 @@ func (hub *Hub) Sync(a *rpctype.HubSyncArgs, r *rpctype.HubSyncRes) error {
 	hub.mu.Lock()
 	defer hub.mu.Unlock()
 
+	if err := hub.st.AddResults(name, a.Results); err != nil {
+		log.Logf(0, "sync error: %v", err)
+		return err
+	}
 	domain, inputs, more, err := hub.st.Sync(name, a.Add, a.Del)
 	if err != nil {
 		log.Logf(0, "sync error: %v", err)
@@ -4,9 +4,12 @@
 package state
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"time"
@@ -29,6 +32,41 @@ type State struct {
 	Corpus    *db.DB
 	Repros    *db.DB
 	Managers  map[string]*Manager
+	// Per-input statistics keyed by input hash, persisted in statsDB.
+	Stats   map[string]*InputStats
+	statsDB *db.DB
+	// Hashes of normalized programs mapped to the corpus input with that normalized form.
+	normalized map[string]string
+	// Near-duplicates of corpus inputs mapped to the corpus input, persisted in dupsDB.
+	// Managers refer to the near-duplicates when they delete inputs.
+	dups   map[string]string
+	dupsDB *db.DB
+}
+
+// InputStats holds statistics about how managers treated a corpus input.
+type InputStats struct {
+	// Managers that have the input in their corpus (including the one that found it).
+	Managers map[string]bool
+	// Number of times the input was sent to managers.
+	Sent int
+	// Number of managers that accepted the input as a candidate.
+	Accepted int
+	// Number of accepted inputs that were minimized by the receiver (it came from a different domain).
+	Minimized int
+	// Number of managers that rejected the input (e.g. it contains disabled calls).
+	Dropped int
+}
+
+// Score estimates usefulness of the input for other managers, higher is better.
+// It's the ratio of managers that have the input in their corpus to the managers that tried it.
+// Minimized inputs are not counted as tried since minimization changes the program
+// and we can't match it anymore.
+func (stats *InputStats) Score() float64 {
+	tried := stats.Accepted - stats.Minimized
+	if tried < 0 {
+		tried = 0
+	}
+	return float64(len(stats.Managers)) / float64(1+tried)
 }
 
 // Manager represents one syz-manager instance.
@@ -41,22 +79,29 @@ type Manager struct {
 	corpusSeqFile string
 	reproSeqFile  string
 	domainFile    string
+	sentFile      string
 	ownRepros     map[string]bool
-	Connected     time.Time
-	Added         int
-	Deleted       int
-	New           int
-	SentRepros    int
-	RecvRepros    int
-	Calls         map[string]struct{}
-	Corpus        *db.DB
+	// Inputs with seq > corpusSeq that were already sent to the manager (only keys are stored).
+	sent       *db.DB
+	Connected  time.Time
+	Added      int
+	Deleted    int
+	New        int
+	Dups       int
+	SentRepros int
+	RecvRepros int
+	Calls      map[string]struct{}
+	Corpus     *db.DB
 }
 
 // Make creates State and initializes it from dir.
 func Make(dir string) (*State, error) {
 	st := &State{
-		dir:      dir,
-		Managers: make(map[string]*Manager),
+		dir:        dir,
+		Managers:   make(map[string]*Manager),
+		Stats:      make(map[string]*InputStats),
+		normalized: make(map[string]string),
+		dups:       make(map[string]string),
 	}
 
 	osutil.MkdirAll(st.dir)
@@ -69,6 +114,28 @@ func Make(dir string) (*State, error) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	st.statsDB, _, err = loadDB(filepath.Join(st.dir, "stats.db"), "stats", false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for key, rec := range st.statsDB.Records {
+		stats := new(InputStats)
+		if err := json.Unmarshal(rec.Val, stats); err != nil {
+			log.Logf(0, "bad stats for %v: %v", key, err)
+			continue
+		}
+		st.Stats[key] = stats
+	}
+	for key, rec := range st.Corpus.Records {
+		st.normalized[hash.String(normalize(rec.Val))] = key
+	}
+	st.dupsDB, _, err = loadDB(filepath.Join(st.dir, "dups.db"), "dups", false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for key, rec := range st.dupsDB.Records {
+		st.dups[key] = string(rec.Val)
+	}
 
 	managersDir := filepath.Join(st.dir, "manager")
 	osutil.MkdirAll(managersDir)
@@ -92,6 +159,7 @@ func (st *State) Flush() {
 	if err := st.Corpus.Flush(); err != nil {
 		log.Logf(0, "failed to flush corpus database: %v", err)
 	}
+	st.flushStats()
 	for _, mgr := range st.Managers {
 		if err := mgr.Corpus.Flush(); err != nil {
 			log.Logf(0, "failed to flush corpus database: %v", err)
@@ -145,6 +213,7 @@ func (st *State) createManager(name string) (*Manager, error) {
 		corpusSeqFile: filepath.Join(dir, "seq"),
 		reproSeqFile:  filepath.Join(dir, "repro.seq"),
 		domainFile:    filepath.Join(dir, "domain"),
+		sentFile:      filepath.Join(dir, "sent.db"),
 		ownRepros:     make(map[string]bool),
 	}
 	mgr.corpusSeq = loadSeqFile(mgr.corpusSeqFile)
@@ -165,6 +234,10 @@ func (st *State) createManager(name string) (*Manager, error) {
 		return nil, fmt.Errorf("failed to open manager corpus %v: %v", mgr.corpusFile, err)
 	}
 	mgr.Corpus = corpus
+	mgr.sent, _, err = loadDB(mgr.sentFile, name+" sent", false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manager sent inputs %v: %v", mgr.sentFile, err)
+	}
 	log.Logf(0, "created manager %v: domain=%v corpus=%v, corpusSeq=%v, reproSeq=%v",
 		mgr.name, mgr.Domain, len(mgr.Corpus.Records), mgr.corpusSeq, mgr.reproSeq)
 	st.Managers[name] = mgr
@@ -186,6 +259,9 @@ func (st *State) Connect(name, domain string, fresh bool, calls []string, corpus
 	if fresh {
 		mgr.corpusSeq = 0
 		mgr.reproSeq = st.reproSeq
+		if err := mgr.resetSent(); err != nil {
+			return err
+		}
 	}
 	saveSeqFile(mgr.corpusSeqFile, mgr.corpusSeq)
 	saveSeqFile(mgr.reproSeqFile, mgr.reproSeq)
@@ -214,7 +290,14 @@ func (st *State) Sync(name string, add [][]byte, del []string) (string, []rpctyp
 	}
 	if len(del) != 0 {
 		for _, sig := range del {
+			if rep, ok := st.dups[sig]; ok {
+				sig = rep
+			}
 			mgr.Corpus.Delete(sig)
+			if stats := st.Stats[sig]; stats != nil && stats.Managers[name] {
+				delete(stats.Managers, name)
+				st.saveStats(sig)
+			}
 		}
 		if err := mgr.Corpus.Flush(); err != nil {
 			log.Logf(0, "failed to flush corpus database: %v", err)
@@ -223,12 +306,38 @@ func (st *State) Sync(name string, add [][]byte, del []string) (string, []rpctyp
 	}
 	st.addInputs(mgr, add)
 	progs, more, err := st.pendingInputs(mgr)
+	st.flushStats()
 	mgr.Added += len(add)
 	mgr.Deleted += len(del)
 	mgr.New += len(progs)
 	return mgr.Domain, progs, more, err
 }
 
+// AddResults records what the manager did with inputs it received from hub.
+func (st *State) AddResults(name string, results []rpctype.HubInputResult) error {
+	mgr := st.Managers[name]
+	if mgr == nil || mgr.Connected.IsZero() {
+		return fmt.Errorf("unconnected manager %v", name)
+	}
+	for _, res := range results {
+		stats := st.Stats[res.Sig]
+		if stats == nil {
+			continue
+		}
+		if res.Dropped {
+			stats.Dropped++
+		} else {
+			stats.Accepted++
+			if res.Minimized {
+				stats.Minimized++
+			}
+		}
+		st.saveStats(res.Sig)
+	}
+	st.flushStats()
+	return nil
+}
+
 func (st *State) AddRepro(name string, repro []byte) error {
 	mgr := st.Managers[name]
 	if mgr == nil || mgr.Connected.IsZero() {
@@ -302,13 +411,14 @@ func (st *State) pendingInputs(mgr *Manager) ([]rpctype.HubInput, int, error) {
 		return nil, 0, nil
 	}
 	type Record struct {
-		Key string
-		Val []byte
-		Seq uint64
+		Key   string
+		Val   []byte
+		Seq   uint64
+		Score float64
 	}
 	var records []Record
 	for key, rec := range st.Corpus.Records {
-		if mgr.corpusSeq >= rec.Seq {
+		if _, sent := mgr.sent.Records[key]; sent || mgr.corpusSeq >= rec.Seq {
 			continue
 		}
 		if _, ok := mgr.Corpus.Records[key]; ok {
@@ -321,33 +431,34 @@ func (st *State) pendingInputs(mgr *Manager) ([]rpctype.HubInput, int, error) {
 		if !managerSupportsAllCalls(mgr.Calls, calls) {
 			continue
 		}
-		records = append(records, Record{key, rec.Val, rec.Seq})
+		records = append(records, Record{key, rec.Val, rec.Seq, st.stats(key).Score()})
 	}
-	maxSeq := st.corpusSeq
 	more := 0
 	const (
-		// Send at most that many records (rounded up to next seq number).
+		// Send at most that many records.
 		maxRecords = 100
 		// If we have way too many records to send (more than capRecords),
-		// cap total number to capRecords and give up sending all.
+		// cap total number to capRecords and give up sending the rest
+		// (they are marked as sent and won't be sent to this manager unless it reconnects as fresh).
 		// Otherwise new managers will never chew all this on a busy hub.
 		capRecords = 100000
 	)
 	if len(records) > maxRecords {
+		// Send inputs that turned out to be useful for other managers first.
 		sort.Slice(records, func(i, j int) bool {
+			if records[i].Score != records[j].Score {
+				return records[i].Score > records[j].Score
+			}
 			return records[i].Seq < records[j].Seq
 		})
 		if len(records) > capRecords {
-			records = records[len(records)-capRecords:]
-		}
-		pos := maxRecords
-		maxSeq = records[pos].Seq
-		for pos+1 < len(records) && records[pos+1].Seq == maxSeq {
-			pos++
+			for _, rec := range records[capRecords:] {
+				mgr.sent.Save(rec.Key, nil, 0)
+			}
+			records = records[:capRecords]
 		}
-		pos++
-		more = len(records) - pos
-		records = records[:pos]
+		more = len(records) - maxRecords
+		records = records[:maxRecords]
 	}
 	progs := make([]rpctype.HubInput, 0, len(records))
 	for _, rec := range records {
@@ -355,10 +466,37 @@ func (st *State) pendingInputs(mgr *Manager) ([]rpctype.HubInput, int, error) {
 			Domain: st.inputDomain(rec.Key, mgr.Domain),
 			Prog:   rec.Val,
 		})
+		st.stats(rec.Key).Sent++
+		st.saveStats(rec.Key)
+	}
+	if more != 0 {
+		// Don't advance corpusSeq until we've sent all pending inputs,
+		// since they are not sent in seq order. Sent inputs are persisted,
+		// so that they are not sent again after restart.
+		for _, rec := range records {
+			mgr.sent.Save(rec.Key, nil, 0)
+		}
+		if err := mgr.sent.Flush(); err != nil {
+			log.Logf(0, "failed to flush sent inputs database: %v", err)
+		}
+		return progs, more, nil
 	}
-	mgr.corpusSeq = maxSeq
+	mgr.corpusSeq = st.corpusSeq
 	saveSeqFile(mgr.corpusSeqFile, mgr.corpusSeq)
-	return progs, more, nil
+	return progs, more, mgr.resetSent()
+}
+
+func (mgr *Manager) resetSent() error {
+	if len(mgr.sent.Records) == 0 {
+		return nil
+	}
+	os.Remove(mgr.sentFile)
+	var err error
+	mgr.sent, err = db.Open(mgr.sentFile, true)
+	if err != nil {
+		return fmt.Errorf("failed to open sent inputs database: %v", err)
+	}
+	return nil
 }
 
 func (st *State) inputDomain(key, self string) string {
@@ -393,6 +531,9 @@ func (st *State) addInputs(mgr *Manager, inputs [][]byte) {
 	if err := st.Corpus.Flush(); err != nil {
 		log.Logf(0, "failed to flush corpus database: %v", err)
 	}
+	if err := st.dupsDB.Flush(); err != nil {
+		log.Logf(0, "failed to flush dups database: %v", err)
+	}
 }
 
 func (st *State) addInput(mgr *Manager, input []byte) {
@@ -406,9 +547,66 @@ func (st *State) addInput(mgr *Manager, input []byte) {
 		return
 	}
 	sig := hash.String(input)
-	mgr.Corpus.Save(sig, nil, 0)
 	if _, ok := st.Corpus.Records[sig]; !ok {
-		st.Corpus.Save(sig, input, st.corpusSeq)
+		norm := hash.String(normalize(input))
+		if rep := st.normalized[norm]; rep != "" {
+			// Near-duplicate of an existing input, the manager is considered to have that input.
+			st.dups[sig] = rep
+			st.dupsDB.Save(sig, []byte(rep), 0)
+			sig = rep
+			mgr.Dups++
+		} else {
+			st.Corpus.Save(sig, input, st.corpusSeq)
+			st.normalized[norm] = sig
+		}
+	}
+	mgr.Corpus.Save(sig, nil, 0)
+	if stats := st.stats(sig); !stats.Managers[mgr.name] {
+		stats.Managers[mgr.name] = true
+		st.saveStats(sig)
+	}
+}
+
+var (
+	normalizeAddr  = regexp.MustCompile(`&\(0x[0-9a-f]+(/0x[0-9a-f]+)?\)`)
+	addrNormalized = []byte("&(0x0)")
+)
+
+// normalize returns a normalized form of a program used to detect near-duplicates.
+// Programs that differ only in comments, whitespaces and addresses of pointers
+// (which are mutated a lot, but rarely matter) have the same normalized form.
+func normalize(input []byte) []byte {
+	var lines [][]byte
+	for _, line := range bytes.Split(input, []byte{'\n'}) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		lines = append(lines, normalizeAddr.ReplaceAll(line, addrNormalized))
+	}
+	return bytes.Join(lines, []byte{'\n'})
+}
+
+func (st *State) stats(sig string) *InputStats {
+	stats := st.Stats[sig]
+	if stats == nil {
+		stats = &InputStats{Managers: make(map[string]bool)}
+		st.Stats[sig] = stats
+	}
+	return stats
+}
+
+func (st *State) saveStats(sig string) {
+	data, err := json.Marshal(st.Stats[sig])
+	if err != nil {
+		panic(err)
+	}
+	st.statsDB.Save(sig, data, 0)
+}
+
+func (st *State) flushStats() {
+	if err := st.statsDB.Flush(); err != nil {
+		log.Logf(0, "failed to flush stats database: %v", err)
 	}
 }
 
@@ -419,12 +617,32 @@ func (st *State) purgeCorpus() {
 			used[sig] = true
 		}
 	}
-	for key := range st.Corpus.Records {
+	for key, rec := range st.Corpus.Records {
 		if used[key] {
 			continue
 		}
 		st.Corpus.Delete(key)
+		if norm := hash.String(normalize(rec.Val)); st.normalized[norm] == key {
+			delete(st.normalized, norm)
+		}
+	}
+	for key := range st.Stats {
+		if used[key] {
+			continue
+		}
+		delete(st.Stats, key)
+		st.statsDB.Delete(key)
+	}
+	for key, rep := range st.dups {
+		if !used[rep] {
+			delete(st.dups, key)
+			st.dupsDB.Delete(key)
+		}
+	}
+	if err := st.dupsDB.Flush(); err != nil {
+		log.Logf(0, "failed to flush dups database: %v", err)
 	}
+	st.flushStats()
 	if err := st.Corpus.Flush(); err != nil {
 		log.Logf(0, "failed to flush corpus database: %v", err)
 	}
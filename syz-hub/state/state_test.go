@@ -4,10 +4,12 @@
 package state
 
 import (
+	"fmt"
 	"sort"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/syzkaller/pkg/hash"
 	"github.com/google/syzkaller/pkg/rpctype"
 )
 
@@ -186,3 +188,110 @@ func TestDomain(t *testing.T) {
 		}
 	}
 }
+
+func TestNearDuplicates(t *testing.T) {
+	st := MakeTestState(t)
+
+	st.Connect("foo", "", false, []string{"open"}, nil)
+	st.Connect("bar", "", false, []string{"open"}, nil)
+	prog := []byte("open(&(0x7f0000000000)='./file0\\x00', 0x0)")
+	dup := []byte("# comment\nopen(&(0x7f0000000040)='./file0\\x00', 0x0)\n")
+	other := []byte("open(&(0x7f0000000000)='./file1\\x00', 0x0)")
+	st.Sync("foo", [][]byte{prog}, nil)
+	if _, inputs, _ := st.Sync("bar", [][]byte{dup, other}, nil); len(inputs) != 0 {
+		t.Fatalf("got near-duplicate inputs: %q", inputs)
+	}
+	_, inputs, _ := st.Sync("foo", nil, nil)
+	if diff := cmp.Diff(inputs, []rpctype.HubInput{{Prog: other}}); diff != "" {
+		t.Fatal(diff)
+	}
+	if got := len(st.state.Corpus.Records); got != 2 {
+		t.Fatalf("got %v corpus records, want 2", got)
+	}
+	if got := st.state.Managers["bar"].Dups; got != 1 {
+		t.Fatalf("got %v dups, want 1", got)
+	}
+	// Near-duplicates survive restart.
+	st.Reload()
+	if got := st.state.dups[hash.String(dup)]; got != hash.String(prog) {
+		t.Fatalf("near-duplicate is lost after restart: %q", got)
+	}
+	st.Connect("foo", "", false, []string{"open"}, [][]byte{prog, other})
+	st.Connect("bar", "", false, []string{"open"}, [][]byte{dup, other})
+	// The input is still in foo corpus.
+	st.Sync("bar", nil, []string{hash.String(dup)})
+	if got := len(st.state.Corpus.Records); got != 2 {
+		t.Fatalf("got %v corpus records, want 2", got)
+	}
+	st.Sync("foo", nil, []string{hash.String(prog)})
+	if got := len(st.state.Corpus.Records); got != 1 {
+		t.Fatalf("got %v corpus records, want 1", got)
+	}
+}
+
+func TestInputPriority(t *testing.T) {
+	st := MakeTestState(t)
+
+	calls := []string{"open"}
+	st.Connect("foo", "", false, calls, nil)
+	st.Connect("bar", "", false, calls, nil)
+	st.Connect("baz", "", false, calls, nil)
+	var progs, good [][]byte
+	for i := 0; i < 150; i++ {
+		progs = append(progs, []byte(fmt.Sprintf("open(0x%x)", i)))
+	}
+	// These inputs were found by several managers.
+	good = append(good, progs[120:125]...)
+	st.Sync("foo", progs, nil)
+	st.Sync("bar", good, nil)
+
+	_, inputs, more := st.Sync("baz", nil, nil)
+	if len(inputs) != 100 || more != 50 {
+		t.Fatalf("got %v inputs, %v more", len(inputs), more)
+	}
+	sent := make(map[string]bool)
+	for _, inp := range inputs {
+		sent[string(inp.Prog)] = true
+	}
+	for _, prog := range good {
+		if !sent[string(prog)] {
+			t.Fatalf("input %s was not sent first", prog)
+		}
+	}
+	// Sent inputs are not sent again after restart.
+	st.Reload()
+	st.Connect("baz", "", false, calls, nil)
+	results := []rpctype.HubInputResult{
+		{Sig: hash.String(progs[0]), Dropped: true},
+		{Sig: hash.String(progs[120]), Minimized: true},
+	}
+	if err := st.state.AddResults("baz", results); err != nil {
+		t.Fatal(err)
+	}
+	_, inputs, more = st.Sync("baz", nil, nil)
+	if len(inputs) != 50 || more != 0 {
+		t.Fatalf("got %v inputs, %v more", len(inputs), more)
+	}
+	for _, inp := range inputs {
+		if sent[string(inp.Prog)] {
+			t.Fatalf("input %s was sent twice", inp.Prog)
+		}
+	}
+	if _, inputs, more = st.Sync("baz", nil, nil); len(inputs) != 0 || more != 0 {
+		t.Fatalf("got %v inputs, %v more", len(inputs), more)
+	}
+
+	st.Reload()
+	stats := st.state.Stats[hash.String(progs[120])]
+	if diff := cmp.Diff(stats, &InputStats{
+		Managers:  map[string]bool{"foo": true, "bar": true},
+		Sent:      1,
+		Accepted:  1,
+		Minimized: 1,
+	}); diff != "" {
+		t.Fatal(diff)
+	}
+	if stats := st.state.Stats[hash.String(progs[0])]; stats.Dropped != 1 || stats.Score() != 1 {
+		t.Fatalf("bad stats: %+v", stats)
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/syzkaller/prog"
+)
+
+// JSON API for scripts and dashboards. It exposes the same data as the HTML pages.
+// All handlers are served under apiPrefix, the version must be bumped on incompatible changes.
+const apiPrefix = "/api/v1"
+
+type APIStats struct {
+	Uptime      int64             `json:"uptime"`  // in seconds
+	Fuzzing     int64             `json:"fuzzing"` // in seconds, summed over all VMs
+	Corpus      int               `json:"corpus"`
+	TriageQueue int               `json:"triage_queue"`
+	Stats       map[string]uint64 `json:"stats"`
+}
+
+type APICrashType struct {
+	ID          string      `json:"id"`
+	Title       string      `json:"title"`
+	Count       int         `json:"count"`
+	LastTime    time.Time   `json:"last_time"`
+	Active      bool        `json:"active"`
+	ReproStatus string      `json:"repro_status,omitempty"`
+	Strace      string      `json:"strace,omitempty"`
+	Group       string      `json:"group,omitempty"` // ID of the crash type with a near-identical stack
+	Crashes     []*APICrash `json:"crashes,omitempty"`
+}
+
+type APICrash struct {
+	Index  int       `json:"index"`
+	Time   time.Time `json:"time"`
+	Active bool      `json:"active"`
+	Log    string    `json:"log"`
+	Report string    `json:"report,omitempty"`
+	Tag    string    `json:"tag,omitempty"`
+}
+
+type APIInput struct {
+	Sig    string `json:"sig"`
+	Call   string `json:"call"`
+	Short  string `json:"short"`
+	Signal int    `json:"signal"`
+	Cover  int    `json:"cover"`
+}
+
+type APISyscall struct {
+	Name    string `json:"name"`
+	ID      int    `json:"id"`
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason,omitempty"` // why the syscall is disabled
+	Inputs  int    `json:"inputs"`
+	Cover   int    `json:"cover"`
+	Execs   uint64 `json:"execs"`
+	AvgTime int64  `json:"avg_time"` // average execution time in microseconds
+	MaxTime int64  `json:"max_time"` // in microseconds
+}
+
+type APIRepros struct {
+	// Crash titles that are queued for reproduction or are being reproduced now.
+	Pending []string `json:"pending"`
+}
+
+func (mgr *Manager) initAPI(handle func(string, func(http.ResponseWriter, *http.Request))) {
+	handle(apiPrefix+"/stats", mgr.apiStats)
+	handle(apiPrefix+"/crashes", mgr.apiCrashes)
+	handle(apiPrefix+"/crash", mgr.apiCrash)
+	handle(apiPrefix+"/corpus", mgr.apiCorpus)
+	handle(apiPrefix+"/syscalls", mgr.apiSyscalls)
+	handle(apiPrefix+"/repros", mgr.apiRepros)
+}
+
+func (mgr *Manager) apiStats(w http.ResponseWriter, r *http.Request) {
+	stats := mgr.stats.all()
+	mgr.mu.Lock()
+	data := &APIStats{
+		Uptime:      int64(time.Since(mgr.startTime) / time.Second),
+		Fuzzing:     int64(mgr.fuzzingTime / time.Second),
+		Corpus:      len(mgr.corpus),
+		TriageQueue: len(mgr.candidates),
+		Stats:       stats,
+	}
+	mgr.mu.Unlock()
+	serveJSON(w, data)
+}
+
+func (mgr *Manager) apiCrashes(w http.ResponseWriter, r *http.Request) {
+	crashes, err := mgr.collectCrashes(mgr.cfg.Workdir)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to collect crashes: %v", err), http.StatusInternalServerError)
+		return
+	}
+	data := []*APICrashType{}
+	for _, crash := range crashes {
+		data = append(data, apiCrashType(crash))
+	}
+	serveJSON(w, data)
+}
+
+func (mgr *Manager) apiCrash(w http.ResponseWriter, r *http.Request) {
+	crash := readCrash(mgr.cfg.Workdir, r.FormValue("id"), nil, mgr.startTime, true)
+	if crash == nil {
+		http.Error(w, "failed to read crash info", http.StatusNotFound)
+		return
+	}
+	serveJSON(w, apiCrashType(crash))
+}
+
+func apiCrashType(crash *UICrashType) *APICrashType {
+	res := &APICrashType{
+		ID:          crash.ID,
+		Title:       crash.Description,
+		Count:       crash.Count,
+		LastTime:    crash.LastTime,
+		Active:      crash.Active,
+		ReproStatus: crash.Triaged,
+		Strace:      crash.Strace,
+		Group:       crash.Group,
+	}
+	for _, c := range crash.Crashes {
+		if c.Log == "" {
+			continue // not a full crash info
+		}
+		res.Crashes = append(res.Crashes, &APICrash{
+			Index:  c.Index,
+			Time:   c.Time,
+			Active: c.Active,
+			Log:    c.Log,
+			Report: c.Report,
+			Tag:    c.Tag,
+		})
+	}
+	return res
+}
+
+func (mgr *Manager) apiCorpus(w http.ResponseWriter, r *http.Request) {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	call := r.FormValue("call")
+	data := []*APIInput{}
+	for sig, inp := range mgr.corpus {
+		if call != "" && call != inp.Call {
+			continue
+		}
+		p, err := mgr.target.Deserialize(inp.Prog, prog.NonStrict)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to deserialize program: %v", err), http.StatusInternalServerError)
+			return
+		}
+		data = append(data, &APIInput{
+			Sig:    sig,
+			Call:   inp.Call,
+			Short:  p.String(),
+			Signal: len(inp.Signal.Elems),
+			Cover:  len(inp.Cover),
+		})
+	}
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].Sig < data[j].Sig
+	})
+	serveJSON(w, data)
+}
+
+func (mgr *Manager) apiSyscalls(w http.ResponseWriter, r *http.Request) {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	if mgr.checkResult == nil {
+		http.Error(w, "machine is not checked yet", http.StatusServiceUnavailable)
+		return
+	}
+	info := mgr.collectSyscallInfoUnlocked()
+	times := mgr.stats.allCallTimes()
+	enabled := make(map[int]bool)
+	for _, id := range mgr.cfg.Syscalls {
+		enabled[id] = true
+	}
+	data := []*APISyscall{}
+	for _, call := range mgr.target.Syscalls {
+		if call.Attrs.Disabled {
+			continue
+		}
+		sc := &APISyscall{
+			Name: call.Name,
+			ID:   call.ID,
+		}
+		switch {
+		case !enabled[call.ID]:
+			sc.Reason = "disabled in config"
+		case mgr.disabledCalls[call.ID] != "":
+			sc.Reason = mgr.disabledCalls[call.ID]
+		default:
+			sc.Enabled = true
+		}
+		if cc := info[call.Name]; cc != nil {
+			sc.Inputs = cc.count
+			sc.Cover = len(cc.cov)
+		}
+		if t, ok := times[call.Name]; ok {
+			sc.Execs = t.Execs
+			sc.AvgTime = t.Average().Microseconds()
+			sc.MaxTime = t.Max.Microseconds()
+		}
+		data = append(data, sc)
+	}
+	serveJSON(w, data)
+}
+
+func (mgr *Manager) apiRepros(w http.ResponseWriter, r *http.Request) {
+	// Note: mu is not locked here.
+	reproReply := make(chan map[string]bool)
+	mgr.reproRequest <- reproReply
+	data := &APIRepros{Pending: []string{}}
+	for title := range <-reproReply {
+		data.Pending = append(data.Pending, title)
+	}
+	sort.Strings(data.Pending)
+	serveJSON(w, data)
+}
+
+func serveJSON(w http.ResponseWriter, data interface{}) {
+	res, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode json: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
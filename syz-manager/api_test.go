@@ -0,0 +1,98 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/pkg/mgrconfig"
+	"github.com/google/syzkaller/pkg/rpctype"
+	"github.com/google/syzkaller/pkg/signal"
+	"github.com/google/syzkaller/prog"
+	_ "github.com/google/syzkaller/sys"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func TestAPICorpusAndSyscalls(t *testing.T) {
+	target, err := prog.GetTarget(targets.TestOS, targets.TestArch64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call0 := target.SyscallMap["test$int"]
+	call1 := target.SyscallMap["test$opt0"]
+	call2 := target.SyscallMap["test$opt1"]
+	data := []byte("test$int(0x1, 0x2, 0x3, 0x4, 0x5)\n")
+	mgr := &Manager{
+		cfg: &mgrconfig.Config{
+			Sandbox: "none",
+			Derived: mgrconfig.Derived{
+				Target:   target,
+				Syscalls: []int{call0.ID, call1.ID},
+			},
+		},
+		target: target,
+		corpus: map[string]CorpusItem{
+			hash.String(data): {
+				Call:   call0.Name,
+				Prog:   data,
+				Signal: signal.FromRaw([]uint32{1, 2, 3}, 0).Serialize(),
+				Cover:  []uint32{10, 20},
+			},
+		},
+		checkResult: &rpctype.CheckArgs{
+			EnabledCalls: map[string][]int{"none": {call0.ID}},
+		},
+		disabledCalls: map[int]string{call1.ID: "not supported"},
+		stats:         new(Stats),
+	}
+
+	rec := httptest.NewRecorder()
+	mgr.apiCorpus(rec, httptest.NewRequest("GET", apiPrefix+"/corpus", nil))
+	var inputs []*APIInput
+	if err := json.Unmarshal(rec.Body.Bytes(), &inputs); err != nil {
+		t.Fatal(err)
+	}
+	wantInputs := []*APIInput{{
+		Sig:    hash.String(data),
+		Call:   call0.Name,
+		Short:  "test$int",
+		Signal: 3,
+		Cover:  2,
+	}}
+	if diff := cmp.Diff(wantInputs, inputs); diff != "" {
+		t.Fatal(diff)
+	}
+
+	for i := 0; i < 2; i++ {
+		mgr.stats.mergeCallTimes(map[string]rpctype.CallTime{
+			call0.Name: {Execs: 2, Total: 3 * time.Millisecond, Max: time.Duration(i+1) * time.Millisecond},
+		})
+	}
+	rec = httptest.NewRecorder()
+	mgr.apiSyscalls(rec, httptest.NewRequest("GET", apiPrefix+"/syscalls", nil))
+	var calls []*APISyscall
+	if err := json.Unmarshal(rec.Body.Bytes(), &calls); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]*APISyscall)
+	for _, call := range calls {
+		got[call.Name] = call
+	}
+	want := map[string]*APISyscall{
+		call0.Name: {Name: call0.Name, ID: call0.ID, Enabled: true, Inputs: 1, Cover: 2,
+			Execs: 4, AvgTime: 1500, MaxTime: 2000},
+		call1.Name: {Name: call1.Name, ID: call1.ID, Reason: "not supported"},
+		call2.Name: {Name: call2.Name, ID: call2.ID, Reason: "disabled in config"},
+	}
+	for name, want := range want {
+		if diff := cmp.Diff(want, got[name]); diff != "" {
+			t.Errorf("%v: %v", name, diff)
+		}
+	}
+}
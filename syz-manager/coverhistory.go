@@ -0,0 +1,502 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/syzkaller/pkg/cover"
+	"github.com/google/syzkaller/pkg/html/pages"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/osutil"
+)
+
+// Coverage history (see mgrconfig.Config.CoverHistory) is stored in workdir/coverhistory.json
+// as a sequence of JSON records, one per line. Records are appended as they are made,
+// the file is truncated to the last coverHistoryMaxRecords records on start.
+// Per-file coverage is large and changes slowly, so Files of a record contain only files whose
+// coverage changed since the previous record (files that disappeared have zero counts).
+// Only the first record has complete per-file coverage. Records in memory use the same representation.
+
+const (
+	coverHistoryPeriod = time.Hour
+	// Two weeks of hourly records.
+	coverHistoryMaxRecords = 14 * 24
+	// Subsystems with fewer covered PCs before the restart are not checked for regressions,
+	// for them a few lost PCs would be a large relative drop.
+	coverRegressionMinPCs = 100
+)
+
+type coverHistoryRecord struct {
+	Time time.Time
+	// Start time of the manager run that made the record.
+	Start      time.Time
+	Tag        string
+	Subsystems map[string]cover.PCCount
+	Files      map[string]cover.PCCount
+}
+
+type coverRegression struct {
+	Subsystem string
+	Base      cover.PCCount
+	Cur       cover.PCCount
+}
+
+func (mgr *Manager) coverHistoryFile() string {
+	return filepath.Join(mgr.cfg.Workdir, "coverhistory.json")
+}
+
+func (mgr *Manager) loadCoverHistory() {
+	history, err := readCoverHistory(mgr.coverHistoryFile())
+	if err != nil {
+		log.Logf(0, "read %v coverage history records and got error: %v", len(history), err)
+	}
+	if len(history) > coverHistoryMaxRecords || err != nil {
+		history = truncateCoverHistory(history, coverHistoryMaxRecords)
+		if err := writeCoverHistory(mgr.coverHistoryFile(), history); err != nil {
+			log.Fatalf("failed to write coverage history: %v", err)
+		}
+	}
+	mgr.coverHistory = history
+	if len(history) != 0 {
+		base := *history[len(history)-1]
+		base.Files = coverHistoryFiles(history)
+		mgr.coverBase = &base
+		mgr.coverFiles = base.Files
+	}
+	log.Logf(0, "%-24v: %v", "coverage history", len(history))
+}
+
+func readCoverHistory(file string) ([]*coverHistoryRecord, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var history []*coverHistoryRecord
+	for dec := json.NewDecoder(bytes.NewReader(data)); ; {
+		rec := new(coverHistoryRecord)
+		if err := dec.Decode(rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				return history, nil
+			}
+			return history, err
+		}
+		history = append(history, rec)
+	}
+}
+
+func writeCoverHistory(file string, history []*coverHistoryRecord) error {
+	buf := new(bytes.Buffer)
+	for _, rec := range history {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(data, '\n'))
+	}
+	return osutil.WriteFile(file, buf.Bytes())
+}
+
+func appendCoverHistory(file string, rec *coverHistoryRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, osutil.DefaultFilePerm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// coverHistoryLoop records coverage every coverHistoryPeriod. Once the corpus is triaged,
+// it makes an additional record and checks it for regressions.
+func (mgr *Manager) coverHistoryLoop() {
+	rg, err := getReportGenerator(mgr.cfg, mgr.modules)
+	if err != nil {
+		log.Logf(0, "failed to initialize coverage history: %v", err)
+		return
+	}
+	var lastTime time.Time
+	for {
+		time.Sleep(time.Minute)
+		mgr.mu.Lock()
+		check := mgr.phase >= phaseTriagedCorpus && !mgr.coverRegressionsChecked
+		if !check && time.Since(lastTime) < coverHistoryPeriod {
+			mgr.mu.Unlock()
+			continue
+		}
+		progs := make([]cover.Prog, 0, len(mgr.corpus))
+		for _, inp := range mgr.corpus {
+			progs = append(progs, cover.Prog{PCs: coverToPCs(rg, inp.Cover)})
+		}
+		mgr.mu.Unlock()
+		summary := rg.Summary(progs)
+		lastTime = time.Now()
+		mgr.addCoverHistoryRecord(&coverHistoryRecord{
+			Time:       lastTime,
+			Start:      mgr.startTime,
+			Tag:        mgr.cfg.Tag,
+			Subsystems: summary.Subsystems,
+			Files:      summary.Files,
+		}, check)
+	}
+}
+
+// addCoverHistoryRecord adds a record with complete per-file coverage to the history.
+// mgr.coverFiles is used only by coverHistoryLoop, so it's not protected by mgr.mu.
+func (mgr *Manager) addCoverHistoryRecord(rec *coverHistoryRecord, check bool) {
+	files := rec.Files
+	rec.Files = diffCoverFiles(mgr.coverFiles, files)
+	mgr.coverFiles = files
+	if err := appendCoverHistory(mgr.coverHistoryFile(), rec); err != nil {
+		log.Logf(0, "failed to save coverage history: %v", err)
+	}
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	mgr.coverHistory = truncateCoverHistory(append(mgr.coverHistory, rec), coverHistoryMaxRecords)
+	if !check {
+		return
+	}
+	mgr.coverRegressionsChecked = true
+	if mgr.coverBase == nil {
+		return
+	}
+	mgr.coverRegressions = coverRegressions(mgr.coverBase, rec, mgr.cfg.CoverRegressionThreshold)
+	for _, reg := range mgr.coverRegressions {
+		log.Logf(0, "coverage regression in %v: %v covered PCs, %v before the restart",
+			reg.Subsystem, reg.Cur.Covered, reg.Base.Covered)
+	}
+}
+
+// diffCoverFiles returns per-file coverage changes from prev to cur.
+func diffCoverFiles(prev, cur map[string]cover.PCCount) map[string]cover.PCCount {
+	res := make(map[string]cover.PCCount)
+	for name, cnt := range cur {
+		if prevCnt, ok := prev[name]; !ok || prevCnt != cnt {
+			res[name] = cnt
+		}
+	}
+	for name := range prev {
+		if _, ok := cur[name]; !ok {
+			res[name] = cover.PCCount{}
+		}
+	}
+	return res
+}
+
+// applyCoverFiles applies per-file coverage changes to files that match filter (all if filter is nil).
+func applyCoverFiles(files, changes map[string]cover.PCCount, filter func(string) bool) {
+	for name, cnt := range changes {
+		if filter != nil && !filter(name) {
+			continue
+		}
+		if cnt == (cover.PCCount{}) {
+			delete(files, name)
+		} else {
+			files[name] = cnt
+		}
+	}
+}
+
+// coverHistoryFiles returns complete per-file coverage of the last record of the history.
+func coverHistoryFiles(history []*coverHistoryRecord) map[string]cover.PCCount {
+	files := make(map[string]cover.PCCount)
+	for _, rec := range history {
+		applyCoverFiles(files, rec.Files, nil)
+	}
+	return files
+}
+
+// truncateCoverHistory returns the last n records of the history.
+// The first returned record is a copy with complete per-file coverage.
+func truncateCoverHistory(history []*coverHistoryRecord, n int) []*coverHistoryRecord {
+	if len(history) <= n {
+		return history
+	}
+	cut := len(history) - n
+	first := *history[cut]
+	first.Files = coverHistoryFiles(history[:cut+1])
+	return append([]*coverHistoryRecord{&first}, history[cut+1:]...)
+}
+
+// coverRegressions returns subsystems whose coverage dropped by more than threshold percent in cur
+// compared to base. Subsystems that are missing in one of the records are ignored.
+func coverRegressions(base, cur *coverHistoryRecord, threshold int) []coverRegression {
+	var res []coverRegression
+	for name, baseCnt := range base.Subsystems {
+		curCnt, ok := cur.Subsystems[name]
+		if !ok || baseCnt.Covered < coverRegressionMinPCs {
+			continue
+		}
+		if (baseCnt.Covered-curCnt.Covered)*100 > baseCnt.Covered*threshold {
+			res = append(res, coverRegression{
+				Subsystem: name,
+				Base:      baseCnt,
+				Cur:       curCnt,
+			})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Subsystem < res[j].Subsystem
+	})
+	return res
+}
+
+func (mgr *Manager) httpCoverHistory(w http.ResponseWriter, r *http.Request) {
+	if !mgr.cfg.CoverHistory {
+		http.Error(w, "coverage history is not enabled", http.StatusInternalServerError)
+		return
+	}
+	mgr.mu.Lock()
+	history := mgr.coverHistory
+	base := mgr.coverBase
+	regressions := mgr.coverRegressions
+	initialized := mgr.modulesInitialized
+	mgr.mu.Unlock()
+	if len(history) == 0 {
+		http.Error(w, "no coverage history recorded yet", http.StatusInternalServerError)
+		return
+	}
+	data := &UICoverHistoryData{
+		Name:    mgr.cfg.Name,
+		Records: len(history),
+		From:    history[0].Time,
+		To:      history[len(history)-1].Time,
+	}
+	if base != nil {
+		data.BaseTime = base.Time
+		data.BaseTag = base.Tag
+	}
+	subsystemName := r.FormValue("subsystem")
+	if subsystemName == "" {
+		for _, reg := range regressions {
+			data.Regressions = append(data.Regressions, UICoverRegression{
+				Subsystem:   reg.Subsystem,
+				Base:        reg.Base.Covered,
+				Covered:     reg.Cur.Covered,
+				Total:       reg.Cur.Total,
+				DropPercent: (reg.Base.Covered - reg.Cur.Covered) * 100 / reg.Base.Covered,
+			})
+		}
+		var counts []map[string]cover.PCCount
+		for _, rec := range history {
+			counts = append(counts, rec.Subsystems)
+		}
+		var baseCounts map[string]cover.PCCount
+		if base != nil {
+			baseCounts = base.Subsystems
+		}
+		data.Rows = coverHistoryRows(counts, baseCounts)
+		for i := range data.Rows {
+			data.Rows[i].Link = "/coverhistory?subsystem=" + url.QueryEscape(data.Rows[i].Name)
+		}
+		executeTemplate(w, coverHistoryTemplate, data)
+		return
+	}
+	if !initialized {
+		http.Error(w, "coverage is not ready, please try again later after fuzzer started", http.StatusInternalServerError)
+		return
+	}
+	rg, err := getReportGenerator(mgr.cfg, mgr.modules)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to generate coverage profile: %v", err), http.StatusInternalServerError)
+		return
+	}
+	for _, subsystem := range rg.Subsystems() {
+		if subsystem.Name != subsystemName {
+			continue
+		}
+		data.Subsystem = subsystemName
+		filter := func(file string) bool {
+			return cover.FileInSubsystem(file, subsystem)
+		}
+		var counts []map[string]cover.PCCount
+		files := make(map[string]cover.PCCount)
+		for _, rec := range history {
+			applyCoverFiles(files, rec.Files, filter)
+			cur := make(map[string]cover.PCCount, len(files))
+			for name, cnt := range files {
+				cur[name] = cnt
+			}
+			counts = append(counts, cur)
+		}
+		var baseCounts map[string]cover.PCCount
+		if base != nil {
+			baseCounts = base.Files
+		}
+		data.Rows = coverHistoryRows(counts, baseCounts)
+		executeTemplate(w, coverHistoryTemplate, data)
+		return
+	}
+	http.Error(w, fmt.Sprintf("unknown subsystem %v", subsystemName), http.StatusBadRequest)
+}
+
+// coverHistoryRows returns time series of coverage of subsystems or files (counts has complete coverage
+// for each history record). Coverage of the last record is compared with base (if it's not nil).
+func coverHistoryRows(counts []map[string]cover.PCCount, base map[string]cover.PCCount) []UICoverHistoryRow {
+	names := make(map[string]bool)
+	for _, cnts := range counts {
+		for name := range cnts {
+			names[name] = true
+		}
+	}
+	var rows []UICoverHistoryRow
+	for name := range names {
+		row := UICoverHistoryRow{Name: name}
+		var series []int
+		for _, cnts := range counts {
+			series = append(series, cnts[name].Covered)
+		}
+		last := counts[len(counts)-1][name]
+		row.Covered, row.Total = last.Covered, last.Total
+		if base != nil {
+			baseCnt := base[name]
+			row.Base = fmt.Sprint(baseCnt.Covered)
+			row.Change = fmt.Sprintf("%+d", row.Covered-baseCnt.Covered)
+			if baseCnt.Covered != 0 {
+				row.Change += fmt.Sprintf(" (%+d%%)", (row.Covered-baseCnt.Covered)*100/baseCnt.Covered)
+			}
+		}
+		row.Points = sparkline(series)
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Name < rows[j].Name
+	})
+	return rows
+}
+
+const (
+	sparklineWidth  = 200
+	sparklineHeight = 30
+)
+
+// sparkline returns points of an SVG polyline that shows vals.
+func sparkline(vals []int) string {
+	maxVal := 1
+	for _, v := range vals {
+		if maxVal < v {
+			maxVal = v
+		}
+	}
+	var points []string
+	for i, v := range vals {
+		x := 0
+		if len(vals) > 1 {
+			x = i * sparklineWidth / (len(vals) - 1)
+		}
+		y := sparklineHeight - v*sparklineHeight/maxVal
+		points = append(points, fmt.Sprintf("%v,%v", x, y))
+	}
+	return strings.Join(points, " ")
+}
+
+type UICoverHistoryData struct {
+	Name        string
+	Subsystem   string
+	Records     int
+	From        time.Time
+	To          time.Time
+	BaseTime    time.Time
+	BaseTag     string
+	Regressions []UICoverRegression
+	Rows        []UICoverHistoryRow
+}
+
+type UICoverRegression struct {
+	Subsystem   string
+	Base        int
+	Covered     int
+	Total       int
+	DropPercent int
+}
+
+type UICoverHistoryRow struct {
+	Name    string
+	Link    string
+	Covered int
+	Total   int
+	Base    string // covered PCs in the last record before the restart
+	Change  string
+	Points  string
+}
+
+var coverHistoryTemplate = pages.Create(`
+<!doctype html>
+<html>
+<head>
+	<title>{{.Name }} syzkaller</title>
+	{{HEAD}}
+</head>
+<body>
+<b>Coverage history{{if $.Subsystem}} of {{$.Subsystem}}{{end}}:</b>
+{{$.Records}} records from {{formatTime $.From}} to {{formatTime $.To}}
+<br>
+{{if not $.BaseTime.IsZero}}
+	Compared with {{formatTime $.BaseTime}} before the restart{{if $.BaseTag}} ({{$.BaseTag}}){{end}}.
+	<br>
+{{end}}
+
+{{if $.Regressions}}
+<table class="list_table">
+	<caption>Coverage regressions after the restart:</caption>
+	<tr>
+		<th>Subsystem</th>
+		<th>Before</th>
+		<th>Now</th>
+		<th>Drop</th>
+	</tr>
+	{{range $r := $.Regressions}}
+	<tr>
+		<td><a href="/coverhistory?subsystem={{$r.Subsystem}}">{{$r.Subsystem}}</a></td>
+		<td>{{$r.Base}}</td>
+		<td>{{$r.Covered}} / {{$r.Total}}</td>
+		<td>{{$r.DropPercent}}%</td>
+	</tr>
+	{{end}}
+</table>
+{{end}}
+
+<table class="list_table">
+	<caption>Covered PCs:</caption>
+	<tr>
+		<th><a onclick="return sortTable(this, 'Name', textSort)" href="#">
+			{{if $.Subsystem}}File{{else}}Subsystem{{end}}
+		</a></th>
+		<th><a onclick="return sortTable(this, 'Covered', numSort)" href="#">Covered</a></th>
+		<th>Total</th>
+		<th><a onclick="return sortTable(this, 'Before', numSort)" href="#">Before restart</a></th>
+		<th><a onclick="return sortTable(this, 'Change', numSort)" href="#">Change</a></th>
+		<th>History</th>
+	</tr>
+	{{range $r := $.Rows}}
+	<tr>
+		<td>{{if $r.Link}}<a href="{{$r.Link}}">{{$r.Name}}</a>{{else}}{{$r.Name}}{{end}}</td>
+		<td>{{$r.Covered}}</td>
+		<td>{{$r.Total}}</td>
+		<td>{{$r.Base}}</td>
+		<td>{{$r.Change}}</td>
+		<td><svg width="200" height="30"><polyline points="{{$r.Points}}" fill="none" stroke="black"/></svg></td>
+	</tr>
+	{{end}}
+</table>
+</body></html>
+`)
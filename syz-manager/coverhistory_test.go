@@ -0,0 +1,154 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/syzkaller/pkg/cover"
+)
+
+func TestCoverRegressions(t *testing.T) {
+	base := &coverHistoryRecord{
+		Subsystems: map[string]cover.PCCount{
+			"all":   {Covered: 10000, Total: 100000},
+			"fs":    {Covered: 1000, Total: 5000},
+			"net":   {Covered: 1000, Total: 5000},
+			"sound": {Covered: 50, Total: 1000},
+			"mm":    {Covered: 500, Total: 1000},
+		},
+	}
+	cur := &coverHistoryRecord{
+		Subsystems: map[string]cover.PCCount{
+			"all": {Covered: 9500, Total: 100000},
+			// Exactly at the threshold, not a regression.
+			"fs":  {Covered: 900, Total: 5000},
+			"net": {Covered: 899, Total: 5000},
+			// Too few PCs to be checked.
+			"sound": {Covered: 0, Total: 1000},
+			"usb":   {Covered: 0, Total: 1000},
+		},
+	}
+	got := coverRegressions(base, cur, 10)
+	want := []coverRegression{
+		{Subsystem: "net", Base: base.Subsystems["net"], Cur: cur.Subsystems["net"]},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestCoverHistoryFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "coverhistory.json")
+	history, err := readCoverHistory(file)
+	if err != nil || len(history) != 0 {
+		t.Fatalf("read non-existent history: %v, %v", history, err)
+	}
+	start := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	var want []*coverHistoryRecord
+	for i := 0; i < 3; i++ {
+		rec := &coverHistoryRecord{
+			Time:       start.Add(time.Duration(i) * time.Hour),
+			Start:      start,
+			Tag:        "tag",
+			Subsystems: map[string]cover.PCCount{"all": {Covered: i, Total: 10}},
+			Files:      map[string]cover.PCCount{"fs/open.c": {Covered: i, Total: 5}},
+		}
+		if err := appendCoverHistory(file, rec); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, rec)
+	}
+	history, err = readCoverHistory(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(history, want) {
+		t.Fatalf("got %+v\nwant %+v", history, want)
+	}
+	if err := writeCoverHistory(file, want[1:]); err != nil {
+		t.Fatal(err)
+	}
+	history, err = readCoverHistory(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(history, want[1:]) {
+		t.Fatalf("got %+v\nwant %+v", history, want[1:])
+	}
+}
+
+func TestCoverHistoryFiles(t *testing.T) {
+	files := []map[string]cover.PCCount{
+		{"fs/open.c": {Covered: 1, Total: 5}, "net/socket.c": {Covered: 2, Total: 5}},
+		{"fs/open.c": {Covered: 1, Total: 5}, "net/socket.c": {Covered: 3, Total: 5}},
+		{"fs/open.c": {Covered: 2, Total: 5}},
+		{"fs/open.c": {Covered: 2, Total: 5}, "mm/mmap.c": {Covered: 1, Total: 5}},
+	}
+	var history []*coverHistoryRecord
+	var prev map[string]cover.PCCount
+	for i, cur := range files {
+		history = append(history, &coverHistoryRecord{
+			Tag:   fmt.Sprint(i),
+			Files: diffCoverFiles(prev, cur),
+		})
+		prev = cur
+	}
+	wantDiffs := []map[string]cover.PCCount{
+		files[0],
+		{"net/socket.c": {Covered: 3, Total: 5}},
+		{"fs/open.c": {Covered: 2, Total: 5}, "net/socket.c": {}},
+		{"mm/mmap.c": {Covered: 1, Total: 5}},
+	}
+	for i, rec := range history {
+		if !reflect.DeepEqual(rec.Files, wantDiffs[i]) {
+			t.Errorf("record #%v: got %+v, want %+v", i, rec.Files, wantDiffs[i])
+		}
+	}
+	for i := range history {
+		if got := coverHistoryFiles(history[:i+1]); !reflect.DeepEqual(got, files[i]) {
+			t.Errorf("files after record #%v: got %+v, want %+v", i, got, files[i])
+		}
+	}
+	truncated := truncateCoverHistory(history, 2)
+	if len(truncated) != 2 || truncated[0].Tag != "2" || truncated[1] != history[3] {
+		t.Fatalf("bad truncated history: %+v", truncated)
+	}
+	if !reflect.DeepEqual(truncated[0].Files, files[2]) {
+		t.Errorf("truncated history starts with %+v, want %+v", truncated[0].Files, files[2])
+	}
+	if !reflect.DeepEqual(history[2].Files, wantDiffs[2]) {
+		t.Errorf("truncation changed the original record: %+v", history[2].Files)
+	}
+	if got := coverHistoryFiles(truncated); !reflect.DeepEqual(got, files[3]) {
+		t.Errorf("files after truncation: got %+v, want %+v", got, files[3])
+	}
+	fsFiles := make(map[string]cover.PCCount)
+	for _, rec := range history {
+		applyCoverFiles(fsFiles, rec.Files, func(file string) bool { return file == "fs/open.c" })
+	}
+	if want := map[string]cover.PCCount{"fs/open.c": {Covered: 2, Total: 5}}; !reflect.DeepEqual(fsFiles, want) {
+		t.Errorf("filtered files: got %+v, want %+v", fsFiles, want)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		vals []int
+		want string
+	}{
+		{[]int{5}, "0,0"},
+		{[]int{0, 0}, "0,30 200,30"},
+		{[]int{0, 50, 100}, "0,30 100,15 200,0"},
+	}
+	for _, test := range tests {
+		if got := sparkline(test.vals); got != test.want {
+			t.Errorf("sparkline(%v) = %q, want %q", test.vals, got, test.want)
+		}
+	}
+}
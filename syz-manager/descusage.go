@@ -0,0 +1,334 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/google/syzkaller/pkg/ast"
+	"github.com/google/syzkaller/pkg/compiler"
+	"github.com/google/syzkaller/pkg/html/pages"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/mgrconfig"
+	"github.com/google/syzkaller/prog"
+)
+
+// Description usage shows which parts of syscall descriptions (struct fields, union options, flags values)
+// are used by the corpus. Corpus programs are minimized, so arguments that don't affect coverage are reset
+// to default values during minimization. As the result, elements that are not used by any corpus program
+// either are never generated, or never lead to new coverage. Both are worth looking at.
+
+type callDescUsage struct {
+	inputs int
+	elems  []prog.DescElem
+	used   map[prog.DescElem]bool
+}
+
+// collectDescUsage returns description usage for the enabled calls. Only the calls that produced new signal
+// (according to the input updates) are accounted for each corpus program, other calls are present only
+// to set up state for them. For inputs without updates all calls with the input call name are accounted.
+func collectDescUsage(target *prog.Target, enabled []*prog.Syscall,
+	corpus map[string]CorpusItem) (map[string]*callDescUsage, error) {
+	calls := make(map[string]*callDescUsage)
+	for _, meta := range enabled {
+		calls[meta.Name] = &callDescUsage{
+			elems: meta.DescElems(),
+			used:  make(map[prog.DescElem]bool),
+		}
+	}
+	for _, inp := range corpus {
+		usage := calls[inp.Call]
+		if usage == nil {
+			continue
+		}
+		p, err := target.Deserialize(inp.Prog, prog.NonStrict)
+		if err != nil {
+			return nil, fmt.Errorf("failed to deserialize program: %v", err)
+		}
+		usage.inputs++
+		callIDs := make(map[int]bool)
+		for _, update := range inp.Updates {
+			callIDs[update.CallID] = true
+		}
+		for id, c := range p.Calls {
+			if c.Meta.Name != inp.Call || len(inp.Updates) != 0 && !callIDs[id] {
+				continue
+			}
+			for elem := range c.UsedDescElems() {
+				usage.used[elem] = true
+			}
+		}
+	}
+	return calls, nil
+}
+
+func (usage *callDescUsage) unused() []prog.DescElem {
+	var res []prog.DescElem
+	for _, elem := range usage.elems {
+		if !usage.used[elem] {
+			res = append(res, elem)
+		}
+	}
+	return res
+}
+
+// descLocations maps description elements to their positions in the descriptions.
+type descLocations struct {
+	elems map[prog.DescElem]descLocation
+	// Declarations of syscalls, structs, unions and flags for elements without own positions.
+	decls map[string]ast.Pos
+}
+
+type descLocation struct {
+	pos ast.Pos
+	// For flags values: the const name as written in the descriptions.
+	name string
+}
+
+var getDescLocations = func() func(cfg *mgrconfig.Config) (*descLocations, error) {
+	var once sync.Once
+	var locs *descLocations
+	var err error
+	return func(cfg *mgrconfig.Config) (*descLocations, error) {
+		once.Do(func() {
+			log.Logf(0, "parsing descriptions...")
+			dir := filepath.Join(cfg.Syzkaller, "sys", cfg.TargetOS)
+			desc := ast.ParseGlob(filepath.Join(dir, "*.txt"), nil)
+			if desc == nil {
+				err = fmt.Errorf("failed to parse descriptions in %v", dir)
+				return
+			}
+			// Const files are used only to find positions of flags values, so missing consts are fine.
+			var consts map[string]uint64
+			if constFile := compiler.DeserializeConstFile(filepath.Join(dir, "*.const"), nil); constFile != nil {
+				consts = constFile.Arch(cfg.TargetArch)
+			}
+			locs = makeDescLocations(desc, consts)
+		})
+		return locs, err
+	}
+}()
+
+func makeDescLocations(desc *ast.Description, consts map[string]uint64) *descLocations {
+	locs := &descLocations{
+		elems: make(map[prog.DescElem]descLocation),
+		decls: make(map[string]ast.Pos),
+	}
+	addFields := func(typ string, kind prog.DescElemKind, fields []*ast.Field) {
+		for _, field := range fields {
+			elem := prog.DescElem{Kind: kind, Type: typ, Name: field.Name.Name}
+			locs.elems[elem] = descLocation{pos: field.Pos}
+		}
+	}
+	addStruct := func(name string, n *ast.Struct) {
+		kind := prog.DescField
+		if n.IsUnion {
+			kind = prog.DescUnionOption
+		}
+		locs.decls[name] = n.Pos
+		addFields(name, kind, n.Fields)
+	}
+	for _, node := range desc.Nodes {
+		switch n := node.(type) {
+		case *ast.Call:
+			locs.decls[n.Name.Name] = n.Pos
+			addFields(n.Name.Name, prog.DescField, n.Args)
+		case *ast.Struct:
+			addStruct(n.Name.Name, n)
+		case *ast.TypeDef:
+			if n.Struct != nil {
+				addStruct(n.Name.Name, n.Struct)
+			}
+		case *ast.IntFlags:
+			locs.decls[n.Name.Name] = n.Pos
+			for _, v := range n.Values {
+				val, name := v.Value, ""
+				if v.Ident != "" {
+					var ok bool
+					if val, ok = consts[v.Ident]; !ok {
+						continue
+					}
+					name = v.Ident
+				}
+				elem := prog.DescElem{Kind: prog.DescFlagsValue, Type: n.Name.Name, Val: val}
+				locs.elems[elem] = descLocation{pos: v.Pos, name: name}
+			}
+		}
+	}
+	return locs
+}
+
+func (locs *descLocations) find(elem prog.DescElem) descLocation {
+	if loc, ok := locs.elems[elem]; ok {
+		return loc
+	}
+	return descLocation{pos: locs.decls[elem.Type]}
+}
+
+func (mgr *Manager) httpDescUsage(w http.ResponseWriter, r *http.Request) {
+	mgr.mu.Lock()
+	var enabled []*prog.Syscall
+	for meta := range mgr.targetEnabledSyscalls {
+		enabled = append(enabled, meta)
+	}
+	// Programs are deserialized outside of the lock, corpus items are not mutated in place.
+	corpus := make(map[string]CorpusItem, len(mgr.corpus))
+	for sig, inp := range mgr.corpus {
+		corpus[sig] = inp
+	}
+	mgr.mu.Unlock()
+	calls, err := collectDescUsage(mgr.target, enabled, corpus)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	callName := r.FormValue("call")
+	if callName == "" {
+		data := &UIDescUsageData{Name: mgr.cfg.Name}
+		for name, usage := range calls {
+			data.Calls = append(data.Calls, UIDescUsageCall{
+				Name:   name,
+				Inputs: usage.inputs,
+				Elems:  len(usage.elems),
+				Unused: len(usage.unused()),
+			})
+		}
+		sort.Slice(data.Calls, func(i, j int) bool {
+			return data.Calls[i].Name < data.Calls[j].Name
+		})
+		executeTemplate(w, descUsageTemplate, data)
+		return
+	}
+	usage := calls[callName]
+	if usage == nil {
+		http.Error(w, fmt.Sprintf("unknown or disabled call: %v", callName), http.StatusInternalServerError)
+		return
+	}
+	locs, err := getDescLocations(mgr.cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data := &UIDescUsageCallData{
+		Call:   callName,
+		Inputs: usage.inputs,
+		Elems:  len(usage.elems),
+	}
+	for _, elem := range usage.unused() {
+		loc := locs.find(elem)
+		ui := UIDescElem{
+			Type: elem.Type,
+			Name: elem.Name,
+		}
+		switch elem.Kind {
+		case prog.DescField:
+			ui.Kind = "field"
+		case prog.DescUnionOption:
+			ui.Kind = "union option"
+		case prog.DescFlagsValue:
+			ui.Kind = "flags value"
+			ui.Name = fmt.Sprintf("0x%x", elem.Val)
+			if loc.name != "" {
+				ui.Name = fmt.Sprintf("%v (%v)", loc.name, ui.Name)
+			}
+		}
+		if loc.pos.File != "" {
+			file, err := filepath.Rel(mgr.cfg.Syzkaller, loc.pos.File)
+			if err != nil {
+				file = loc.pos.File
+			}
+			ui.Location = fmt.Sprintf("%v:%v", file, loc.pos.Line)
+		}
+		data.Unused = append(data.Unused, ui)
+	}
+	executeTemplate(w, descUsageCallTemplate, data)
+}
+
+type UIDescUsageData struct {
+	Name  string
+	Calls []UIDescUsageCall
+}
+
+type UIDescUsageCall struct {
+	Name   string
+	Inputs int
+	Elems  int
+	Unused int
+}
+
+type UIDescUsageCallData struct {
+	Call   string
+	Inputs int
+	Elems  int
+	Unused []UIDescElem
+}
+
+type UIDescElem struct {
+	Kind     string
+	Type     string
+	Name     string
+	Location string
+}
+
+var descUsageTemplate = pages.Create(`
+<!doctype html>
+<html>
+<head>
+	<title>{{.Name }} syzkaller</title>
+	{{HEAD}}
+</head>
+<body>
+
+<table class="list_table">
+	<caption>Description elements used by corpus:</caption>
+	<tr>
+		<th><a onclick="return sortTable(this, 'Syscall', textSort)" href="#">Syscall</a></th>
+		<th><a onclick="return sortTable(this, 'Inputs', numSort)" href="#">Inputs</a></th>
+		<th><a onclick="return sortTable(this, 'Elements', numSort)" href="#">Elements</a></th>
+		<th><a onclick="return sortTable(this, 'Unused', numSort)" href="#">Unused</a></th>
+	</tr>
+	{{range $c := $.Calls}}
+	<tr>
+		<td>{{$c.Name}}</td>
+		<td><a href='/corpus?call={{$c.Name}}'>{{$c.Inputs}}</a></td>
+		<td>{{$c.Elems}}</td>
+		<td><a href='/descusage?call={{$c.Name}}'>{{$c.Unused}}</a></td>
+	</tr>
+	{{end}}
+</table>
+</body></html>
+`)
+
+var descUsageCallTemplate = pages.Create(`
+<!doctype html>
+<html>
+<head>
+	<title>syzkaller description usage</title>
+	{{HEAD}}
+</head>
+<body>
+
+<table class="list_table">
+	<caption>Elements of {{$.Call}} not used by {{$.Inputs}} corpus inputs ({{len $.Unused}} out of {{$.Elems}}):</caption>
+	<tr>
+		<th><a onclick="return sortTable(this, 'Kind', textSort)" href="#">Kind</a></th>
+		<th><a onclick="return sortTable(this, 'Type', textSort)" href="#">Type</a></th>
+		<th><a onclick="return sortTable(this, 'Element', textSort)" href="#">Element</a></th>
+		<th><a onclick="return sortTable(this, 'Location', textSort)" href="#">Location</a></th>
+	</tr>
+	{{range $e := $.Unused}}
+	<tr>
+		<td>{{$e.Kind}}</td>
+		<td>{{$e.Type}}</td>
+		<td>{{$e.Name}}</td>
+		<td>{{$e.Location}}</td>
+	</tr>
+	{{end}}
+</table>
+</body></html>
+`)
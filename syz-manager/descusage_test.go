@@ -0,0 +1,108 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/syzkaller/pkg/ast"
+	"github.com/google/syzkaller/prog"
+	_ "github.com/google/syzkaller/sys"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func TestCollectDescUsage(t *testing.T) {
+	target, err := prog.GetTarget(targets.TestOS, targets.TestArch64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corpus := map[string]CorpusItem{
+		"a": {
+			Call: "mutate_flags",
+			Prog: []byte("mutate_flags(0x0, 0x1, 0x0, 0x1)\n"),
+		},
+		"b": {
+			Call: "mutate_flags",
+			Prog: []byte("mutate_flags(0x0, 0x0, 0x0, 0x0)\nmutate_flags(0x0, 0x0, 0x0, 0x8)\n" +
+				"mutate_flags(0x0, 0x0, 0x0, 0x10)\n"),
+			// The last call only sets up state, it did not produce new signal.
+			Updates: []CorpusItemUpdate{{CallID: 0}, {CallID: 1}},
+		},
+		"c": {
+			// The call that produced signal is not enabled, so the program is ignored.
+			Call: "mutate_flags2",
+			Prog: []byte("mutate_flags(0x0, 0x0, 0x1, 0x10)\nmutate_flags2(0x0, 0x0)\n"),
+		},
+	}
+	enabled := []*prog.Syscall{target.SyscallMap["mutate_flags"]}
+	calls, err := collectDescUsage(target, enabled, corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage := calls["mutate_flags"]
+	if usage == nil || len(calls) != 1 {
+		t.Fatalf("bad calls: %+v", calls)
+	}
+	if usage.inputs != 2 {
+		t.Errorf("got %v inputs, want 2", usage.inputs)
+	}
+	want := []prog.DescElem{
+		{Kind: prog.DescField, Type: "mutate_flags", Name: "b1"},
+		{Kind: prog.DescFlagsValue, Type: "bitmask_flags", Val: 0x10},
+	}
+	if diff := cmp.Diff(want, usage.unused()); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestDescLocations(t *testing.T) {
+	desc := ast.Parse([]byte(`
+foo(a int32, b ptr[in, foo_struct])
+
+foo_struct {
+	f0	flags[foo_flags, int32]
+	f1	foo_union
+}
+
+foo_union [
+	u0	int8
+	u1	tmpl[int8]
+]
+
+type tmpl[T] {
+	t	T
+}
+
+foo_flags = 1, FOO_CONST, FOO_MISSING
+`), "foo.txt", nil)
+	if desc == nil {
+		t.Fatal("failed to parse")
+	}
+	locs := makeDescLocations(desc, map[string]uint64{"FOO_CONST": 2})
+	type result struct {
+		Line int
+		Name string
+	}
+	tests := []struct {
+		elem prog.DescElem
+		res  result
+	}{
+		{prog.DescElem{Kind: prog.DescField, Type: "foo", Name: "b"}, result{2, ""}},
+		{prog.DescElem{Kind: prog.DescField, Type: "foo_struct", Name: "f1"}, result{6, ""}},
+		{prog.DescElem{Kind: prog.DescUnionOption, Type: "foo_union", Name: "u1"}, result{11, ""}},
+		{prog.DescElem{Kind: prog.DescField, Type: "tmpl", Name: "t"}, result{15, ""}},
+		{prog.DescElem{Kind: prog.DescFlagsValue, Type: "foo_flags", Val: 1}, result{18, ""}},
+		{prog.DescElem{Kind: prog.DescFlagsValue, Type: "foo_flags", Val: 2}, result{18, "FOO_CONST"}},
+		// Values of unknown consts point to the flags declaration.
+		{prog.DescElem{Kind: prog.DescFlagsValue, Type: "foo_flags", Val: 3}, result{18, ""}},
+		{prog.DescElem{Kind: prog.DescField, Type: "unknown", Name: "f"}, result{0, ""}},
+	}
+	for _, test := range tests {
+		loc := locs.find(test.elem)
+		if res := (result{loc.pos.Line, loc.name}); res != test.res {
+			t.Errorf("%v: got %+v, want %+v", test.elem, res, test.res)
+		}
+	}
+}
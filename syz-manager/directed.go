@@ -0,0 +1,137 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+
+	"github.com/google/syzkaller/pkg/cover/backend"
+	"github.com/google/syzkaller/pkg/log"
+)
+
+// Distances sent to fuzzers are multiplied by this number to fit them into integers.
+const targetDistanceScale = 100
+
+// createTargetDistances computes distances from coverage points to the targets of directed fuzzing
+// (see mgrconfig.Config.Directed). The result maps coverage PCs (as they are reported by the executor)
+// to distances scaled by targetDistanceScale, PCs that can't reach any of the targets are not present.
+// Distances are function-level: all PCs of a function get the distance of the function.
+// We don't have intra-procedural control flow graphs, so unlike AFLGo we don't distinguish
+// basic blocks that lead to a call on the path to a target from the rest of the function.
+func (mgr *Manager) createTargetDistances() (map[uint32]uint32, error) {
+	if len(mgr.cfg.Directed.Functions)+len(mgr.cfg.Directed.Files) == 0 {
+		return nil, nil
+	}
+	rg, err := getReportGenerator(mgr.cfg, mgr.modules)
+	if err != nil {
+		return nil, err
+	}
+	funcs, err := compileRegexps(mgr.cfg.Directed.Functions)
+	if err != nil {
+		return nil, err
+	}
+	files, err := compileRegexps(mgr.cfg.Directed.Files)
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[*regexp.Regexp]bool)
+	match := func(res []*regexp.Regexp, name string) bool {
+		for _, re := range res {
+			if re.MatchString(name) {
+				used[re] = true
+				return true
+			}
+		}
+		return false
+	}
+	ntargets := 0
+	isTarget := func(sym *backend.Symbol) bool {
+		// Note: we don't short-circuit, so that all regexps are marked as used.
+		target := match(funcs, sym.Name)
+		if sym.Unit != nil && match(files, sym.Unit.Name) {
+			target = true
+		}
+		if target {
+			ntargets++
+		}
+		return target
+	}
+	start := time.Now()
+	distances := symbolDistances(rg.Symbols, isTarget)
+	if len(used) != len(funcs)+len(files) {
+		return nil, fmt.Errorf("some directed fuzzing targets don't match anything")
+	}
+	res := make(map[uint32]uint32)
+	for _, sym := range rg.Symbols {
+		dist, ok := distances[sym]
+		if !ok {
+			continue
+		}
+		for _, pc := range sym.PCs {
+			pc = backend.NextInstructionPC(mgr.cfg.SysTarget, pc)
+			res[uint32(pc)] = uint32(math.Round(dist * targetDistanceScale))
+		}
+	}
+	log.Logf(0, "directed fuzzing: %v target functions, %v functions and %v PCs reach them (took %v)",
+		ntargets, len(distances), len(res), time.Since(start))
+	return res, nil
+}
+
+// symbolDistances returns distances from functions to the targets over the call graph.
+// Functions that can't reach any target are not present in the result.
+// As in AFLGo, the distance of a function is the harmonic mean of the shortest path lengths
+// to all reachable targets (without division by the number of targets), which favors functions
+// that are close to at least one target, but also reach more targets. Targets have distance 0.
+func symbolDistances(symbols []*backend.Symbol, isTarget func(*backend.Symbol) bool) map[*backend.Symbol]float64 {
+	// Symbols without coverage points are not present in symbols, but may be callees.
+	callers := make(map[*backend.Symbol][]*backend.Symbol)
+	visited := make(map[*backend.Symbol]bool)
+	var visit func(sym *backend.Symbol)
+	visit = func(sym *backend.Symbol) {
+		if visited[sym] {
+			return
+		}
+		visited[sym] = true
+		for _, callee := range sym.Callees {
+			callers[callee] = append(callers[callee], sym)
+			visit(callee)
+		}
+	}
+	var targets []*backend.Symbol
+	for _, sym := range symbols {
+		visit(sym)
+		if isTarget(sym) {
+			targets = append(targets, sym)
+		}
+	}
+	sums := make(map[*backend.Symbol]float64)
+	res := make(map[*backend.Symbol]float64)
+	for _, target := range targets {
+		res[target] = 0
+		// Breadth-first search over the reversed call graph.
+		dist := map[*backend.Symbol]int{target: 0}
+		queue := []*backend.Symbol{target}
+		for len(queue) != 0 {
+			sym := queue[0]
+			queue = queue[1:]
+			for _, caller := range callers[sym] {
+				if _, ok := dist[caller]; ok {
+					continue
+				}
+				dist[caller] = dist[sym] + 1
+				sums[caller] += 1 / float64(dist[caller])
+				queue = append(queue, caller)
+			}
+		}
+	}
+	for sym, sum := range sums {
+		if _, ok := res[sym]; !ok {
+			res[sym] = 1 / sum
+		}
+	}
+	return res
+}
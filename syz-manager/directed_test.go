@@ -0,0 +1,49 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/syzkaller/pkg/cover/backend"
+)
+
+func TestSymbolDistances(t *testing.T) {
+	sym := func(name string, callees ...*backend.Symbol) *backend.Symbol {
+		return &backend.Symbol{ObjectUnit: backend.ObjectUnit{Name: name}, Callees: callees}
+	}
+	target1 := sym("target1")
+	target2 := sym("target2")
+	// Does not have coverage points, so is not present in the symbol list.
+	hidden := sym("hidden", target2)
+	foo := sym("foo", target1)
+	bar := sym("bar", foo, hidden)
+	baz := sym("baz", target1, target2)
+	unrelated := sym("unrelated", sym("leaf"))
+	loop := sym("loop", bar)
+	loop.Callees = append(loop.Callees, loop)
+	symbols := []*backend.Symbol{target1, target2, foo, bar, baz, unrelated, loop}
+	dist := symbolDistances(symbols, func(s *backend.Symbol) bool {
+		return s == target1 || s == target2
+	})
+	want := map[*backend.Symbol]float64{
+		target1: 0,
+		target2: 0,
+		foo:     1,
+		hidden:  1,
+		bar:     1.0 / (1.0/2 + 1.0/2),
+		baz:     1.0 / (1.0/1 + 1.0/1),
+		loop:    1.0 / (1.0/3 + 1.0/3),
+	}
+	if len(dist) != len(want) {
+		t.Errorf("got %v distances, want %v", len(dist), len(want))
+	}
+	for s, d := range want {
+		got, ok := dist[s]
+		if !ok || math.Abs(got-d) > 1e-9 {
+			t.Errorf("%v: got distance %v (%v), want %v", s.Name, got, ok, d)
+		}
+	}
+}
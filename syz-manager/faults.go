@@ -0,0 +1,160 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+
+	"github.com/google/syzkaller/pkg/db"
+	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/rpctype"
+	"github.com/google/syzkaller/prog"
+)
+
+// Results of fault injection sweeps (see mgrconfig.Config.FaultCampaign) are stored
+// in workdir/faults.db keyed by the hash of the swept corpus program.
+
+func (mgr *Manager) loadFaultSweeps() {
+	faultsDB, err := db.Open(filepath.Join(mgr.cfg.Workdir, "faults.db"), true)
+	if err != nil {
+		if faultsDB == nil {
+			log.Fatalf("failed to open faults database: %v", err)
+		}
+		log.Logf(0, "read %v fault sweeps and got error: %v", len(faultsDB.Records), err)
+	}
+	mgr.faultsDB = faultsDB
+	for key, rec := range faultsDB.Records {
+		sweep := new(rpctype.FaultSweep)
+		if err := json.Unmarshal(rec.Val, sweep); err != nil {
+			log.Logf(0, "failed to parse fault sweep %v: %v", key, err)
+			faultsDB.Delete(key)
+			continue
+		}
+		mgr.faultSweeps[key] = sweep
+	}
+	log.Logf(0, "%-24v: %v", "fault sweeps", len(mgr.faultSweeps))
+}
+
+func (mgr *Manager) newFaultSweeps(sweeps []rpctype.FaultSweep) {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	for i := range sweeps {
+		sweep := &sweeps[i]
+		if old := mgr.faultSweeps[sweep.Sig]; old != nil {
+			// Crashes could be detected while the program was swept by another fuzzer.
+			for _, call := range old.Calls {
+				for _, nth := range call.Crashed {
+					addCrashedFault(sweep, call.Call, nth)
+				}
+			}
+		}
+		mgr.saveFaultSweep(sweep)
+	}
+	if err := mgr.faultsDB.Flush(); err != nil {
+		log.Logf(0, "failed to save faults database: %v", err)
+	}
+}
+
+// saveCrashedFaults records fault sites that crashed the kernel during a fault sweep,
+// so that they are skipped by subsequent sweeps of the same program.
+func (mgr *Manager) saveCrashedFaults(output []byte) {
+	sites := crashedFaultSites(mgr.target, output)
+	if len(sites) == 0 {
+		return
+	}
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	for _, site := range sites {
+		if _, ok := mgr.corpus[site.sig]; !ok {
+			continue
+		}
+		sweep := mgr.faultSweeps[site.sig]
+		if sweep == nil {
+			sweep = &rpctype.FaultSweep{Sig: site.sig}
+		}
+		log.Logf(0, "fault sweep of %v crashed at call %v/%v", site.sig, site.call, site.nth)
+		addCrashedFault(sweep, site.call, site.nth)
+		mgr.saveFaultSweep(sweep)
+	}
+	if err := mgr.faultsDB.Flush(); err != nil {
+		log.Logf(0, "failed to save faults database: %v", err)
+	}
+}
+
+func (mgr *Manager) saveFaultSweep(sweep *rpctype.FaultSweep) {
+	data, err := json.Marshal(sweep)
+	if err != nil {
+		panic(err)
+	}
+	mgr.faultSweeps[sweep.Sig] = sweep
+	mgr.faultsDB.Save(sweep.Sig, data, 0)
+}
+
+func addCrashedFault(sweep *rpctype.FaultSweep, call, nth int) {
+	for i := range sweep.Calls {
+		res := &sweep.Calls[i]
+		if res.Call != call {
+			continue
+		}
+		for _, v := range res.Crashed {
+			if v == nth {
+				return
+			}
+		}
+		res.Crashed = append(res.Crashed, nth)
+		return
+	}
+	sweep.Calls = append(sweep.Calls, rpctype.FaultCall{Call: call, Crashed: []int{nth}})
+	sort.Slice(sweep.Calls, func(i, j int) bool {
+		return sweep.Calls[i].Call < sweep.Calls[j].Call
+	})
+}
+
+type faultSite struct {
+	sig  string // hash of the swept program
+	call int
+	nth  int
+}
+
+// crashedFaultSites returns fault sites that were being injected when the kernel crashed.
+// These are the last programs executed by each proc that have exactly one call with fail_nth.
+func crashedFaultSites(target *prog.Target, output []byte) []faultSite {
+	var sites []faultSite
+	entries := target.ParseLog(output)
+	seenProcs := make(map[int]bool)
+	for i := len(entries) - 1; i >= 0; i-- {
+		ent := entries[i]
+		if seenProcs[ent.Proc] {
+			continue
+		}
+		seenProcs[ent.Proc] = true
+		call := -1
+		for j, c := range ent.P.Calls {
+			if c.Props.FailNth == 0 {
+				continue
+			}
+			if call != -1 {
+				// Sweeps inject a single fault at a time.
+				call = -1
+				break
+			}
+			call = j
+		}
+		if call == -1 {
+			continue
+		}
+		p := ent.P.Clone()
+		nth := p.Calls[call].Props.FailNth
+		p.Calls[call].Props.FailNth = 0
+		sites = append(sites, faultSite{
+			sig:  hash.String(p.Serialize()),
+			call: call,
+			nth:  nth,
+		})
+	}
+	return sites
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/syzkaller/pkg/db"
+	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/pkg/mgrconfig"
+	"github.com/google/syzkaller/pkg/rpctype"
+	"github.com/google/syzkaller/prog"
+	_ "github.com/google/syzkaller/sys"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func TestCrashedFaultSites(t *testing.T) {
+	target, err := prog.GetTarget(targets.TestOS, targets.TestArch64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog0 := []byte("test$int(0x1, 0x2, 0x3, 0x4, 0x5)\ntest$opt0(0x0)\n")
+	prog1 := []byte("test$opt1(0x0)\n")
+	output := []byte(`
+executing program 0:
+test$int(0x1, 0x2, 0x3, 0x4, 0x5)
+test$opt0(0x0) (fail_nth: 2)
+
+executing program 1:
+test$opt1(0x0) (fail_nth: 1)
+
+executing program 0:
+test$int(0x1, 0x2, 0x3, 0x4, 0x5)
+test$opt0(0x0) (fail_nth: 3)
+
+executing program 2:
+test$int(0x1, 0x2, 0x3, 0x4, 0x5) (fail_nth: 1)
+test$opt0(0x0) (fail_nth: 1)
+
+executing program 3:
+test$opt1(0x0) (fail_nth: 5)
+
+executing program 3:
+test$opt1(0x0)
+
+BUG: bad
+`)
+	want := []faultSite{
+		{sig: hash.String(prog0), call: 1, nth: 3},
+		{sig: hash.String(prog1), call: 0, nth: 1},
+	}
+	got := crashedFaultSites(target, output)
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(faultSite{})); diff != "" {
+		t.Fatal(diff)
+	}
+
+	dir := t.TempDir()
+	faultsDB, err := db.Open(filepath.Join(dir, "faults.db"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &Manager{
+		cfg:         &mgrconfig.Config{Workdir: dir},
+		target:      target,
+		faultsDB:    faultsDB,
+		faultSweeps: make(map[string]*rpctype.FaultSweep),
+		corpus: map[string]CorpusItem{
+			hash.String(prog0): {Prog: prog0},
+		},
+	}
+	mgr.saveCrashedFaults(output)
+	mgr.newFaultSweeps([]rpctype.FaultSweep{{
+		Sig:   hash.String(prog0),
+		Done:  true,
+		Calls: []rpctype.FaultCall{{Call: 0, Sites: 4, NewSignal: []int{2}}},
+	}})
+	wantSweeps := map[string]*rpctype.FaultSweep{
+		hash.String(prog0): {
+			Sig:  hash.String(prog0),
+			Done: true,
+			Calls: []rpctype.FaultCall{
+				{Call: 0, Sites: 4, NewSignal: []int{2}},
+				{Call: 1, Crashed: []int{3}},
+			},
+		},
+	}
+	if diff := cmp.Diff(wantSweeps, mgr.faultSweeps); diff != "" {
+		t.Fatal(diff)
+	}
+	mgr.faultSweeps = make(map[string]*rpctype.FaultSweep)
+	mgr.loadFaultSweeps()
+	if diff := cmp.Diff(wantSweeps, mgr.faultSweeps); diff != "" {
+		t.Fatalf("fault sweeps were not persisted: %v", diff)
+	}
+}
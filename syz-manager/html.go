@@ -23,6 +23,8 @@ import (
 	"github.com/google/syzkaller/pkg/html/pages"
 	"github.com/google/syzkaller/pkg/log"
 	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/pkg/report"
+	"github.com/google/syzkaller/pkg/rpctype"
 	"github.com/google/syzkaller/pkg/signal"
 	"github.com/google/syzkaller/pkg/vcs"
 	"github.com/google/syzkaller/prog"
@@ -46,6 +48,8 @@ func (mgr *Manager) initHTTP() {
 	handle("/subsystemcover", mgr.httpSubsystemCover)
 	handle("/modulecover", mgr.httpModuleCover)
 	handle("/prio", mgr.httpPrio)
+	handle("/descusage", mgr.httpDescUsage)
+	handle("/coverhistory", mgr.httpCoverHistory)
 	handle("/file", mgr.httpFile)
 	handle("/report", mgr.httpReport)
 	handle("/rawcover", mgr.httpRawCover)
@@ -55,6 +59,7 @@ func (mgr *Manager) initHTTP() {
 	handle("/filecover", mgr.httpFileCover)
 	handle("/input", mgr.httpInput)
 	handle("/debuginput", mgr.httpDebugInput)
+	mgr.initAPI(handle)
 	// Browsers like to request this, without special handler this goes to / handler.
 	handle("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {})
 
@@ -111,9 +116,39 @@ func (mgr *Manager) httpSyscalls(w http.ResponseWriter, r *http.Request) {
 	sort.Slice(data.Calls, func(i, j int) bool {
 		return data.Calls[i].Name < data.Calls[j].Name
 	})
+	data.Slowest = slowestCalls(mgr.stats.allCallTimes(), slowestCallsCount)
 	executeTemplate(w, syscallsTemplate, data)
 }
 
+// Number of syscalls shown in the slowest syscalls table.
+const slowestCallsCount = 20
+
+// slowestCalls returns up to n syscalls with the largest average execution time.
+func slowestCalls(times map[string]rpctype.CallTime, n int) []UICallTime {
+	var res []UICallTime
+	for call, t := range times {
+		if t.Execs == 0 {
+			continue
+		}
+		res = append(res, UICallTime{
+			Name:    call,
+			Execs:   t.Execs,
+			Average: t.Average(),
+			Max:     t.Max,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Average != res[j].Average {
+			return res[i].Average > res[j].Average
+		}
+		return res[i].Name < res[j].Name
+	})
+	if len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
+
 func (mgr *Manager) collectStats() []UIStat {
 	mgr.mu.Lock()
 	defer mgr.mu.Unlock()
@@ -143,9 +178,25 @@ func (mgr *Manager) collectStats() []UIStat {
 			Link: "/cover?filter=yes",
 		})
 	}
+	if mgr.targetPCs != 0 {
+		stats = append(stats, UIStat{
+			Name: "target coverage",
+			Value: fmt.Sprintf("%v / %v (%v%%)",
+				rawStats["target coverage"], mgr.targetPCs,
+				rawStats["target coverage"]*100/uint64(mgr.targetPCs)),
+		})
+	}
+	if mgr.cfg.CoverHistory {
+		regressions := "not checked yet"
+		if mgr.coverRegressionsChecked {
+			regressions = fmt.Sprint(len(mgr.coverRegressions))
+		}
+		stats = append(stats, UIStat{Name: "coverage regressions", Value: regressions, Link: "/coverhistory"})
+	}
 	delete(rawStats, "signal")
 	delete(rawStats, "coverage")
 	delete(rawStats, "filtered coverage")
+	delete(rawStats, "target coverage")
 	if mgr.checkResult != nil {
 		stats = append(stats, UIStat{
 			Name:  "syscalls",
@@ -577,7 +628,40 @@ func (mgr *Manager) collectCrashes(workdir string) ([]*UICrashType, error) {
 	sort.Slice(crashTypes, func(i, j int) bool {
 		return strings.ToLower(crashTypes[i].Description) < strings.ToLower(crashTypes[j].Description)
 	})
-	return crashTypes, nil
+	return groupCrashes(crashTypes), nil
+}
+
+// groupCrashes groups crash types with near-identical stacks, these are likely the same bug
+// with slightly different titles. Each group is represented by its first crash type,
+// the rest of the group follows it.
+func groupCrashes(crashTypes []*UICrashType) []*UICrashType {
+	var leaders []*UICrashType
+	members := make(map[*UICrashType][]*UICrashType)
+	for _, crash := range crashTypes {
+		var leader *UICrashType
+		if !crash.stack.Empty() {
+			for _, cand := range leaders {
+				if report.StackSimilarity(cand.stack, crash.stack) >= report.SimilarStackThreshold {
+					leader = cand
+					break
+				}
+			}
+		}
+		if leader == nil {
+			leaders = append(leaders, crash)
+			continue
+		}
+		crash.Group = leader.ID
+		crash.GroupTitle = leader.Description
+		members[leader] = append(members[leader], crash)
+		leader.GroupSize++
+	}
+	res := make([]*UICrashType, 0, len(crashTypes))
+	for _, leader := range leaders {
+		res = append(res, leader)
+		res = append(res, members[leader]...)
+	}
+	return res
 }
 
 func readCrash(workdir, dir string, repros map[string]bool, start time.Time, full bool) *UICrashType {
@@ -653,6 +737,7 @@ func readCrash(workdir, dir string, repros map[string]bool, start time.Time, ful
 		})
 	}
 
+	stack, _ := os.ReadFile(filepath.Join(crashdir, dir, "stack"))
 	triaged := reproStatus(hasRepro, hasCRepro, repros[desc], reproAttempts >= maxReproAttempts)
 	return &UICrashType{
 		Description: desc,
@@ -663,6 +748,7 @@ func readCrash(workdir, dir string, repros map[string]bool, start time.Time, ful
 		Triaged:     triaged,
 		Strace:      strace,
 		Crashes:     crashes,
+		stack:       report.ParseStackSignature(stack),
 	}
 }
 
@@ -706,8 +792,9 @@ type UISummaryData struct {
 }
 
 type UISyscallsData struct {
-	Name  string
-	Calls []UICallType
+	Name    string
+	Calls   []UICallType
+	Slowest []UICallTime
 }
 
 type UICrashType struct {
@@ -719,6 +806,12 @@ type UICrashType struct {
 	Triaged     string
 	Strace      string
 	Crashes     []*UICrash
+	// Group is ID of the crash type with a near-identical stack this crash type is grouped with.
+	Group      string
+	GroupTitle string
+	// GroupSize is the number of other crash types grouped with this one.
+	GroupSize int
+	stack     report.StackSignature
 }
 
 type UICrash struct {
@@ -743,6 +836,13 @@ type UICallType struct {
 	Cover  int
 }
 
+type UICallTime struct {
+	Name    string
+	Execs   uint64
+	Average time.Duration
+	Max     time.Duration
+}
+
 type UICorpus struct {
 	Call     string
 	RawCover bool
@@ -792,7 +892,15 @@ var summaryTemplate = pages.Create(`
 	</tr>
 	{{range $c := $.Crashes}}
 	<tr>
-		<td class="title"><a href="/crash?id={{$c.ID}}">{{$c.Description}}</a></td>
+		<td class="title">
+			{{if $c.Group}}&nbsp;&nbsp;&#8627;{{end}}
+			<a href="/crash?id={{$c.ID}}">{{$c.Description}}</a>
+			{{if $c.Group}}
+				<span title="near-identical stack with {{$c.GroupTitle}}">(similar)</span>
+			{{else if $c.GroupSize}}
+				<span title="crash types below have near-identical stacks">(+{{$c.GroupSize}} similar)</span>
+			{{end}}
+		</td>
 		<td class="stat {{if not $c.Active}}inactive{{end}}">{{$c.Count}}</td>
 		<td class="time {{if not $c.Active}}inactive{{end}}">{{formatTime $c.LastTime}}</td>
 		<td>
@@ -835,6 +943,7 @@ var syscallsTemplate = pages.Create(`
 		<th><a onclick="return sortTable(this, 'Inputs', numSort)" href="#">Inputs</a></th>
 		<th><a onclick="return sortTable(this, 'Coverage', numSort)" href="#">Coverage</a></th>
 		<th>Prio</th>
+		<th>Descriptions</th>
 	</tr>
 	{{range $c := $.Calls}}
 	<tr>
@@ -842,6 +951,25 @@ var syscallsTemplate = pages.Create(`
 		<td><a href='/corpus?call={{$c.Name}}'>{{$c.Inputs}}</a></td>
 		<td><a href='/cover?call={{$c.Name}}'>{{$c.Cover}}</a></td>
 		<td><a href='/prio?call={{$c.Name}}'>prio</a></td>
+		<td><a href='/descusage?call={{$c.Name}}'>unused</a></td>
+	</tr>
+	{{end}}
+</table>
+<br>
+<table class="list_table">
+	<caption>Slowest syscalls:</caption>
+	<tr>
+		<th>Syscall</th>
+		<th>Execs</th>
+		<th>Average time</th>
+		<th>Max time</th>
+	</tr>
+	{{range $c := $.Slowest}}
+	<tr>
+		<td>{{$c.Name}}</td>
+		<td>{{$c.Execs}}</td>
+		<td>{{$c.Average}}</td>
+		<td>{{$c.Max}}</td>
 	</tr>
 	{{end}}
 </table>
@@ -0,0 +1,67 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/syzkaller/pkg/report"
+	"github.com/google/syzkaller/pkg/rpctype"
+)
+
+func TestGroupCrashes(t *testing.T) {
+	crash := func(id string, frames ...string) *UICrashType {
+		return &UICrashType{
+			ID:          id,
+			Description: "crash " + id,
+			stack:       report.StackSignature{Frames: frames},
+		}
+	}
+	crashes := groupCrashes([]*UICrashType{
+		crash("a", "f1", "f2", "f3", "f4", "f5"),
+		crash("b", "g1", "g2", "g3"),
+		crash("c", "f0", "f2", "f3", "f4", "f5"),
+		crash("d"),
+		crash("e", "g1", "g2", "g3"),
+	})
+	var got []string
+	for _, c := range crashes {
+		got = append(got, c.ID+":"+c.Group)
+	}
+	want := []string{"a:", "c:a", "b:", "e:b", "d:"}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+	if crashes[0].GroupSize != 1 || crashes[4].GroupSize != 0 {
+		t.Fatalf("wrong group sizes: %v, %v", crashes[0].GroupSize, crashes[4].GroupSize)
+	}
+}
+
+func TestSlowestCalls(t *testing.T) {
+	times := map[string]rpctype.CallTime{
+		"a": {Execs: 10, Total: 10 * time.Millisecond, Max: 5 * time.Millisecond},
+		"b": {Execs: 1, Total: time.Second, Max: time.Second},
+		"c": {Execs: 4, Total: 40 * time.Millisecond, Max: 20 * time.Millisecond},
+		"d": {},
+	}
+	got := slowestCalls(times, 2)
+	want := []UICallTime{
+		{Name: "b", Execs: 1, Average: time.Second, Max: time.Second},
+		{Name: "c", Execs: 4, Average: 10 * time.Millisecond, Max: 20 * time.Millisecond},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got %+v, want %+v", got, want)
+		}
+	}
+}
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"net/http"
 	"strings"
 	"time"
@@ -50,6 +51,13 @@ func (mgr *Manager) hubSyncLoop(keyGet keyGetter) {
 	if mgr.cfg.Reproduce && mgr.dash != nil {
 		hc.needMoreRepros = mgr.needMoreRepros
 	}
+	if mgr.cfg.HubTLSCert != "" {
+		var err error
+		hc.tls, err = rpctype.ClientTLSConfig(mgr.cfg.HubTLSCert, mgr.cfg.HubTLSKey, mgr.cfg.HubTLSCA)
+		if err != nil {
+			log.Fatalf("failed to load hub TLS config: %v", err)
+		}
+	}
 	hc.loop()
 }
 
@@ -63,10 +71,12 @@ type HubConnector struct {
 	leak           bool
 	fresh          bool
 	hubCorpus      map[hash.Sig]bool
+	hubResults     []rpctype.HubInputResult
 	newRepros      [][]byte
 	hubReproQueue  chan *Crash
 	needMoreRepros chan chan bool
 	keyGet         keyGetter
+	tls            *tls.Config
 }
 
 // HubManagerView restricts interface between HubConnector and Manager.
@@ -136,10 +146,10 @@ func (hc *HubConnector) connect(corpus [][]byte) (*rpctype.RPCClient, error) {
 	}
 	// Hub.Connect request can be very large, so do it on a transient connection
 	// (rpc connection buffers never shrink).
-	if err := rpctype.RPCCall(hc.cfg.HubAddr, 1, "Hub.Connect", a, nil); err != nil {
+	if err := rpctype.RPCCall(hc.cfg.HubAddr, 1, hc.tls, "Hub.Connect", a, nil); err != nil {
 		return nil, err
 	}
-	hub, err := rpctype.NewRPCClient(hc.cfg.HubAddr, 1)
+	hub, err := rpctype.NewRPCClient(hc.cfg.HubAddr, 1, hc.tls)
 	if err != nil {
 		return nil, err
 	}
@@ -182,10 +192,12 @@ func (hc *HubConnector) sync(hub *rpctype.RPCClient, corpus [][]byte) error {
 	}
 	a.Repros = hc.newRepros
 	for {
+		a.Results = hc.hubResults
 		r := new(rpctype.HubSyncRes)
 		if err := hub.Call("Hub.Sync", a, r); err != nil {
 			return err
 		}
+		hc.hubResults = nil
 		minimized, smashed, progDropped := hc.processProgs(r.Inputs)
 		reproDropped := hc.processRepros(r.Repros)
 		hc.stats.hubSendProgAdd.add(len(a.Add))
@@ -219,9 +231,17 @@ func (hc *HubConnector) processProgs(inputs []rpctype.HubInput) (minimized, smas
 			log.Logf(0, "rejecting program from hub (bad=%v, disabled=%v):\n%s",
 				bad, disabled, inp)
 			dropped++
+			hc.hubResults = append(hc.hubResults, rpctype.HubInputResult{
+				Sig:     hash.String(inp.Prog),
+				Dropped: true,
+			})
 			continue
 		}
 		min, smash := matchDomains(hc.domain, inp.Domain)
+		hc.hubResults = append(hc.hubResults, rpctype.HubInputResult{
+			Sig:       hash.String(inp.Prog),
+			Minimized: min,
+		})
 		if min {
 			minimized++
 		}
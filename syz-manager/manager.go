@@ -54,6 +54,7 @@ type Manager struct {
 	crashdir       string
 	serv           *RPCServer
 	corpusDB       *db.DB
+	faultsDB       *db.DB
 	startTime      time.Time
 	firstConnect   time.Time
 	fuzzingTime    time.Duration
@@ -70,10 +71,12 @@ type Manager struct {
 	mu                    sync.Mutex
 	phase                 int
 	targetEnabledSyscalls map[*prog.Syscall]bool
+	disabledCalls         map[int]string // syscall ID -> reason why it's disabled on the target
 
 	candidates       []rpctype.Candidate // untriaged inputs from corpus and hub
 	disabledHashes   map[string]struct{}
 	corpus           map[string]CorpusItem
+	faultSweeps      map[string]*rpctype.FaultSweep // see faults.go
 	seeds            [][]byte
 	newRepros        [][]byte
 	lastMinCorpus    int
@@ -89,10 +92,17 @@ type Manager struct {
 	// Maps file name to modification time.
 	usedFiles map[string]time.Time
 
-	modules            []host.KernelModule
-	coverFilter        map[uint32]uint32
-	coverFilterBitmap  []byte
-	modulesInitialized bool
+	modules                 []host.KernelModule
+	coverFilter             map[uint32]uint32
+	coverFilterBitmap       []byte
+	targetDistances         map[uint32]uint32        // see createTargetDistances
+	targetPCs               int                      // number of coverage PCs in directed fuzzing targets
+	coverHistory            []*coverHistoryRecord    // see coverhistory.go
+	coverBase               *coverHistoryRecord      // the last record made before the restart
+	coverFiles              map[string]cover.PCCount // complete per-file coverage of the last record
+	coverRegressions        []coverRegression
+	coverRegressionsChecked bool
+	modulesInitialized      bool
 
 	assetStorage *asset.Storage
 }
@@ -198,6 +208,7 @@ func RunManager(cfg *mgrconfig.Config) {
 		reproRequest:     make(chan chan map[string]bool),
 		usedFiles:        make(map[string]time.Time),
 		saturatedCalls:   make(map[string]bool),
+		faultSweeps:      make(map[string]*rpctype.FaultSweep),
 	}
 
 	mgr.preloadCorpus()
@@ -568,6 +579,12 @@ func (mgr *Manager) preloadCorpus() {
 		log.Logf(0, "read %v inputs from corpus and got error: %v", len(corpusDB.Records), err)
 	}
 	mgr.corpusDB = corpusDB
+	if mgr.cfg.FaultCampaign {
+		mgr.loadFaultSweeps()
+	}
+	if mgr.cfg.CoverHistory {
+		mgr.loadCoverHistory()
+	}
 
 	if seedDir := filepath.Join(mgr.cfg.Syzkaller, "sys", mgr.cfg.TargetOS, "test"); osutil.IsExist(seedDir) {
 		seeds, err := os.ReadDir(seedDir)
@@ -671,6 +688,7 @@ func (mgr *Manager) loadProg(data []byte, minimized, smashed bool) bool {
 		Prog:      data,
 		Minimized: minimized,
 		Smashed:   smashed,
+		Faults:    mgr.faultSweeps[hash.String(data)],
 	})
 	return true
 }
@@ -762,12 +780,37 @@ func (mgr *Manager) runInstanceInner(index int, instanceName string) (*report.Re
 		}
 	}
 
+	var tlsCert, tlsKey, tlsCA string
+	if mgr.cfg.RPCTLSCert != "" {
+		for _, file := range []struct {
+			host string
+			vm   *string
+		}{
+			{mgr.cfg.FuzzerTLSCert, &tlsCert},
+			{mgr.cfg.FuzzerTLSKey, &tlsKey},
+			{mgr.cfg.RPCTLSCA, &tlsCA},
+		} {
+			if *file.vm, err = inst.Copy(file.host); err != nil {
+				return nil, nil, fmt.Errorf("failed to copy TLS file: %v", err)
+			}
+		}
+	}
+
 	fuzzerV := 0
 	procs := mgr.cfg.Procs
 	if *flagDebug {
 		fuzzerV = 100
 		procs = 1
 	}
+	resetAfter := 0
+	if mgr.cfg.SnapshotResets {
+		// Take the snapshot after the binaries are copied, so that every fuzzer run
+		// starts from the same freshly booted state.
+		if err := inst.Snapshot(); err != nil {
+			return nil, nil, fmt.Errorf("failed to save VM snapshot: %v", err)
+		}
+		resetAfter = mgr.cfg.SnapshotResetPeriod
+	}
 
 	// Run the fuzzer binary.
 	start := time.Now()
@@ -789,19 +832,42 @@ func (mgr *Manager) runInstanceInner(index int, instanceName string) (*report.Re
 		Test:      false,
 		Runtest:   false,
 		Optional: &instance.OptionalFuzzerArgs{
-			Slowdown:   mgr.cfg.Timeouts.Slowdown,
-			RawCover:   mgr.cfg.RawCover,
-			SandboxArg: mgr.cfg.SandboxArg,
+			Slowdown:         mgr.cfg.Timeouts.Slowdown,
+			RawCover:         mgr.cfg.RawCover,
+			SandboxArg:       mgr.cfg.SandboxArg,
+			ResetAfter:       resetAfter,
+			FaultCampaign:    mgr.cfg.FaultCampaign,
+			ForkTemplate:     mgr.cfg.ForkTemplate,
+			ForkBench:        mgr.cfg.ForkTemplate,
+			DeprioritizeSlow: mgr.cfg.DeprioritizeSlowCalls,
+			CorpusSchedule:   mgr.cfg.CorpusSchedule,
+			TLSCert:          tlsCert,
+			TLSKey:           tlsKey,
+			TLSCA:            tlsCA,
 		},
 	}
 	cmd := instance.FuzzerCmd(args)
-	outc, errc, err := inst.Run(mgr.cfg.Timeouts.VMRunningTime, mgr.vmStop, cmd)
+	// The fork template benchmark takes a while, so restarted fuzzers don't repeat it.
+	args.Optional.ForkBench = false
+	restartCmd := instance.FuzzerCmd(args)
+	rep, err := runWithResets(mgr.cfg.Timeouts.VMRunningTime,
+		func(timeout time.Duration) (*report.Report, bool, error) {
+			rep, reset, err := mgr.runFuzzer(inst, cmd, timeout, resetAfter != 0)
+			cmd = restartCmd
+			return rep, reset, err
+		},
+		func() error {
+			if err := inst.Restore(); err != nil {
+				return fmt.Errorf("failed to restore VM snapshot: %v", err)
+			}
+			mgr.stats.vmResets.inc()
+			return nil
+		})
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to run fuzzer: %v", err)
+		return nil, nil, err
 	}
 
 	var vmInfo []byte
-	rep := inst.MonitorExecution(outc, errc, mgr.reporter, vm.ExitTimeout)
 	if rep == nil {
 		// This is the only "OK" outcome.
 		log.Logf(0, "%s: running for %v, restarting", instanceName, time.Since(start))
@@ -815,6 +881,57 @@ func (mgr *Manager) runInstanceInner(index int, instanceName string) (*report.Re
 	return rep, vmInfo, nil
 }
 
+// runWithResets runs the fuzzer (see runFuzzer) until it crashes or the total running time expires.
+// Each time the fuzzer exits to reset VM state, the VM snapshot is restored and the fuzzer is restarted.
+func runWithResets(runningTime time.Duration, run func(time.Duration) (*report.Report, bool, error),
+	restore func() error) (*report.Report, error) {
+	start := time.Now()
+	for {
+		timeout := runningTime - time.Since(start)
+		if timeout <= 0 {
+			return nil, nil
+		}
+		rep, reset, err := run(timeout)
+		if err != nil || rep != nil || !reset {
+			return rep, err
+		}
+		if err := restore(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// runFuzzer runs the fuzzer command until it exits or the timeout expires.
+// If allowReset is set, the fuzzer is allowed to exit normally, which means that it asks
+// to restore the VM snapshot, the returned reset is true in this case.
+func (mgr *Manager) runFuzzer(inst *vm.Instance, cmd string, timeout time.Duration, allowReset bool) (
+	rep *report.Report, reset bool, err error) {
+	outc, errc, err := inst.Run(timeout, mgr.vmStop, cmd)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to run fuzzer: %v", err)
+	}
+	if !allowReset {
+		return inst.MonitorExecution(outc, errc, mgr.reporter, vm.ExitTimeout), false, nil
+	}
+	// Intercept the exit status to distinguish a normal exit from timeouts and stop requests.
+	exitErr := make(chan error, 1)
+	monitorErrc := make(chan error, 1)
+	go func() {
+		err := <-errc
+		exitErr <- err
+		monitorErrc <- err
+	}()
+	rep = inst.MonitorExecution(outc, monitorErrc, mgr.reporter, vm.ExitTimeout|vm.ExitNormal)
+	if rep == nil {
+		select {
+		case err := <-exitErr:
+			reset = err == nil
+		default:
+		}
+	}
+	return rep, reset, nil
+}
+
 func (mgr *Manager) emailCrash(crash *Crash) {
 	if len(mgr.cfg.EmailAddrs) == 0 {
 		return
@@ -852,6 +969,9 @@ func (mgr *Manager) saveCrash(crash *Crash) bool {
 		flags += " [suppressed]"
 	}
 	log.Logf(0, "vm-%v: crash: %v%v", crash.vmIndex, crash.Title, flags)
+	if mgr.cfg.FaultCampaign {
+		mgr.saveCrashedFaults(crash.Output)
+	}
 
 	if crash.Suppressed {
 		// Collect all of them into a single bucket so that it's possible to control and assess them,
@@ -933,6 +1053,10 @@ func (mgr *Manager) saveCrash(crash *Crash) bool {
 	writeOrRemove("tag", []byte(mgr.cfg.Tag))
 	writeOrRemove("report", crash.Report.Report)
 	writeOrRemove("machineInfo", crash.machineInfo)
+	// Stack signature of the latest report is used to group crash types with near-identical stacks.
+	if sig := mgr.reporter.StackSignature(crash.Report); !sig.Empty() {
+		osutil.WriteFile(filepath.Join(dir, "stack"), []byte(sig.String()+"\n"))
+	}
 	return mgr.needLocalRepro(crash)
 }
 
@@ -1148,9 +1272,11 @@ func saveReproStats(filename string, stats *repro.Stats) {
 	text := ""
 	if stats != nil {
 		text = fmt.Sprintf("Extracting prog: %v\nMinimizing prog: %v\n"+
-			"Simplifying prog options: %v\nExtracting C: %v\nSimplifying C: %v\n\n\n%s",
+			"Simplifying prog options: %v\nExtracting C: %v\nSimplifying C: %v\n"+
+			"Estimating reliability: %v\nProg reliability: %v\nC reliability: %v\n\n\n%s",
 			stats.ExtractProgTime, stats.MinimizeProgTime,
-			stats.SimplifyProgTime, stats.ExtractCTime, stats.SimplifyCTime, stats.Log)
+			stats.SimplifyProgTime, stats.ExtractCTime, stats.SimplifyCTime,
+			stats.ReliabilityTime, stats.ProgReliability, stats.CReliability, stats.Log)
 	}
 	osutil.WriteFile(filename, []byte(text))
 }
@@ -1244,6 +1370,17 @@ func (mgr *Manager) minimizeCorpus() {
 		}
 	}
 	mgr.corpusDB.BumpVersion(currentDBVersion)
+	if mgr.faultsDB != nil {
+		for key := range mgr.faultsDB.Records {
+			if _, ok := mgr.corpus[key]; !ok {
+				mgr.faultsDB.Delete(key)
+				delete(mgr.faultSweeps, key)
+			}
+		}
+		if err := mgr.faultsDB.Flush(); err != nil {
+			log.Logf(0, "failed to save faults database: %v", err)
+		}
+	}
 }
 
 func setGuiltyFiles(crash *dashapi.Crash, report *report.Report) {
@@ -1312,16 +1449,41 @@ func (mgr *Manager) fuzzerConnect(modules []host.KernelModule) (
 		if len(modules) > 0 && mgr.coverFilterBitmap != nil {
 			log.Fatalf("coverage filtering is not supported with modules")
 		}
+		mgr.targetDistances, err = mgr.createTargetDistances()
+		if err != nil {
+			log.Fatalf("failed to compute directed fuzzing distances: %v", err)
+		}
+		if len(modules) > 0 && mgr.targetDistances != nil {
+			log.Fatalf("directed fuzzing is not supported with modules")
+		}
+		for _, dist := range mgr.targetDistances {
+			if dist == 0 {
+				mgr.targetPCs++
+			}
+		}
 		mgr.modulesInitialized = true
+		if mgr.cfg.CoverHistory {
+			go mgr.coverHistoryLoop()
+		}
 	}
 	return corpus, frames, mgr.coverFilter, mgr.coverFilterBitmap, nil
 }
 
+func (mgr *Manager) directedTargets() map[uint32]uint32 {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	return mgr.targetDistances
+}
+
 func (mgr *Manager) machineChecked(a *rpctype.CheckArgs, enabledSyscalls map[*prog.Syscall]bool) {
 	mgr.mu.Lock()
 	defer mgr.mu.Unlock()
 	mgr.checkResult = a
 	mgr.targetEnabledSyscalls = enabledSyscalls
+	mgr.disabledCalls = make(map[int]string)
+	for _, dc := range a.DisabledCalls[mgr.cfg.Sandbox] {
+		mgr.disabledCalls[dc.ID] = dc.Reason
+	}
 	mgr.target.UpdateGlobs(a.GlobFiles)
 	mgr.loadCorpus()
 	mgr.firstConnect = time.Now()
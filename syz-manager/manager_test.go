@@ -0,0 +1,82 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/syzkaller/pkg/report"
+)
+
+func TestRunWithResets(t *testing.T) {
+	type result struct {
+		rep   *report.Report
+		reset bool
+		err   error
+	}
+	crash := &report.Report{Title: "crash"}
+	errRestore := errors.New("restore failed")
+	tests := []struct {
+		name        string
+		results     []result
+		restoreErr  error
+		runningTime time.Duration
+		runTime     time.Duration
+		rep         *report.Report
+		err         error
+		runs        int
+	}{
+		{
+			name:        "timeout",
+			results:     []result{{}},
+			runningTime: time.Hour,
+			runs:        1,
+		},
+		{
+			name:        "resets then crash",
+			results:     []result{{reset: true}, {reset: true}, {rep: crash}},
+			runningTime: time.Hour,
+			rep:         crash,
+			runs:        3,
+		},
+		{
+			name:        "restore error",
+			results:     []result{{reset: true}, {}},
+			restoreErr:  errRestore,
+			runningTime: time.Hour,
+			err:         errRestore,
+			runs:        1,
+		},
+		{
+			name:        "no time left",
+			results:     []result{{reset: true}, {reset: true}},
+			runningTime: 10 * time.Millisecond,
+			runTime:     20 * time.Millisecond,
+			runs:        1,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			runs := 0
+			rep, err := runWithResets(test.runningTime,
+				func(timeout time.Duration) (*report.Report, bool, error) {
+					if timeout <= 0 {
+						t.Fatalf("non-positive timeout %v", timeout)
+					}
+					res := test.results[runs]
+					runs++
+					time.Sleep(test.runTime)
+					return res.rep, res.reset, res.err
+				},
+				func() error { return test.restoreErr })
+			if rep != test.rep || err != test.err || runs != test.runs {
+				t.Fatalf("got rep=%v err=%v runs=%v, want rep=%v err=%v runs=%v",
+					rep, err, runs, test.rep, test.err, test.runs)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
 	"math/rand"
 	"net"
@@ -26,12 +27,14 @@ type RPCServer struct {
 	port                  int
 	targetEnabledSyscalls map[*prog.Syscall]bool
 	coverFilter           map[uint32]uint32
+	targetDistances       map[uint32]uint32
 	stats                 *Stats
 	batchSize             int
 	canonicalModules      *cover.Canonicalizer
 
 	mu            sync.Mutex
 	fuzzers       map[string]*Fuzzer
+	fuzzerStates  map[string]*rpctype.FuzzerState // checkpoints keyed by instance name
 	checkResult   *rpctype.CheckArgs
 	maxSignal     signal.Signal
 	corpusSignal  signal.Signal
@@ -49,6 +52,10 @@ type Fuzzer struct {
 	rotatedSignal signal.Signal
 	machineInfo   []byte
 	instModules   *cover.CanonicalizerInstance
+	// Signals the stream serving this fuzzer (if any) that there is new work to push.
+	wakeup chan struct{}
+	// The fuzzer has requested candidates over the stream.
+	wantCandidates bool
 }
 
 type BugFrames struct {
@@ -60,30 +67,42 @@ type BugFrames struct {
 type RPCManagerView interface {
 	fuzzerConnect([]host.KernelModule) (
 		[]rpctype.Input, BugFrames, map[uint32]uint32, []byte, error)
+	directedTargets() map[uint32]uint32
 	machineChecked(result *rpctype.CheckArgs, enabledSyscalls map[*prog.Syscall]bool)
 	newInput(inp rpctype.Input, sign signal.Signal) bool
 	candidateBatch(size int) []rpctype.Candidate
+	newFaultSweeps(sweeps []rpctype.FaultSweep)
 	rotateCorpus() bool
 }
 
 func startRPCServer(mgr *Manager) (*RPCServer, error) {
 	serv := &RPCServer{
-		mgr:     mgr,
-		cfg:     mgr.cfg,
-		stats:   mgr.stats,
-		fuzzers: make(map[string]*Fuzzer),
-		rnd:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		mgr:          mgr,
+		cfg:          mgr.cfg,
+		stats:        mgr.stats,
+		fuzzers:      make(map[string]*Fuzzer),
+		fuzzerStates: make(map[string]*rpctype.FuzzerState),
+		rnd:          rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 	serv.batchSize = 5
 	if serv.batchSize < mgr.cfg.Procs {
 		serv.batchSize = mgr.cfg.Procs
 	}
-	s, err := rpctype.NewRPCServer(mgr.cfg.RPC, "Manager", serv)
+	var tlsCfg *tls.Config
+	if mgr.cfg.RPCTLSCert != "" {
+		var err error
+		tlsCfg, err = rpctype.ServerTLSConfig(mgr.cfg.RPCTLSCert, mgr.cfg.RPCTLSKey, mgr.cfg.RPCTLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load rpc TLS config: %v", err)
+		}
+	}
+	s, err := rpctype.NewRPCServer(mgr.cfg.RPC, "Manager", serv, tlsCfg)
 	if err != nil {
 		return nil, err
 	}
 	log.Logf(0, "serving rpc on tcp://%v", s.Addr())
 	serv.port = s.Addr().(*net.TCPAddr).Port
+	s.HandleStreams(serv.serveStream)
 	go s.Serve()
 	return serv, nil
 }
@@ -97,6 +116,7 @@ func (serv *RPCServer) Connect(a *rpctype.ConnectArgs, r *rpctype.ConnectRes) er
 		return err
 	}
 	serv.coverFilter = coverFilter
+	serv.targetDistances = serv.mgr.directedTargets()
 	serv.modules = a.Modules
 
 	if serv.canonicalModules == nil {
@@ -110,15 +130,18 @@ func (serv *RPCServer) Connect(a *rpctype.ConnectArgs, r *rpctype.ConnectRes) er
 		name:        a.Name,
 		machineInfo: a.MachineInfo,
 		instModules: serv.canonicalModules.NewInstance(a.Modules),
+		wakeup:      make(chan struct{}, 1),
 	}
 	serv.fuzzers[a.Name] = f
 	r.MemoryLeakFrames = bugFrames.memoryLeaks
 	r.DataRaceFrames = bugFrames.dataRaces
 	r.CoverFilterBitmap = coverBitmap
+	r.TargetDistances = serv.targetDistances
 	r.EnabledCalls = serv.cfg.Syscalls
 	r.NoMutateCalls = serv.cfg.NoMutateCalls
 	r.GitRevision = prog.GitRevision
 	r.TargetRevision = serv.cfg.Target.Revision
+	r.Stream = true // fuzzers that can't dial streams (see rpctype.CanDialStream) keep polling
 	if serv.mgr.rotateCorpus() && serv.rnd.Intn(5) == 0 {
 		// We do rotation every other time because there are no objective
 		// proofs regarding its efficiency either way.
@@ -129,6 +152,14 @@ func (serv *RPCServer) Connect(a *rpctype.ConnectArgs, r *rpctype.ConnectRes) er
 		r.CheckResult = serv.checkResult
 		f.inputs = corpus
 		f.newMaxSignal = serv.maxSignal.Copy()
+		// Don't restore the state into rotated fuzzers since it may contain disabled calls.
+		r.State = serv.fuzzerStates[a.Name]
+	}
+	// Hand out the state only once: if pending work items crash the kernel,
+	// we don't want to crash all subsequent instances as well.
+	delete(serv.fuzzerStates, a.Name)
+	if r.State != nil {
+		serv.stats.restoredItems.add(len(r.State.Candidates) + len(r.State.Triage) + len(r.State.Smash))
 	}
 	return nil
 }
@@ -303,6 +334,15 @@ func (serv *RPCServer) NewInput(a *rpctype.NewInputArgs, r *int) error {
 		}
 		serv.stats.corpusCoverFiltered.add(filtered)
 	}
+	if len(diff) != 0 && serv.targetDistances != nil {
+		covered := 0
+		for _, pc := range diff {
+			if dist, ok := serv.targetDistances[pc]; ok && dist == 0 {
+				covered++
+			}
+		}
+		serv.stats.corpusCoverTarget.add(covered)
+	}
 	serv.stats.newInputs.inc()
 	if rotated {
 		serv.stats.rotatedInputs.inc()
@@ -319,13 +359,14 @@ func (serv *RPCServer) NewInput(a *rpctype.NewInputArgs, r *int) error {
 				continue
 			}
 			other.inputs = append(other.inputs, a.Input)
+			other.wake()
 		}
 	}
 	return nil
 }
 
 func (serv *RPCServer) Poll(a *rpctype.PollArgs, r *rpctype.PollRes) error {
-	serv.stats.mergeNamed(a.Stats)
+	serv.pollStats(a)
 
 	serv.mu.Lock()
 	defer serv.mu.Unlock()
@@ -337,23 +378,30 @@ func (serv *RPCServer) Poll(a *rpctype.PollArgs, r *rpctype.PollRes) error {
 		log.Logf(1, "poll: fuzzer %v is not connected", a.Name)
 		return nil
 	}
-	newMaxSignal := serv.maxSignal.Diff(a.MaxSignal.Deserialize())
-	if !newMaxSignal.Empty() {
-		serv.maxSignal.Merge(newMaxSignal)
-		serv.stats.maxSignal.set(len(serv.maxSignal))
-		for _, f1 := range serv.fuzzers {
-			if f1 == f || f1.rotated {
-				continue
-			}
-			f1.newMaxSignal.Merge(newMaxSignal)
-		}
-	}
+	serv.mergeMaxSignal(f, a.MaxSignal.Deserialize())
 	if f.rotated {
 		// Let rotated VMs run in isolation, don't send them anything.
 		return nil
 	}
+	serv.pendingWork(f, a.NeedCandidates, len(a.Stats) == 0, r)
+	log.Logf(4, "poll from %v: candidates=%v inputs=%v maxsignal=%v",
+		a.Name, len(r.Candidates), len(r.NewInputs), len(r.MaxSignal.Elems))
+	return nil
+}
+
+func (serv *RPCServer) pollStats(a *rpctype.PollArgs) {
+	serv.stats.mergeNamed(a.Stats)
+	serv.stats.mergeCallTimes(a.CallTimes)
+	if len(a.FaultSweeps) != 0 {
+		serv.mgr.newFaultSweeps(a.FaultSweeps)
+	}
+}
+
+// pendingWork moves pending max signal, candidates and corpus inputs for the fuzzer into r.
+// Initial says that the fuzzer is pumping the corpus on start. Requires serv.mu.
+func (serv *RPCServer) pendingWork(f *Fuzzer, needCandidates, initial bool, r *rpctype.PollRes) {
 	r.MaxSignal = f.newMaxSignal.Split(2000).Serialize()
-	if a.NeedCandidates {
+	if needCandidates {
 		r.Candidates = serv.mgr.candidateBatch(serv.batchSize)
 	}
 	if len(r.Candidates) == 0 {
@@ -363,7 +411,7 @@ func (serv *RPCServer) Poll(a *rpctype.PollArgs, r *rpctype.PollRes) error {
 		// (batch of size 6 can take more than 10 mins for 50K corpus and slow kernel).
 		// So use a larger batch initially (we use no stats as approximation of initial pump).
 		const initialBatch = 50
-		if len(a.Stats) == 0 && batchSize < initialBatch {
+		if initial && batchSize < initialBatch {
 			batchSize = initialBatch
 		}
 		for i := 0; i < batchSize && len(f.inputs) > 0; i++ {
@@ -379,11 +427,136 @@ func (serv *RPCServer) Poll(a *rpctype.PollArgs, r *rpctype.PollRes) error {
 	for _, inp := range r.NewInputs {
 		f.instModules.Decanonicalize(inp.Cover)
 	}
-	log.Logf(4, "poll from %v: candidates=%v inputs=%v maxsignal=%v",
-		a.Name, len(r.Candidates), len(r.NewInputs), len(r.MaxSignal.Elems))
+}
+
+// serveStream serves a stream connection from a fuzzer (see rpctype.StreamMsg).
+// Max signal, inputs and candidates are pushed to the fuzzer as soon as they appear.
+func (serv *RPCServer) serveStream(s *rpctype.Stream) {
+	defer s.Close()
+	msg, err := s.Recv()
+	if err != nil {
+		log.Logf(1, "stream: failed to receive fuzzer name: %v", err)
+		return
+	}
+	serv.mu.Lock()
+	f := serv.fuzzers[msg.Name]
+	serv.mu.Unlock()
+	if f == nil {
+		log.Logf(1, "stream: fuzzer %v is not connected", msg.Name)
+		return
+	}
+	log.Logf(1, "fuzzer %v started streaming", f.name)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			msg, err := s.Recv()
+			if err != nil {
+				log.Logf(1, "stream from %v failed: %v", f.name, err)
+				return
+			}
+			serv.streamRecv(f, msg)
+		}
+	}()
+	for {
+		select {
+		case <-f.wakeup:
+		case <-done:
+			return
+		}
+		msg, more := serv.streamWork(f)
+		if more {
+			f.wake()
+		}
+		if msg == nil {
+			continue
+		}
+		if err := s.Send(msg); err != nil {
+			log.Logf(1, "stream to %v failed: %v", f.name, err)
+			return
+		}
+	}
+}
+
+func (serv *RPCServer) streamRecv(f *Fuzzer, msg *rpctype.StreamMsg) {
+	if msg.NewInput != nil {
+		serv.NewInput(msg.NewInput, nil)
+	}
+	if a := msg.Poll; a != nil {
+		serv.pollStats(a)
+		serv.mu.Lock()
+		defer serv.mu.Unlock()
+		if serv.fuzzers[f.name] != f {
+			log.Logf(1, "stream: fuzzer %v is not connected", f.name)
+			return
+		}
+		serv.mergeMaxSignal(f, a.MaxSignal.Deserialize())
+		if a.NeedCandidates {
+			f.wantCandidates = true
+			f.wake()
+		}
+	}
+}
+
+// streamWork returns the next message to push to the fuzzer (nil if there is nothing to push)
+// and whether there is more pending work.
+func (serv *RPCServer) streamWork(f *Fuzzer) (*rpctype.StreamMsg, bool) {
+	serv.mu.Lock()
+	defer serv.mu.Unlock()
+
+	r := new(rpctype.PollRes)
+	if !f.rotated {
+		serv.pendingWork(f, f.wantCandidates, false, r)
+	}
+	msg := &rpctype.StreamMsg{
+		Res:             r,
+		CandidatesReply: f.wantCandidates,
+	}
+	f.wantCandidates = false
+	more := !f.rotated && (len(f.inputs) != 0 || !f.newMaxSignal.Empty())
+	if !msg.CandidatesReply && len(r.Candidates) == 0 && len(r.NewInputs) == 0 && len(r.MaxSignal.Elems) == 0 {
+		return nil, more
+	}
+	log.Logf(4, "push to %v: candidates=%v inputs=%v maxsignal=%v",
+		f.name, len(r.Candidates), len(r.NewInputs), len(r.MaxSignal.Elems))
+	return msg, more
+}
+
+func (serv *RPCServer) Checkpoint(a *rpctype.CheckpointArgs, r *int) error {
+	serv.mu.Lock()
+	defer serv.mu.Unlock()
+
+	f := serv.fuzzers[a.Name]
+	if f == nil {
+		log.Logf(1, "checkpoint: fuzzer %v is not connected", a.Name)
+		return nil
+	}
+	serv.mergeMaxSignal(f, a.State.MaxSignal.Deserialize())
+	// Max signal is already merged into serv.maxSignal and
+	// will be sent to the next fuzzer on connect anyway.
+	a.State.MaxSignal = signal.Serial{}
+	serv.fuzzerStates[a.Name] = &a.State
+	log.Logf(4, "checkpoint from %v: candidates=%v triage=%v smash=%v",
+		a.Name, len(a.State.Candidates), len(a.State.Triage), len(a.State.Smash))
 	return nil
 }
 
+func (serv *RPCServer) mergeMaxSignal(f *Fuzzer, sign signal.Signal) {
+	newMaxSignal := serv.maxSignal.Diff(sign)
+	if newMaxSignal.Empty() {
+		return
+	}
+	serv.maxSignal.Merge(newMaxSignal)
+	serv.stats.maxSignal.set(len(serv.maxSignal))
+	for _, f1 := range serv.fuzzers {
+		if f1 == f || f1.rotated {
+			continue
+		}
+		f1.newMaxSignal.Merge(newMaxSignal)
+		f1.wake()
+	}
+}
+
 func (serv *RPCServer) shutdownInstance(name string) []byte {
 	serv.mu.Lock()
 	defer serv.mu.Unlock()
@@ -395,3 +568,11 @@ func (serv *RPCServer) shutdownInstance(name string) []byte {
 	delete(serv.fuzzers, name)
 	return fuzzer.machineInfo
 }
+
+// wake notifies the stream serving the fuzzer (if any) that there is new work to push.
+func (f *Fuzzer) wake() {
+	select {
+	case f.wakeup <- struct{}{}:
+	default:
+	}
+}
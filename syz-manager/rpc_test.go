@@ -0,0 +1,120 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/syzkaller/pkg/cover"
+	"github.com/google/syzkaller/pkg/rpctype"
+	"github.com/google/syzkaller/pkg/signal"
+)
+
+type testStreamManager struct {
+	RPCManagerView
+	candidates []rpctype.Candidate
+}
+
+func (mgr *testStreamManager) candidateBatch(size int) []rpctype.Candidate {
+	if size > len(mgr.candidates) {
+		size = len(mgr.candidates)
+	}
+	res := mgr.candidates[:size]
+	mgr.candidates = mgr.candidates[size:]
+	return res
+}
+
+func TestServeStream(t *testing.T) {
+	mgr := &testStreamManager{
+		candidates: []rpctype.Candidate{{Prog: []byte("a")}, {Prog: []byte("b")}, {Prog: []byte("c")}},
+	}
+	serv := &RPCServer{
+		mgr:       mgr,
+		stats:     new(Stats),
+		batchSize: 2,
+		fuzzers:   make(map[string]*Fuzzer),
+	}
+	instModules := cover.NewCanonicalizer(nil).NewInstance(nil)
+	for _, f := range []*Fuzzer{
+		{name: "fuzzer", instModules: instModules},
+		{name: "rotated", instModules: instModules, rotated: true},
+	} {
+		f.wakeup = make(chan struct{}, 1)
+		serv.fuzzers[f.name] = f
+	}
+	s, err := rpctype.NewRPCServer("127.0.0.1:0", "Manager", serv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.HandleStreams(serv.serveStream)
+	go s.Serve()
+	dial := func(name string) *rpctype.Stream {
+		stream, err := rpctype.DialStream(s.Addr().String(), 1, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.Send(&rpctype.StreamMsg{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+		return stream
+	}
+	recv := func(stream *rpctype.Stream) *rpctype.StreamMsg {
+		res := make(chan *rpctype.StreamMsg, 1)
+		go func() {
+			msg, err := stream.Recv()
+			if err != nil {
+				t.Error(err)
+			}
+			res <- msg
+		}()
+		select {
+		case msg := <-res:
+			if msg == nil || msg.Res == nil {
+				t.Fatalf("bad stream message: %+v", msg)
+			}
+			return msg
+		case <-time.After(time.Minute):
+			t.Fatalf("no message from the stream")
+		}
+		return nil
+	}
+	fuzzer := dial("fuzzer")
+	defer fuzzer.Close()
+	rotated := dial("rotated")
+	defer rotated.Close()
+
+	// New max signal is pushed to the fuzzer as soon as it appears, rotated fuzzers don't get it.
+	serv.mu.Lock()
+	serv.mergeMaxSignal(nil, signal.FromRaw([]uint32{1, 2, 3}, 0))
+	serv.mu.Unlock()
+	if msg := recv(fuzzer); len(msg.Res.MaxSignal.Elems) != 3 || msg.CandidatesReply {
+		t.Fatalf("bad max signal push: %+v", msg.Res)
+	}
+	serv.mu.Lock()
+	rotatedSignal := serv.fuzzers["rotated"].newMaxSignal.Len()
+	serv.mu.Unlock()
+	if rotatedSignal != 0 {
+		t.Fatalf("rotated fuzzer got %v new max signal", rotatedSignal)
+	}
+
+	// Candidates are pushed in reply to a request.
+	if err := fuzzer.Send(&rpctype.StreamMsg{Poll: &rpctype.PollArgs{NeedCandidates: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := recv(fuzzer); len(msg.Res.Candidates) != 2 || !msg.CandidatesReply {
+		t.Fatalf("bad candidates reply: %+v", msg)
+	}
+
+	// Rotated fuzzers get an empty reply and don't consume candidates.
+	if err := rotated.Send(&rpctype.StreamMsg{Poll: &rpctype.PollArgs{NeedCandidates: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := recv(rotated); len(msg.Res.Candidates) != 0 || !msg.CandidatesReply {
+		t.Fatalf("bad candidates reply for rotated fuzzer: %+v", msg)
+	}
+	if len(mgr.candidates) != 1 {
+		t.Fatalf("rotated fuzzer consumed candidates: %v left", len(mgr.candidates))
+	}
+}
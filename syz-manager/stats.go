@@ -7,6 +7,7 @@ import (
 	"sync"
 	"sync/atomic"
 
+	"github.com/google/syzkaller/pkg/rpctype"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -18,8 +19,10 @@ type Stats struct {
 	crashTypes          Stat
 	crashSuppressed     Stat
 	vmRestarts          Stat
+	vmResets            Stat
 	newInputs           Stat
 	rotatedInputs       Stat
+	restoredItems       Stat
 	execTotal           Stat
 	hubSendProgAdd      Stat
 	hubSendProgDel      Stat
@@ -30,11 +33,13 @@ type Stats struct {
 	hubRecvReproDrop    Stat
 	corpusCover         Stat
 	corpusCoverFiltered Stat
+	corpusCoverTarget   Stat
 	corpusSignal        Stat
 	maxSignal           Stat
 
 	mu         sync.Mutex
 	namedStats map[string]uint64
+	callTimes  map[string]rpctype.CallTime // per-syscall execution time summed over all fuzzers
 	haveHub    bool
 }
 
@@ -66,11 +71,14 @@ func (stats *Stats) all() map[string]uint64 {
 		"crash types":       stats.crashTypes.get(),
 		"suppressed":        stats.crashSuppressed.get(),
 		"vm restarts":       stats.vmRestarts.get(),
+		"vm resets":         stats.vmResets.get(),
 		"new inputs":        stats.newInputs.get(),
 		"rotated inputs":    stats.rotatedInputs.get(),
+		"restored items":    stats.restoredItems.get(),
 		"exec total":        stats.execTotal.get(),
 		"coverage":          stats.corpusCover.get(),
 		"filtered coverage": stats.corpusCoverFiltered.get(),
+		"target coverage":   stats.corpusCoverTarget.get(),
 		"signal":            stats.corpusSignal.get(),
 		"max signal":        stats.maxSignal.get(),
 	}
@@ -107,6 +115,29 @@ func (stats *Stats) mergeNamed(named map[string]uint64) {
 	}
 }
 
+func (stats *Stats) mergeCallTimes(times map[string]rpctype.CallTime) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	if stats.callTimes == nil {
+		stats.callTimes = make(map[string]rpctype.CallTime)
+	}
+	for call, t := range times {
+		total := stats.callTimes[call]
+		total.Merge(t)
+		stats.callTimes[call] = total
+	}
+}
+
+func (stats *Stats) allCallTimes() map[string]rpctype.CallTime {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	res := make(map[string]rpctype.CallTime, len(stats.callTimes))
+	for call, t := range stats.callTimes {
+		res[call] = t
+	}
+	return res
+}
+
 func (s *Stat) get() uint64 {
 	return atomic.LoadUint64((*uint64)(s))
 }
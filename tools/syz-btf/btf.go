@@ -0,0 +1,308 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// BTF format is described in the kernel sources: Documentation/bpf/btf.rst and include/uapi/linux/btf.h.
+
+const (
+	btfMagic  = 0xeb9f
+	btfHdrLen = 24
+)
+
+type btfKind int
+
+const (
+	btfKindVoid btfKind = iota
+	btfKindInt
+	btfKindPtr
+	btfKindArray
+	btfKindStruct
+	btfKindUnion
+	btfKindEnum
+	btfKindFwd
+	btfKindTypedef
+	btfKindVolatile
+	btfKindConst
+	btfKindRestrict
+	btfKindFunc
+	btfKindFuncProto
+	btfKindVar
+	btfKindDatasec
+	btfKindFloat
+	btfKindDeclTag
+	btfKindTypeTag
+	btfKindEnum64
+)
+
+const (
+	btfIntSigned = 1 << 0
+	btfIntChar   = 1 << 1
+	btfIntBool   = 1 << 2
+)
+
+// btfType is a parsed BTF type. Type IDs are indices in btfSpec.types, ID 0 is void.
+type btfType struct {
+	kind btfKind
+	name string
+	// Size in bytes for int, float, struct, union, enum, enum64 and datasec.
+	size uint32
+	// Referenced type for ptr, typedef, modifiers, func and var.
+	typ int
+	// Int encoding.
+	intEncoding uint32
+	intOffset   uint32
+	intBits     uint32
+	// Array.
+	elem   int
+	nelems uint32
+	// Struct and union.
+	members []btfMember
+	// Enum and enum64.
+	values []btfEnumValue
+}
+
+type btfMember struct {
+	name      string
+	typ       int
+	bitOffset uint32
+	bitSize   uint32 // non-zero for bitfields
+}
+
+type btfEnumValue struct {
+	name string
+	val  uint64
+}
+
+type btfSpec struct {
+	types []*btfType
+}
+
+// loadBTF loads BTF from an ELF file (the .BTF section of vmlinux)
+// or from a raw BTF file (e.g. /sys/kernel/btf/vmlinux).
+func loadBTF(file string) (*btfSpec, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte(elf.ELFMAG)) {
+		ef, err := elf.NewFile(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		sec := ef.Section(".BTF")
+		if sec == nil {
+			return nil, fmt.Errorf("%v does not have .BTF section", file)
+		}
+		if data, err = sec.Data(); err != nil {
+			return nil, err
+		}
+	}
+	return parseBTF(data)
+}
+
+func parseBTF(data []byte) (*btfSpec, error) {
+	if len(data) < btfHdrLen {
+		return nil, fmt.Errorf("BTF data is too short: %v bytes", len(data))
+	}
+	var order binary.ByteOrder
+	switch {
+	case binary.LittleEndian.Uint16(data) == btfMagic:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint16(data) == btfMagic:
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("bad BTF magic 0x%x", binary.LittleEndian.Uint16(data))
+	}
+	if data[2] != 1 {
+		return nil, fmt.Errorf("unsupported BTF version %v", data[2])
+	}
+	hdrLen := order.Uint32(data[4:])
+	typeOff, typeLen := order.Uint32(data[8:]), order.Uint32(data[12:])
+	strOff, strLen := order.Uint32(data[16:]), order.Uint32(data[20:])
+	section := func(off, size uint32) ([]byte, error) {
+		start, end := uint64(hdrLen)+uint64(off), uint64(hdrLen)+uint64(off)+uint64(size)
+		if end > uint64(len(data)) {
+			return nil, fmt.Errorf("BTF section [%v, %v) is out of bounds (%v bytes)", start, end, len(data))
+		}
+		return data[start:end], nil
+	}
+	typeData, err := section(typeOff, typeLen)
+	if err != nil {
+		return nil, err
+	}
+	strData, err := section(strOff, strLen)
+	if err != nil {
+		return nil, err
+	}
+	p := &btfParser{
+		order:   order,
+		data:    typeData,
+		strings: strData,
+	}
+	spec := &btfSpec{types: []*btfType{{kind: btfKindVoid}}}
+	for len(p.data) != 0 && p.err == nil {
+		spec.types = append(spec.types, p.parseType())
+	}
+	if p.err != nil {
+		return nil, fmt.Errorf("failed to parse BTF type %v: %v", len(spec.types)-1, p.err)
+	}
+	for id, t := range spec.types {
+		for _, ref := range t.refs() {
+			if ref >= len(spec.types) {
+				return nil, fmt.Errorf("BTF type %v references non-existent type %v", id, ref)
+			}
+		}
+	}
+	return spec, nil
+}
+
+type btfParser struct {
+	order   binary.ByteOrder
+	data    []byte
+	strings []byte
+	err     error
+}
+
+func (p *btfParser) u32() uint32 {
+	if len(p.data) < 4 {
+		if p.err == nil {
+			p.err = fmt.Errorf("unexpected end of data")
+		}
+		p.data = nil
+		return 0
+	}
+	v := p.order.Uint32(p.data)
+	p.data = p.data[4:]
+	return v
+}
+
+func (p *btfParser) str(off uint32) string {
+	if off >= uint32(len(p.strings)) {
+		if p.err == nil {
+			p.err = fmt.Errorf("bad string offset %v", off)
+		}
+		return ""
+	}
+	s := p.strings[off:]
+	if end := bytes.IndexByte(s, 0); end != -1 {
+		s = s[:end]
+	}
+	return string(s)
+}
+
+func (p *btfParser) parseType() *btfType {
+	name, info, sizeOrType := p.u32(), p.u32(), p.u32()
+	vlen := int(info & 0xffff)
+	kindFlag := info>>31 != 0
+	t := &btfType{
+		kind: btfKind(info >> 24 & 0x1f),
+		name: p.str(name),
+	}
+	switch t.kind {
+	case btfKindInt:
+		t.size = sizeOrType
+		enc := p.u32()
+		t.intEncoding = enc >> 24 & 0xf
+		t.intOffset = enc >> 16 & 0xff
+		t.intBits = enc & 0xff
+	case btfKindPtr, btfKindTypedef, btfKindVolatile, btfKindConst, btfKindRestrict,
+		btfKindFunc, btfKindTypeTag:
+		t.typ = int(sizeOrType)
+	case btfKindArray:
+		t.elem = int(p.u32())
+		p.u32() // index type
+		t.nelems = p.u32()
+	case btfKindStruct, btfKindUnion:
+		t.size = sizeOrType
+		for i := 0; i < vlen; i++ {
+			m := btfMember{name: p.str(p.u32()), typ: int(p.u32())}
+			off := p.u32()
+			if kindFlag {
+				m.bitSize, m.bitOffset = off>>24, off&0xffffff
+			} else {
+				m.bitOffset = off
+			}
+			t.members = append(t.members, m)
+		}
+	case btfKindEnum:
+		t.size = sizeOrType
+		for i := 0; i < vlen; i++ {
+			name, val := p.str(p.u32()), p.u32()
+			v := uint64(val)
+			if kindFlag {
+				v = uint64(int64(int32(val)))
+			}
+			t.values = append(t.values, btfEnumValue{name, v})
+		}
+	case btfKindEnum64:
+		t.size = sizeOrType
+		for i := 0; i < vlen; i++ {
+			name, lo, hi := p.str(p.u32()), p.u32(), p.u32()
+			t.values = append(t.values, btfEnumValue{name, uint64(hi)<<32 | uint64(lo)})
+		}
+	case btfKindFwd:
+	case btfKindFuncProto:
+		t.typ = int(sizeOrType)
+		for i := 0; i < vlen; i++ {
+			p.u32() // name
+			p.u32() // type
+		}
+	case btfKindVar:
+		t.typ = int(sizeOrType)
+		p.u32() // linkage
+	case btfKindDatasec:
+		t.size = sizeOrType
+		for i := 0; i < vlen; i++ {
+			p.u32() // type
+			p.u32() // offset
+			p.u32() // size
+		}
+	case btfKindFloat:
+		t.size = sizeOrType
+	case btfKindDeclTag:
+		t.typ = int(sizeOrType)
+		p.u32() // component index
+	default:
+		if p.err == nil {
+			p.err = fmt.Errorf("unknown kind %v", t.kind)
+		}
+		p.data = nil
+	}
+	return t
+}
+
+// refs returns IDs of types referenced by the type.
+func (t *btfType) refs() []int {
+	refs := []int{t.typ, t.elem}
+	for _, m := range t.members {
+		refs = append(refs, m.typ)
+	}
+	return refs
+}
+
+// resolve skips typedefs and type modifiers. It returns the underlying type ID
+// and the name of the last typedef on the way (if any).
+func (spec *btfSpec) resolve(id int) (int, string) {
+	typedef := ""
+	for range spec.types {
+		t := spec.types[id]
+		switch t.kind {
+		case btfKindTypedef:
+			typedef = t.name
+		case btfKindVolatile, btfKindConst, btfKindRestrict, btfKindTypeTag:
+		default:
+			return id, typedef
+		}
+		id = t.typ
+	}
+	return 0, "" // typedef loop in malformed BTF
+}
@@ -0,0 +1,219 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+// btfBuilder builds raw little-endian BTF data.
+type btfBuilder struct {
+	types   []uint32
+	strings []byte
+	// Offsets of types in types.
+	offsets []int
+}
+
+func newBTFBuilder() *btfBuilder {
+	return &btfBuilder{strings: []byte{0}}
+}
+
+func (b *btfBuilder) str(s string) uint32 {
+	if s == "" {
+		return 0
+	}
+	off := uint32(len(b.strings))
+	b.strings = append(append(b.strings, s...), 0)
+	return off
+}
+
+func (b *btfBuilder) add(name string, kind btfKind, vlen int, kindFlag bool, sizeOrType uint32, data ...uint32) int {
+	info := uint32(kind)<<24 | uint32(vlen)
+	if kindFlag {
+		info |= 1 << 31
+	}
+	b.offsets = append(b.offsets, len(b.types))
+	b.types = append(b.types, b.str(name), info, sizeOrType)
+	b.types = append(b.types, data...)
+	return len(b.offsets)
+}
+
+// setType sets the referenced type of a previously added type (e.g. a pointer).
+func (b *btfBuilder) setType(id, typ int) {
+	b.types[b.offsets[id-1]+2] = uint32(typ)
+}
+
+func (b *btfBuilder) integer(name string, size, bits, encoding uint32) int {
+	return b.add(name, btfKindInt, 0, false, size, encoding<<24|bits)
+}
+
+type testMember struct {
+	name      string
+	typ       int
+	bitOffset uint32
+	bitSize   uint32
+}
+
+func (b *btfBuilder) composite(kind btfKind, name string, size uint32, members ...testMember) int {
+	var data []uint32
+	kindFlag := false
+	for _, m := range members {
+		if m.bitSize != 0 {
+			kindFlag = true
+		}
+	}
+	for _, m := range members {
+		off := m.bitOffset
+		if kindFlag {
+			off |= m.bitSize << 24
+		}
+		data = append(data, b.str(m.name), uint32(m.typ), off)
+	}
+	return b.add(name, kind, len(members), kindFlag, size, data...)
+}
+
+func (b *btfBuilder) data() []byte {
+	typeLen := uint32(len(b.types) * 4)
+	hdr := []uint32{btfHdrLen, 0, typeLen, typeLen, uint32(len(b.strings))}
+	res := []byte{0x9f, 0xeb, 1, 0}
+	for _, v := range append(hdr, b.types...) {
+		res = binary.LittleEndian.AppendUint32(res, v)
+	}
+	return append(res, b.strings...)
+}
+
+func TestGenerate(t *testing.T) {
+	b := newBTFBuilder()
+	u8 := b.integer("unsigned char", 1, 8, 0)
+	u16 := b.integer("short unsigned int", 2, 16, 0)
+	u32 := b.integer("unsigned int", 4, 32, 0)
+	u64 := b.integer("long long unsigned int", 8, 64, 0)
+	boolean := b.integer("_Bool", 1, 8, btfIntBool)
+	typedefU32 := b.add("__u32", btfKindTypedef, 0, false, uint32(u32))
+	constU64 := b.add("", btfKindConst, 0, false, uint32(u64))
+	enum := b.add("foo_mode", btfKindEnum, 2, false, 4,
+		b.str("FOO_MODE_A"), 0, b.str("FOO_MODE_B"), 0x10)
+	name := b.add("", btfKindArray, 0, false, 0, uint32(u8), uint32(u32), 10)
+	flex := b.add("", btfKindArray, 0, false, 0, uint32(u16), uint32(u32), 0)
+	fooPtr := b.add("", btfKindPtr, 0, false, 0)
+	voidPtr := b.add("", btfKindPtr, 0, false, 0)
+	anonUnion := b.composite(btfKindUnion, "", 8,
+		testMember{name: "val", typ: constU64},
+		testMember{name: "raw", typ: b.add("", btfKindArray, 0, false, 0, uint32(u8), uint32(u32), 8)},
+	)
+	foo := b.composite(btfKindStruct, "foo", 48,
+		testMember{name: "mode", typ: enum, bitOffset: 0},
+		testMember{name: "flag", typ: boolean, bitOffset: 32},
+		testMember{name: "a", typ: typedefU32, bitOffset: 40, bitSize: 3},
+		testMember{name: "b", typ: typedefU32, bitOffset: 43, bitSize: 5},
+		testMember{name: "name", typ: name, bitOffset: 48},
+		testMember{name: "", typ: anonUnion, bitOffset: 128},
+		testMember{name: "next", typ: fooPtr, bitOffset: 192},
+		testMember{name: "data", typ: voidPtr, bitOffset: 256},
+		testMember{name: "parent", typ: u16, bitOffset: 320},
+		testMember{name: "items", typ: flex, bitOffset: 336},
+	)
+	b.setType(fooPtr, foo)
+	// Packed struct that needs explicit layout: struct bar { u8 x; u32 y; u16 z; u8 w; } __packed.
+	b.composite(btfKindStruct, "bar", 8,
+		testMember{name: "x", typ: u8, bitOffset: 0},
+		testMember{name: "y", typ: u32, bitOffset: 8},
+		testMember{name: "z", typ: u16, bitOffset: 40},
+		testMember{name: "w", typ: u8, bitOffset: 56},
+	)
+	// Over-aligned struct: struct qux { u32 x; } __aligned(8).
+	b.composite(btfKindStruct, "qux", 8, testMember{name: "x", typ: u32})
+	// Unnamed padding bitfield: struct pad { u32 a:3; u32 :2; u32 b:4; }.
+	b.composite(btfKindStruct, "pad", 4,
+		testMember{name: "a", typ: u32, bitOffset: 0, bitSize: 3},
+		testMember{name: "b", typ: u32, bitOffset: 5, bitSize: 4},
+	)
+	// Not selected.
+	b.composite(btfKindStruct, "baz", 4, testMember{name: "x", typ: u32})
+	spec, err := parseBTF(b.data())
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := targets.Get(targets.Linux, targets.AMD64)
+	desc, consts, warnings, err := generate(spec, target, regexp.MustCompile("^(foo|bar|qux|pad)$"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("got warnings: %q", warnings)
+	}
+	want := `# Code generated by syz-btf. DO NOT EDIT.
+
+bar {
+	x	int8
+	y	int32
+	z	int16
+	w	int8
+} [packed]
+
+foo {
+	mode	flags[foo_mode, int32]
+	flag	bool8
+	a	int32:3
+	b	int32:5
+	name	array[int8, 10]
+	anon5	foo_anon5
+	next	ptr[inout, foo, opt]
+	data	intptr
+	parent_	int16
+	items	array[int16]
+}
+
+foo_anon5 [
+	val	int64
+	raw	array[int8, 8]
+]
+
+pad {
+	a	int32:3
+	_pad1	const[0, int32:2]
+	b	int32:4
+}
+
+qux {
+	x	int32
+	_pad1	array[const[0, int8], 4]
+} [packed, align[4]]
+
+foo_mode = FOO_MODE_A, FOO_MODE_B
+`
+	if diff := cmp.Diff(want, string(desc)); diff != "" {
+		t.Fatal(diff)
+	}
+	if !strings.Contains(string(consts), "FOO_MODE_B = amd64:16") {
+		t.Fatalf("bad consts:\n%s", consts)
+	}
+}
+
+func TestParseBTFErrors(t *testing.T) {
+	b := newBTFBuilder()
+	b.add("ptr", btfKindPtr, 0, false, 10)
+	data := b.data()
+	tests := []struct {
+		data []byte
+		err  string
+	}{
+		{data[:10], "BTF data is too short"},
+		{append([]byte{1, 2}, data[2:]...), "bad BTF magic"},
+		{data[:len(data)-1], "out of bounds"},
+		{data, "references non-existent type 10"},
+	}
+	for _, test := range tests {
+		_, err := parseBTF(test.data)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("got error %v, want %q", err, test.err)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/google/syzkaller/pkg/ast"
+	"github.com/google/syzkaller/prog"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+// generator converts BTF structs/unions/enums into syzlang descriptions.
+// Structs are first described with natural layout (as the compiler lays them out),
+// structs for which this does not give BTF layout are then described with explicit padding
+// (see validate.go).
+type generator struct {
+	spec   *btfSpec
+	target *targets.Target
+	// Emitted structs/unions keyed by BTF type ID.
+	structs map[int]*genStruct
+	order   []*genStruct
+	// Emitted flags keyed by BTF enum type ID.
+	flags      map[int]*ast.IntFlags
+	typeNames  map[string]bool
+	flagsNames map[string]bool
+	// Enum values referenced by the flags.
+	consts   map[string]uint64
+	warnings []string
+}
+
+type genStruct struct {
+	id   int
+	name string
+	// Fields corresponding to node fields.
+	fields []genField
+	node   *ast.Struct
+	// Layout the struct with explicit padding.
+	explicit bool
+	// Alignment of the struct with natural layout.
+	align uint64
+	// The struct is contained in other structs/unions by value.
+	embedded bool
+}
+
+type genField struct {
+	member int // index of the BTF member or -1 for padding
+	// Expected layout of the field.
+	bitOffset uint32
+	bitSize   uint32
+}
+
+// Names that can't be used for generated types (builtin types and reserved words).
+var reservedTypeNames = map[string]bool{
+	"int8": true, "int16": true, "int32": true, "int64": true, "int16be": true, "int32be": true,
+	"int64be": true, "intptr": true, "bool8": true, "bool16": true, "bool32": true, "bool64": true,
+	"boolptr": true, "ptr": true, "ptr64": true, "void": true, "array": true, "len": true, "bytesize": true,
+	"bytesize2": true, "bytesize4": true, "bytesize8": true, "bitsize": true, "offsetof": true,
+	"const": true, "flags": true, "vma": true, "vma64": true, "csum": true, "proc": true, "text": true,
+	"string": true, "glob": true, "stringnoz": true, "fmt": true, "compressed_image": true,
+	"fileoff": true, "filename": true, "buffer": true, "optional": true,
+	"opt": true, "in": true, "out": true, "inout": true, "type": true, "meta": true,
+	"include": true, "incdir": true, "define": true, "resource": true,
+}
+
+// Keywords that can't be used as field names.
+var reservedFieldNames = map[string]bool{
+	"include": true, "incdir": true, "define": true, "resource": true,
+	prog.ParentRef: true, prog.SyscallRef: true,
+}
+
+func newGenerator(spec *btfSpec, target *targets.Target) *generator {
+	return &generator{
+		spec:       spec,
+		target:     target,
+		structs:    make(map[int]*genStruct),
+		flags:      make(map[int]*ast.IntFlags),
+		typeNames:  make(map[string]bool),
+		flagsNames: make(map[string]bool),
+		consts:     make(map[string]uint64),
+	}
+}
+
+// selectTypes adds all named structs/unions matching the regexp and all types they contain by value.
+// Anonymous structs/unions are named after their typedefs.
+func (g *generator) selectTypes(re *regexp.Regexp) {
+	for id, t := range g.spec.types {
+		name := t.name
+		if t.kind == btfKindTypedef {
+			rid, _ := g.spec.resolve(id)
+			if rt := g.spec.types[rid]; rt.name == "" && (rt.kind == btfKindStruct || rt.kind == btfKindUnion) {
+				id, t = rid, rt
+			}
+		}
+		if name == "" || t.kind != btfKindStruct && t.kind != btfKindUnion || !re.MatchString(name) {
+			continue
+		}
+		g.addStruct(id, name)
+	}
+}
+
+func (g *generator) addStruct(id int, name string) *genStruct {
+	if s := g.structs[id]; s != nil {
+		return s
+	}
+	t := g.spec.types[id]
+	if t.size == 0 {
+		// Empty structs are not supported in descriptions (and can't affect layout anyway).
+		return nil
+	}
+	s := &genStruct{
+		id:   id,
+		name: g.uniqueName(g.typeNames, name),
+	}
+	g.structs[id] = s
+	g.order = append(g.order, s)
+	for i, m := range t.members {
+		rid, typedef := g.spec.resolve(m.typ)
+		for g.spec.types[rid].kind == btfKindArray {
+			rid, typedef = g.spec.resolve(g.spec.types[rid].elem)
+		}
+		rt := g.spec.types[rid]
+		if rt.kind != btfKindStruct && rt.kind != btfKindUnion {
+			continue
+		}
+		name := rt.name
+		if name == "" {
+			name = typedef
+		}
+		if name == "" {
+			name = s.name + "_" + memberName(m, i)
+		}
+		if child := g.addStruct(rid, name); child != nil {
+			child.embedded = true
+		}
+	}
+	return s
+}
+
+func (g *generator) uniqueName(names map[string]bool, name string) string {
+	res := name
+	for i := 1; reservedTypeNames[res] || names[res]; i++ {
+		res = fmt.Sprintf("%v$%v", name, i)
+	}
+	names[res] = true
+	return res
+}
+
+func memberName(m btfMember, i int) string {
+	if m.name == "" {
+		return fmt.Sprintf("anon%v", i)
+	}
+	if reservedFieldNames[m.name] {
+		return m.name + "_"
+	}
+	return m.name
+}
+
+// generate (re)generates descriptions for all selected structs.
+func (g *generator) generate() *ast.Description {
+	desc := &ast.Description{}
+	structs := append([]*genStruct{}, g.order...)
+	sort.Slice(structs, func(i, j int) bool {
+		return structs[i].name < structs[j].name
+	})
+	for _, s := range structs {
+		g.genStruct(s)
+		desc.Nodes = append(desc.Nodes, s.node, &ast.NewLine{})
+	}
+	var flags []*ast.IntFlags
+	for _, f := range g.flags {
+		flags = append(flags, f)
+	}
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].Name.Name < flags[j].Name.Name
+	})
+	for _, f := range flags {
+		desc.Nodes = append(desc.Nodes, f)
+	}
+	return desc
+}
+
+func (g *generator) genStruct(s *genStruct) {
+	t := g.spec.types[s.id]
+	s.node = &ast.Struct{
+		Name:    &ast.Ident{Name: s.name},
+		IsUnion: t.kind == btfKindUnion,
+	}
+	s.fields = nil
+	var offset uint32 // in bytes, only for explicit layout
+	addPad := func(size uint32) {
+		s.node.Fields = append(s.node.Fields, &ast.Field{
+			Name: &ast.Ident{Name: fmt.Sprintf("_pad%v", len(s.node.Fields))},
+			Type: makeType("array", makeType("const", makeNum(0), makeType("int8")), makeNum(uint64(size))),
+		})
+		s.fields = append(s.fields, genField{member: -1})
+		offset += size
+	}
+	var maxSize uint32
+	var bitEnd uint32 // end of the previous member if it's a bitfield
+	varlen := false
+	for i, m := range t.members {
+		// Flexible array members are described only in top-level structs,
+		// in embedded structs they would make the parent struct varlen.
+		flexible := i == len(t.members)-1 && g.isFlexibleArray(m.typ) && !s.embedded && !s.node.IsUnion
+		bitOffset, bitSize := m.bitOffset, m.bitSize
+		if rt := g.spec.types[g.resolveID(m.typ)]; rt.kind == btfKindInt && bitSize == 0 && rt.intBits != rt.size*8 {
+			// Old-style bitfield encoding in the int type.
+			bitOffset, bitSize = bitOffset+rt.intOffset, rt.intBits
+		}
+		if s.node.IsUnion {
+			bitSize = 0
+		}
+		size := g.size(m.typ)
+		if size == 0 && !flexible {
+			continue
+		}
+		varlen = flexible
+		typ := g.fieldType(m.typ, bitSize, flexible)
+		if typ == nil {
+			g.warnings = append(g.warnings, fmt.Sprintf("%v.%v: unsupported type %v",
+				s.name, memberName(m, i), g.spec.types[g.resolveID(m.typ)].kind))
+			typ = makeType("array", makeType("int8"), makeNum(uint64(size)))
+		}
+		if s.explicit && bitOffset/8 > offset {
+			addPad(bitOffset/8 - offset)
+		}
+		if gap := bitOffset - bitEnd; !s.explicit && bitSize != 0 && bitEnd != 0 && bitOffset > bitEnd && gap < size*8 {
+			// Unnamed bitfields used as padding are not present in BTF.
+			s.node.Fields = append(s.node.Fields, &ast.Field{
+				Name: &ast.Ident{Name: fmt.Sprintf("_pad%v", len(s.node.Fields))},
+				Type: makeType("const", makeNum(0), g.intType(size, gap)),
+			})
+			s.fields = append(s.fields, genField{member: -1})
+		}
+		s.node.Fields = append(s.node.Fields, &ast.Field{
+			Name: &ast.Ident{Name: memberName(m, i)},
+			Type: typ,
+		})
+		s.fields = append(s.fields, genField{member: i, bitOffset: bitOffset, bitSize: bitSize})
+		bitEnd = 0
+		if bitSize != 0 {
+			bitEnd = bitOffset + bitSize
+			offset = (bitEnd + 7) / 8
+		} else {
+			offset = bitOffset/8 + size
+		}
+		if maxSize < size {
+			maxSize = size
+		}
+	}
+	if len(s.node.Fields) == 0 {
+		// All members have zero size, but the struct doesn't.
+		addPad(t.size)
+	}
+	if s.node.IsUnion {
+		if t.size > maxSize {
+			s.node.Attrs = append(s.node.Attrs, makeType("size", makeNum(uint64(t.size))))
+		}
+		return
+	}
+	if s.explicit {
+		if offset < t.size && !varlen {
+			addPad(t.size - offset)
+		}
+		s.node.Attrs = append(s.node.Attrs, makeType("packed"))
+		if s.align > 1 && uint64(t.size)%s.align == 0 {
+			s.node.Attrs = append(s.node.Attrs, makeType("align", makeNum(s.align)))
+		}
+	}
+}
+
+// fieldType returns syzlang type for a BTF type. Bitfields are described with the size of the unit type.
+func (g *generator) fieldType(id int, bitSize uint32, flexible bool) *ast.Type {
+	rid, typedef := g.spec.resolve(id)
+	t := g.spec.types[rid]
+	switch t.kind {
+	case btfKindInt:
+		if t.intEncoding&btfIntBool != 0 && bitSize == 0 && t.size == 1 {
+			return makeType("bool8")
+		}
+		return g.intType(t.size, bitSize)
+	case btfKindEnum, btfKindEnum64:
+		base := g.intType(t.size, bitSize)
+		if base == nil {
+			return nil
+		}
+		name := g.enumFlags(rid, typedef)
+		if name == "" {
+			return base
+		}
+		return makeType("flags", makeType(name), base)
+	case btfKindFloat:
+		return g.intType(t.size, 0)
+	case btfKindPtr:
+		if s := g.structs[g.resolveID(t.typ)]; s != nil {
+			return makeType("ptr", makeType("inout"), makeType(s.name), makeType("opt"))
+		}
+		return makeType("intptr")
+	case btfKindArray:
+		elem := g.fieldType(t.elem, 0, false)
+		if elem == nil {
+			return nil
+		}
+		if t.nelems == 0 && flexible {
+			return makeType("array", elem)
+		}
+		return makeType("array", elem, makeNum(uint64(t.nelems)))
+	case btfKindStruct, btfKindUnion:
+		if s := g.structs[rid]; s != nil {
+			return makeType(s.name)
+		}
+	}
+	return nil
+}
+
+func (g *generator) intType(size, bitSize uint32) *ast.Type {
+	var typ *ast.Type
+	switch size {
+	case 1, 2, 4, 8:
+		typ = makeType(fmt.Sprintf("int%v", size*8))
+	default:
+		if bitSize != 0 {
+			return nil
+		}
+		return makeType("array", makeType("int8"), makeNum(uint64(size)))
+	}
+	if bitSize != 0 {
+		typ.Colon = []*ast.Type{makeNum(uint64(bitSize))}
+	}
+	return typ
+}
+
+// enumFlags returns name of the flags for the enum, or an empty string if the enum can't be described as flags.
+func (g *generator) enumFlags(id int, typedef string) string {
+	if f := g.flags[id]; f != nil {
+		return f.Name.Name
+	}
+	t := g.spec.types[id]
+	name := t.name
+	if name == "" {
+		name = typedef
+	}
+	if name == "" || len(t.values) == 0 {
+		return ""
+	}
+	f := &ast.IntFlags{Name: &ast.Ident{Name: g.uniqueName(g.flagsNames, name)}}
+	mask := ^uint64(0)
+	if t.size < 8 {
+		mask = 1<<(t.size*8) - 1
+	}
+	for _, v := range t.values {
+		val := v.val & mask
+		if prev, ok := g.consts[v.name]; !ok || prev == val {
+			g.consts[v.name] = val
+			f.Values = append(f.Values, &ast.Int{Ident: v.name})
+		} else {
+			// Should not happen in C, but may happen with duplicate types in BTF.
+			f.Values = append(f.Values, &ast.Int{Value: val, ValueFmt: ast.IntFmtHex})
+		}
+	}
+	g.flags[id] = f
+	return f.Name.Name
+}
+
+func (g *generator) resolveID(id int) int {
+	rid, _ := g.spec.resolve(id)
+	return rid
+}
+
+func (g *generator) isFlexibleArray(id int) bool {
+	t := g.spec.types[g.resolveID(id)]
+	return t.kind == btfKindArray && t.nelems == 0
+}
+
+// size returns size of the type in bytes.
+func (g *generator) size(id int) uint32 {
+	t := g.spec.types[g.resolveID(id)]
+	switch t.kind {
+	case btfKindInt, btfKindEnum, btfKindEnum64, btfKindFloat, btfKindStruct, btfKindUnion:
+		return t.size
+	case btfKindPtr:
+		return uint32(g.target.PtrSize)
+	case btfKindArray:
+		return g.size(t.elem) * t.nelems
+	}
+	return 0
+}
+
+func makeType(name string, args ...*ast.Type) *ast.Type {
+	return &ast.Type{Ident: name, Args: args}
+}
+
+func makeNum(v uint64) *ast.Type {
+	return &ast.Type{Value: v}
+}
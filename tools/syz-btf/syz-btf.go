@@ -0,0 +1,93 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// syz-btf generates syzlang descriptions of kernel structs, unions and enums from BTF
+// (the .BTF section of vmlinux or /sys/kernel/btf/vmlinux). Use:
+//
+//	$ syz-btf -btf vmlinux -types '^(sockaddr_.*|ifreq)$' -out sys/linux/btf.txt -consts sys/linux/btf.txt.const
+//
+// Structs and unions matching -types are emitted together with all types they contain by value.
+// Enums become flags, pointers to emitted types become opt pointers, all other pointers become intptr.
+// Semantics of fields (directions, lengths, resources) can't be deduced from BTF,
+// so the output is a starting point for manual descriptions rather than a final result.
+//
+// Generated descriptions are compiled for the target and layout computed by the compiler
+// is compared with BTF offsets and sizes. Structs with non-natural layout (e.g. packed or over-aligned)
+// are described with explicit padding, remaining mismatches (e.g. some packed bitfields) are printed as warnings.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/google/syzkaller/pkg/ast"
+	"github.com/google/syzkaller/pkg/compiler"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/pkg/tool"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func main() {
+	var (
+		flagBTF    = flag.String("btf", "/sys/kernel/btf/vmlinux", "vmlinux or raw BTF file")
+		flagTypes  = flag.String("types", "", "regexp for names of structs/unions to generate (all by default)")
+		flagOS     = flag.String("os", targets.Linux, "target OS")
+		flagArch   = flag.String("arch", targets.AMD64, "target arch")
+		flagOut    = flag.String("out", "", "output file for descriptions (stdout by default)")
+		flagConsts = flag.String("consts", "", "output file for values of enums used in descriptions")
+	)
+	defer tool.Init()()
+	target := targets.Get(*flagOS, *flagArch)
+	if target == nil {
+		tool.Failf("unknown target %v/%v", *flagOS, *flagArch)
+	}
+	re, err := regexp.Compile(*flagTypes)
+	if err != nil {
+		tool.Failf("bad -types: %v", err)
+	}
+	spec, err := loadBTF(*flagBTF)
+	if err != nil {
+		tool.Fail(err)
+	}
+	desc, consts, warnings, err := generate(spec, target, re)
+	if err != nil {
+		tool.Fail(err)
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", w)
+	}
+	if *flagOut == "" {
+		os.Stdout.Write(desc)
+	} else if err := osutil.WriteFile(*flagOut, desc); err != nil {
+		tool.Fail(err)
+	}
+	if *flagConsts != "" {
+		if err := osutil.WriteFile(*flagConsts, consts); err != nil {
+			tool.Fail(err)
+		}
+	}
+}
+
+// generate returns formatted descriptions of the selected types and the const file for them.
+func generate(spec *btfSpec, target *targets.Target, re *regexp.Regexp) ([]byte, []byte, []string, error) {
+	g := newGenerator(spec, target)
+	g.selectTypes(re)
+	if len(g.order) == 0 {
+		return nil, nil, nil, fmt.Errorf("no structs/unions matching %q", re)
+	}
+	desc, mismatches, err := g.run()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "# Code generated by syz-btf. DO NOT EDIT.\n\n")
+	ast.FormatWriter(buf, desc)
+	cf := compiler.NewConstFile()
+	if err := cf.AddArch(target.Arch, g.consts, nil); err != nil {
+		return nil, nil, nil, err
+	}
+	return buf.Bytes(), cf.Serialize(), append(g.warnings, mismatches...), nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/google/syzkaller/pkg/ast"
+	"github.com/google/syzkaller/pkg/compiler"
+	"github.com/google/syzkaller/prog"
+)
+
+// run generates descriptions and checks them against the compiler layout computation.
+// Structs that the compiler lays out differently from BTF are switched to explicit layout
+// and everything is compiled again. Returns descriptions and layout mismatches that are left.
+func (g *generator) run() (*ast.Description, []string, error) {
+	for {
+		desc := g.generate()
+		types, err := g.compile(desc)
+		if err != nil {
+			return nil, nil, err
+		}
+		var mismatches []string
+		changed := false
+		for _, s := range g.order {
+			typ := types[s.name]
+			if typ == nil {
+				return nil, nil, fmt.Errorf("no compiled type for %v", s.name)
+			}
+			res := g.validate(s, typ)
+			if len(res) == 0 {
+				continue
+			}
+			if str, ok := typ.(*prog.StructType); ok && !s.explicit {
+				s.explicit = true
+				if g.alignedMembers(s, str) {
+					// Probably an over-aligned struct or a struct with an over-aligned member,
+					// keep at least the natural alignment. Otherwise it's a packed struct.
+					s.align = typ.Alignment()
+				}
+				changed = true
+				continue
+			}
+			mismatches = append(mismatches, res...)
+		}
+		if !changed {
+			return desc, mismatches, nil
+		}
+	}
+}
+
+// compile compiles the descriptions with a pseudo-syscall for every struct (otherwise they are unused)
+// and returns compiled types keyed by name.
+func (g *generator) compile(desc *ast.Description) (map[string]prog.Type, error) {
+	desc = desc.Clone()
+	for i, s := range g.order {
+		desc.Nodes = append(desc.Nodes, &ast.Call{
+			Name: &ast.Ident{Name: fmt.Sprintf("syz_btf$%v", i)},
+			Args: []*ast.Field{{
+				Name: &ast.Ident{Name: "a"},
+				Type: makeType("ptr", makeType("in"), makeType(s.name)),
+			}},
+		})
+	}
+	// Re-parse formatted descriptions to get positions for error messages.
+	const file = "btf.txt"
+	errors := new(bytes.Buffer)
+	eh := func(pos ast.Pos, msg string) {
+		fmt.Fprintf(errors, "%v: %v\n", pos, msg)
+	}
+	desc = ast.Parse(ast.Format(desc), file, eh)
+	if desc == nil {
+		return nil, fmt.Errorf("failed to parse generated descriptions:\n%s", errors.Bytes())
+	}
+	res := compiler.Compile(desc, g.consts, g.target, eh)
+	if res == nil {
+		return nil, fmt.Errorf("failed to compile generated descriptions:\n%s", errors.Bytes())
+	}
+	prog.RestoreLinks(res.Syscalls, res.Resources, res.Types)
+	types := make(map[string]prog.Type)
+	for _, typ := range res.Types {
+		switch typ.(type) {
+		case *prog.StructType, *prog.UnionType:
+			types[typ.Name()] = typ
+		}
+	}
+	return types, nil
+}
+
+// alignedMembers checks if all non-bitfield members have offsets aligned to their natural alignment.
+func (g *generator) alignedMembers(s *genStruct, typ *prog.StructType) bool {
+	fi := 0
+	for _, field := range typ.Fields {
+		if prog.IsPad(field.Type) {
+			continue
+		}
+		gf := s.fields[fi]
+		fi++
+		align := field.Type.Alignment()
+		if gf.member >= 0 && gf.bitSize == 0 && align != 0 && uint64(gf.bitOffset)%(align*8) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// validate compares layout of the compiled type with BTF.
+func (g *generator) validate(s *genStruct, typ prog.Type) []string {
+	var res []string
+	t := g.spec.types[s.id]
+	if !typ.Varlen() && typ.Size() != uint64(t.size) {
+		res = append(res, fmt.Sprintf("%v: size %v, want %v", s.name, typ.Size(), t.size))
+	}
+	str, ok := typ.(*prog.StructType)
+	if !ok {
+		return res
+	}
+	fi := 0
+	offset := uint64(0)
+	for _, field := range str.Fields {
+		if prog.IsPad(field.Type) {
+			offset += field.Size()
+			continue
+		}
+		gf := s.fields[fi]
+		fi++
+		if gf.member >= 0 {
+			bitOffset := (offset-field.Type.UnitOffset())*8 + field.Type.BitfieldOffset()
+			if bitOffset != uint64(gf.bitOffset) || field.Type.BitfieldLength() != uint64(gf.bitSize) {
+				res = append(res, fmt.Sprintf("%v.%v: bit offset/size %v/%v, want %v/%v",
+					s.name, field.Name, bitOffset, field.Type.BitfieldLength(), gf.bitOffset, gf.bitSize))
+			}
+		}
+		if field.Varlen() {
+			break
+		}
+		offset += field.Size()
+	}
+	return res
+}
@@ -14,6 +14,16 @@
 // Usage:
 //
 //	syz-cover [-os=OS -arch=ARCH -kernel_src=. -kernel_obj=.] rawcover.file*
+//
+// With -base flag syz-cover generates a differential report that shows lines and functions
+// covered by rawcover.file* but not by the base files and vice versa. If the base coverage
+// was collected on a different kernel build, pass its location with -base_kernel_* flags,
+// in such case coverage is matched by file:line:
+//
+//	syz-cover -base=old.rawcover [-base_kernel_obj=old/] new.rawcover
+//
+// With -config flag, coverage is additionally grouped by kernel subsystems from
+// the kernel_subsystem parameter of the manager config.
 package main
 
 import (
@@ -28,6 +38,7 @@ import (
 	"strings"
 
 	"github.com/google/syzkaller/pkg/cover"
+	"github.com/google/syzkaller/pkg/mgrconfig"
 	"github.com/google/syzkaller/pkg/osutil"
 	"github.com/google/syzkaller/pkg/tool"
 	"github.com/google/syzkaller/sys/targets"
@@ -44,6 +55,11 @@ func main() {
 		flagExportCSV      = flag.String("csv", "", "export coverage data in csv format (optional)")
 		flagExportLineJSON = flag.String("json", "", "export coverage data with source line info in json format (optional)")
 		flagExportHTML     = flag.String("html", "", "save coverage HTML report to file (optional)")
+		flagBase           = flag.String("base", "", "comma-separated list of base rawcover files for differential report")
+		flagBaseKernelSrc  = flag.String("base_kernel_src", "", "path to kernel sources of the base build (optional)")
+		flagBaseKernelObj  = flag.String("base_kernel_obj", "", "path to kernel build/obj dir of the base build (optional)")
+		flagBaseBuildSrc   = flag.String("base_kernel_build_src", "", "path to kernel build dir of the base build (optional)")
+		flagConfig         = flag.String("config", "", "manager config to take kernel subsystems from (optional)")
 	)
 	defer tool.Init()()
 
@@ -68,17 +84,65 @@ func main() {
 	if target == nil {
 		tool.Failf("unknown target %v/%v", *flagOS, *flagArch)
 	}
+	var subsystems []mgrconfig.Subsystem
+	if *flagConfig != "" {
+		cfg, err := mgrconfig.LoadPartialFile(*flagConfig)
+		if err != nil {
+			tool.Fail(err)
+		}
+		subsystems = cfg.KernelSubsystem
+	}
 	pcs, err := readPCs(flag.Args())
 	if err != nil {
 		tool.Fail(err)
 	}
 	rg, err := cover.MakeReportGenerator(target, *flagVM, *flagKernelObj,
-		*flagKernelSrc, *flagKernelBuildSrc, nil, nil, nil, false)
+		*flagKernelSrc, *flagKernelBuildSrc, subsystems, nil, nil, false)
 	if err != nil {
 		tool.Fail(err)
 	}
 	progs := []cover.Prog{{PCs: pcs}}
 	buf := new(bytes.Buffer)
+	if *flagBase != "" {
+		basePCs, err := readPCs(strings.Split(*flagBase, ","))
+		if err != nil {
+			tool.Fail(err)
+		}
+		base := rg
+		if *flagBaseKernelSrc != "" || *flagBaseKernelObj != "" || *flagBaseBuildSrc != "" {
+			baseSrc, baseObj := *flagKernelSrc, *flagKernelObj
+			if *flagBaseKernelSrc != "" {
+				baseSrc = osutil.Abs(*flagBaseKernelSrc)
+			}
+			if *flagBaseKernelObj != "" {
+				baseObj = osutil.Abs(*flagBaseKernelObj)
+			}
+			baseBuildSrc := baseSrc
+			if *flagBaseBuildSrc != "" {
+				baseBuildSrc = osutil.Abs(*flagBaseBuildSrc)
+			}
+			base, err = cover.MakeReportGenerator(target, *flagVM, baseObj, baseSrc, baseBuildSrc,
+				subsystems, nil, nil, false)
+			if err != nil {
+				tool.Fail(err)
+			}
+		}
+		baseProgs := []cover.Prog{{PCs: basePCs}}
+		if *flagExportCSV != "" {
+			if err := rg.DoDiffCSV(buf, base, baseProgs, progs); err != nil {
+				tool.Fail(err)
+			}
+			if err := osutil.WriteFile(*flagExportCSV, buf.Bytes()); err != nil {
+				tool.Fail(err)
+			}
+			return
+		}
+		if err := rg.DoDiffHTML(buf, base, baseProgs, progs); err != nil {
+			tool.Fail(err)
+		}
+		showHTML(buf.Bytes(), *flagExportHTML)
+		return
+	}
 	if *flagExportCSV != "" {
 		if err := rg.DoCSV(buf, progs, nil); err != nil {
 			tool.Fail(err)
@@ -100,8 +164,12 @@ func main() {
 	if err := rg.DoHTML(buf, progs, nil); err != nil {
 		tool.Fail(err)
 	}
-	if *flagExportHTML != "" {
-		if err := osutil.WriteFile(*flagExportHTML, buf.Bytes()); err != nil {
+	showHTML(buf.Bytes(), *flagExportHTML)
+}
+
+func showHTML(data []byte, file string) {
+	if file != "" {
+		if err := osutil.WriteFile(file, data); err != nil {
 			tool.Fail(err)
 		}
 		return
@@ -111,7 +179,7 @@ func main() {
 		tool.Fail(err)
 	}
 	fn += ".html"
-	if err := osutil.WriteFile(fn, buf.Bytes()); err != nil {
+	if err := osutil.WriteFile(fn, data); err != nil {
 		tool.Fail(err)
 	}
 	if err := exec.Command("xdg-open", fn).Start(); err != nil {
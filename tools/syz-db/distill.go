@@ -0,0 +1,166 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/google/syzkaller/pkg/db"
+	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/pkg/ipc"
+	"github.com/google/syzkaller/pkg/ipc/ipcconfig"
+	"github.com/google/syzkaller/pkg/signal"
+	"github.com/google/syzkaller/pkg/tool"
+	"github.com/google/syzkaller/prog"
+)
+
+// distill re-executes all programs from the corpus, collects their signal
+// and writes the minimal subset of programs that preserves the whole signal.
+// Each program is executed runs times and only signal it gives on every run is accounted,
+// so that flaky signal does not keep programs in the corpus (like triage in syz-fuzzer does).
+// Must be run on the target machine (like syz-execprog).
+func distill(target *prog.Target, file, outFile, reportFile string, version uint64, runs int) {
+	progs, err := db.ReadCorpus(file, target)
+	if err != nil {
+		tool.Fail(err)
+	}
+	config, execOpts, err := ipcconfig.Default(target)
+	if err != nil {
+		tool.Failf("failed to create ipc config: %v", err)
+	}
+	config.Flags |= ipc.FlagSignal
+	execOpts.Flags |= ipc.FlagCollectSignal
+	env, err := ipc.MakeEnv(config, 0)
+	if err != nil {
+		tool.Failf("failed to create ipc env: %v", err)
+	}
+	defer env.Close()
+	start := time.Now()
+	corpus := make([]signal.Context, len(progs))
+	flaky := 0
+	for i, p := range progs {
+		sign, flakySignal := stableSignal(runs, func() signal.Signal {
+			return execSignal(env, execOpts, p)
+		})
+		corpus[i] = signal.Context{
+			Signal:  sign,
+			Context: i,
+		}
+		flaky += flakySignal
+		if (i+1)%1000 == 0 {
+			fmt.Fprintf(os.Stderr, "executed %v/%v programs (%v)\n", i+1, len(progs), time.Since(start))
+		}
+	}
+	res := distillCorpus(corpus)
+	res.flakySignal = flaky
+	var records []db.Record
+	for _, idx := range res.kept {
+		records = append(records, db.Record{Val: progs[idx].Serialize()})
+	}
+	if err := db.Create(outFile, version, records); err != nil {
+		tool.Fail(err)
+	}
+	out := io.Writer(os.Stdout)
+	if reportFile != "" {
+		f, err := os.Create(reportFile)
+		if err != nil {
+			tool.Fail(err)
+		}
+		defer f.Close()
+		out = f
+	}
+	res.print(out, progs)
+}
+
+type distillResult struct {
+	kept        []int // indices of programs in the distilled corpus
+	dropped     []int // indices of dropped programs
+	noSignal    int   // number of dropped programs that did not give any signal
+	totalSignal int   // signal of the whole corpus, all of it is retained
+	flakySignal int   // signal that programs did not give on every run, it's not accounted
+}
+
+// stableSignal executes a program runs times (with exec) and returns the signal given on every run,
+// and the number of signal elements that were not given on every run.
+func stableSignal(runs int, exec func() signal.Signal) (signal.Signal, int) {
+	var stable, all signal.Signal
+	for i := 0; i < runs; i++ {
+		sign := exec()
+		if i == 0 {
+			stable = sign.Copy()
+		} else {
+			stable = stable.Intersection(sign)
+		}
+		all.Merge(sign)
+	}
+	return stable, all.Len() - stable.Len()
+}
+
+func distillCorpus(corpus []signal.Context) *distillResult {
+	res := new(distillResult)
+	var total signal.Signal
+	for _, ctx := range corpus {
+		total.Merge(ctx.Signal)
+	}
+	res.totalSignal = total.Len()
+	kept := make(map[int]bool)
+	for _, ctx := range signal.Minimize(corpus) {
+		kept[ctx.(int)] = true
+	}
+	for i, ctx := range corpus {
+		if kept[i] {
+			res.kept = append(res.kept, i)
+			continue
+		}
+		res.dropped = append(res.dropped, i)
+		if ctx.Signal.Empty() {
+			res.noSignal++
+		}
+	}
+	return res
+}
+
+func (res *distillResult) print(w io.Writer, progs []*prog.Prog) {
+	fmt.Fprintf(w, "programs: %v -> %v (dropped %v, %v without signal)\n",
+		len(progs), len(res.kept), len(res.dropped), res.noSignal)
+	fmt.Fprintf(w, "signal: %v (retained fully), flaky signal: %v (not accounted)\n",
+		res.totalSignal, res.flakySignal)
+	fmt.Fprintf(w, "dropped programs:\n")
+	for _, idx := range res.dropped {
+		p := progs[idx]
+		fmt.Fprintf(w, "%v %v\n", hash.String(p.Serialize()), p)
+	}
+}
+
+func execSignal(env *ipc.Env, opts *ipc.ExecOpts, p *prog.Prog) signal.Signal {
+	var sign signal.Signal
+	for try := 0; ; try++ {
+		_, info, _, err := env.Exec(opts, p)
+		if err != nil {
+			if err == prog.ErrExecBufferTooSmall || try > 10 {
+				fmt.Fprintf(os.Stderr, "failed to execute program: %v\n", err)
+				return nil
+			}
+			time.Sleep(time.Second)
+			continue
+		}
+		for i, inf := range info.Calls {
+			// Same prioritization as in syz-fuzzer: successful calls and calls without ANY
+			// are more valuable, so prefer programs that give the signal in such calls.
+			prio := uint8(0)
+			if inf.Errno == 0 {
+				prio |= 1 << 1
+			}
+			if !p.Target.CallContainsAny(p.Calls[i]) {
+				prio |= 1 << 0
+			}
+			sign.Merge(signal.FromRaw(inf.Signal, prio))
+		}
+		sign.Merge(signal.FromRaw(info.Extra.Signal, 0))
+		return sign
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/syzkaller/pkg/signal"
+)
+
+func TestDistillCorpus(t *testing.T) {
+	corpus := []signal.Context{
+		{Signal: signal.FromRaw([]uint32{1, 2, 3}, 0), Context: 0},
+		// Subset of the first program.
+		{Signal: signal.FromRaw([]uint32{1, 2}, 0), Context: 1},
+		{Signal: nil, Context: 2},
+		{Signal: signal.FromRaw([]uint32{3, 4}, 0), Context: 3},
+		// Same signal with higher priority.
+		{Signal: signal.FromRaw([]uint32{5}, 0), Context: 4},
+		{Signal: signal.FromRaw([]uint32{5}, 3), Context: 5},
+	}
+	res := distillCorpus(corpus)
+	want := &distillResult{
+		kept:        []int{0, 3, 5},
+		dropped:     []int{1, 2, 4},
+		noSignal:    1,
+		totalSignal: 5,
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Fatalf("got %+v\nwant %+v", res, want)
+	}
+}
+
+func TestStableSignal(t *testing.T) {
+	runs := [][]uint32{
+		{1, 2, 3, 4},
+		{1, 2, 3, 5},
+		{1, 3, 4},
+	}
+	run := 0
+	sign, flaky := stableSignal(len(runs), func() signal.Signal {
+		res := signal.FromRaw(runs[run], 0)
+		run++
+		return res
+	})
+	if want := signal.FromRaw([]uint32{1, 3}, 0); !reflect.DeepEqual(sign, want) {
+		t.Errorf("got signal %v, want %v", sign, want)
+	}
+	if flaky != 3 {
+		t.Errorf("got %v flaky signal, want 3", flaky)
+	}
+	if run != len(runs) {
+		t.Errorf("program executed %v times, want %v", run, len(runs))
+	}
+}
@@ -26,6 +26,8 @@ func main() {
 		flagVersion = flag.Uint64("version", 0, "database version")
 		flagOS      = flag.String("os", "", "target OS")
 		flagArch    = flag.String("arch", "", "target arch")
+		flagReport  = flag.String("report", "", "write distill report to the file instead of stdout")
+		flagRuns    = flag.Int("runs", 3, "execute each program this many times during distill to filter flaky signal")
 	)
 	flag.Parse()
 	args := flag.Args()
@@ -43,6 +45,17 @@ func main() {
 		bench(target, args[1])
 		return
 	}
+	if args[0] == "distill" {
+		if len(args) != 3 || *flagRuns < 1 {
+			usage()
+		}
+		target, err := prog.GetTarget(*flagOS, *flagArch)
+		if err != nil {
+			tool.Failf("failed to find target: %v", err)
+		}
+		distill(target, args[1], args[2], *flagReport, *flagVersion, *flagRuns)
+		return
+	}
 	var target *prog.Target
 	if *flagOS != "" || *flagArch != "" {
 		var err error
@@ -77,7 +90,8 @@ func usage() {
 	fmt.Fprintf(os.Stderr, "  syz-db pack dir corpus.db\n")
 	fmt.Fprintf(os.Stderr, "  syz-db unpack corpus.db dir\n")
 	fmt.Fprintf(os.Stderr, "  syz-db merge dst-corpus.db add-corpus.db* add-prog*\n")
-	fmt.Fprintf(os.Stderr, "  syz-db bench corpus.db\n")
+	fmt.Fprintf(os.Stderr, "  syz-db -os=OS -arch=ARCH bench corpus.db\n")
+	fmt.Fprintf(os.Stderr, "  syz-db -os=OS -arch=ARCH [-report file] [-runs N] distill corpus.db distilled.db\n")
 	os.Exit(1)
 }
 
@@ -30,13 +30,14 @@ import (
 var (
 	flagOS        = flag.String("os", runtime.GOOS, "target os")
 	flagArch      = flag.String("arch", runtime.GOARCH, "target arch")
-	flagCoverFile = flag.String("coverfile", "", "write coverage to the file")
+	flagCoverFile = flag.String("coverfile", "", "write coverage to the file (with .progN suffix for several programs)")
 	flagRepeat    = flag.Int("repeat", 1, "repeat execution that many times (0 for infinite loop)")
 	flagProcs     = flag.Int("procs", 2*runtime.NumCPU(), "number of parallel processes to execute programs")
 	flagOutput    = flag.Bool("output", false, "write programs and results to stdout")
 	flagHints     = flag.Bool("hints", false, "do a hints-generation run")
 	flagEnable    = flag.String("enable", "none", "enable only listed additional features")
 	flagDisable   = flag.String("disable", "none", "enable all additional features except listed")
+	flagTrace     = flag.String("trace", "", "write JSON trace of each program execution to the file (one per line)")
 	// The following flag is only kept to let syzkaller remain compatible with older execprog versions.
 	// In order to test incoming patches or perform bug bisection, syz-ci must use the exact syzkaller
 	// version that detected the bug (as descriptions and syntax could've already been changed), and
@@ -110,6 +111,13 @@ func main() {
 		shutdown: make(chan struct{}),
 		repeat:   *flagRepeat,
 	}
+	if *flagTrace != "" {
+		ctx.traceFile, err = os.Create(*flagTrace)
+		if err != nil {
+			log.Fatalf("failed to create trace file: %v", err)
+		}
+		defer ctx.traceFile.Close()
+	}
 	var wg sync.WaitGroup
 	wg.Add(*flagProcs)
 	for p := 0; p < *flagProcs; p++ {
@@ -134,6 +142,8 @@ type Context struct {
 	repeat    int
 	pos       int
 	lastPrint time.Time
+	traceMu   sync.Mutex
+	traceFile *os.File
 }
 
 func (ctx *Context) run(pid int) {
@@ -153,11 +163,11 @@ func (ctx *Context) run(pid int) {
 			return
 		}
 		entry := ctx.progs[idx%len(ctx.progs)]
-		ctx.execute(pid, env, entry)
+		ctx.execute(pid, env, entry, idx%len(ctx.progs))
 	}
 }
 
-func (ctx *Context) execute(pid int, env *ipc.Env, p *prog.Prog) {
+func (ctx *Context) execute(pid int, env *ipc.Env, p *prog.Prog, progIndex int) {
 	// Limit concurrency window.
 	ticket := ctx.gate.Enter()
 	defer ctx.gate.Leave(ticket)
@@ -168,7 +178,9 @@ func (ctx *Context) execute(pid int, env *ipc.Env, p *prog.Prog) {
 	}
 	// This mimics the syz-fuzzer logic. This is important for reproduction.
 	for try := 0; ; try++ {
+		start := time.Now()
 		output, info, hanged, err := env.Exec(callOpts, p)
+		duration := time.Since(start)
 		if err != nil && err != prog.ErrExecBufferTooSmall {
 			if try > 10 {
 				log.Fatalf("executor failed %v times: %v\n%s", try, err, output)
@@ -181,13 +193,21 @@ func (ctx *Context) execute(pid int, env *ipc.Env, p *prog.Prog) {
 		if ctx.config.Flags&ipc.FlagDebug != 0 || err != nil {
 			log.Logf(0, "result: hanged=%v err=%v\n\n%s", hanged, err, output)
 		}
+		if ctx.traceFile != nil {
+			ctx.writeTrace(ipc.MakeProgTrace(p, info, duration, hanged))
+		}
 		if info != nil {
 			ctx.printCallResults(info)
 			if *flagHints {
 				ctx.printHints(p, info)
 			}
 			if *flagCoverFile != "" {
-				ctx.dumpCoverage(*flagCoverFile, info)
+				coverFile := *flagCoverFile
+				if len(ctx.progs) > 1 {
+					// Don't overwrite coverage of other programs.
+					coverFile = fmt.Sprintf("%v.prog%v", coverFile, progIndex)
+				}
+				ctx.dumpCoverage(coverFile, info)
 			}
 		} else {
 			log.Logf(1, "RESULT: no calls executed")
@@ -203,6 +223,14 @@ func (ctx *Context) logProgram(pid int, p *prog.Prog, callOpts *ipc.ExecOpts) {
 	ctx.logMu.Unlock()
 }
 
+func (ctx *Context) writeTrace(trace *ipc.ProgTrace) {
+	ctx.traceMu.Lock()
+	defer ctx.traceMu.Unlock()
+	if err := ipc.WriteProgTrace(ctx.traceFile, trace); err != nil {
+		log.Fatalf("failed to write trace: %v", err)
+	}
+}
+
 func (ctx *Context) printCallResults(info *ipc.ProgInfo) {
 	for i, inf := range info.Calls {
 		if inf.Flags&ipc.CallExecuted == 0 {
@@ -5,6 +5,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"flag"
 	"log"
 	"net/http"
@@ -32,6 +33,9 @@ func main() {
 		flagCorpus     = flag.String("corpus", "", "coprpus file to upload")
 		flagWorkdir    = flag.String("workdir", "", "workdir to upload coprpus and reproducers")
 		flagDrain      = flag.Bool("drain", false, "drain hub corpus and reproducers for the given manager")
+		flagTLSCert    = flag.String("tls_cert", "", "client certificate for TLS connection to hub")
+		flagTLSKey     = flag.String("tls_key", "", "client private key for TLS connection to hub")
+		flagTLSCA      = flag.String("tls_ca", "", "CA certificate to verify hub for TLS connection")
 	)
 	flag.Parse()
 	target, err := prog.GetTarget(*flagOS, *flagArch)
@@ -54,7 +58,13 @@ func main() {
 		return
 	}
 	log.Printf("connecting to hub at %v...", *flagHubAddress)
-	conn, err := rpctype.NewRPCClient(*flagHubAddress, 1)
+	var tlsCfg *tls.Config
+	if *flagTLSCert != "" || *flagTLSKey != "" || *flagTLSCA != "" {
+		if tlsCfg, err = rpctype.ClientTLSConfig(*flagTLSCert, *flagTLSKey, *flagTLSCA); err != nil {
+			log.Fatal(err)
+		}
+	}
+	conn, err := rpctype.NewRPCClient(*flagHubAddress, 1, tlsCfg)
 	if err != nil {
 		log.Fatalf("failed to connect to hub: %v", err)
 	}
@@ -0,0 +1,370 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+
+	"github.com/google/syzkaller/pkg/ast"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+type server struct {
+	conn *conn
+	// Target for descriptions in dirs that are not named after an OS.
+	defaultOS string
+	arch      string
+	// Workspaces keyed by descriptions dir.
+	workspaces map[string]*workspace
+	// Files that had non-empty diagnostics published last time.
+	published map[string]bool
+	shutdown  bool
+}
+
+func newServer(r io.Reader, w io.Writer, defaultOS, arch string) *server {
+	return &server{
+		conn:       newConn(r, w),
+		defaultOS:  defaultOS,
+		arch:       arch,
+		workspaces: make(map[string]*workspace),
+		published:  make(map[string]bool),
+	}
+}
+
+// serve handles requests until the client sends the exit notification or closes the connection.
+func (srv *server) serve() error {
+	for {
+		req, err := srv.conn.read()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			if respErr, ok := err.(*responseError); ok {
+				if err := srv.conn.reply(json.RawMessage("null"), nil, respErr); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+		if req.Method == "exit" {
+			if !srv.shutdown {
+				return fmt.Errorf("exit without shutdown")
+			}
+			return nil
+		}
+		res, err := srv.handle(req)
+		if req.ID == nil {
+			// Notifications don't have responses.
+			if err != nil {
+				typ := messageError
+				if rerr, ok := err.(*responseError); ok && rerr.Code == errMethodNotFound {
+					// Clients send notifications we don't care about (e.g. $/cancelRequest).
+					typ = messageLog
+				}
+				srv.logf(typ, "%v: %v", req.Method, err)
+			}
+			continue
+		}
+		if err := srv.conn.reply(req.ID, res, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (srv *server) handle(req *message) (interface{}, error) {
+	switch req.Method {
+	case "initialize":
+		return &InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:           textDocumentSyncFull,
+				HoverProvider:              true,
+				DefinitionProvider:         true,
+				ReferencesProvider:         true,
+				DocumentFormattingProvider: true,
+			},
+			ServerInfo: ServerInfo{Name: "syz-lsp"},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		srv.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		params := new(DidOpenTextDocumentParams)
+		if err := unmarshalParams(req, params); err != nil {
+			return nil, err
+		}
+		return nil, srv.didOpen(params.TextDocument.URI, []byte(params.TextDocument.Text))
+	case "textDocument/didChange":
+		params := new(DidChangeTextDocumentParams)
+		if err := unmarshalParams(req, params); err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		return nil, srv.didChange(params.TextDocument.URI, []byte(text))
+	case "textDocument/didSave":
+		params := new(DidSaveTextDocumentParams)
+		if err := unmarshalParams(req, params); err != nil {
+			return nil, err
+		}
+		return nil, srv.didSave(params.TextDocument.URI)
+	case "textDocument/didClose":
+		return nil, nil
+	case "textDocument/hover":
+		params := new(TextDocumentPositionParams)
+		if err := unmarshalParams(req, params); err != nil {
+			return nil, err
+		}
+		return srv.hover(params)
+	case "textDocument/definition":
+		params := new(TextDocumentPositionParams)
+		if err := unmarshalParams(req, params); err != nil {
+			return nil, err
+		}
+		return srv.definition(params)
+	case "textDocument/references":
+		params := new(ReferenceParams)
+		if err := unmarshalParams(req, params); err != nil {
+			return nil, err
+		}
+		return srv.references(params)
+	case "textDocument/formatting":
+		params := new(DocumentFormattingParams)
+		if err := unmarshalParams(req, params); err != nil {
+			return nil, err
+		}
+		return srv.formatting(params)
+	default:
+		return nil, &responseError{errMethodNotFound, fmt.Sprintf("unsupported method %v", req.Method)}
+	}
+}
+
+func unmarshalParams(req *message, params interface{}) error {
+	if err := json.Unmarshal(req.Params, params); err != nil {
+		return &responseError{errInvalidParams, err.Error()}
+	}
+	return nil
+}
+
+func (srv *server) didOpen(uri string, data []byte) error {
+	file, err := uriToPath(uri)
+	if err != nil {
+		return err
+	}
+	ws, created, err := srv.workspace(file)
+	if err != nil {
+		return err
+	}
+	if f := ws.files[file]; created || f == nil || !bytes.Equal(f.data, data) {
+		ws.update(file, data)
+		ws.compile()
+	}
+	return srv.publish(ws)
+}
+
+func (srv *server) didChange(uri string, data []byte) error {
+	file, err := uriToPath(uri)
+	if err != nil {
+		return err
+	}
+	ws, _, err := srv.workspace(file)
+	if err != nil {
+		return err
+	}
+	// Compilation of all descriptions is too slow to do on every key press,
+	// so only syntax errors are updated until the file is saved.
+	ws.update(file, data)
+	return srv.publish(ws)
+}
+
+func (srv *server) didSave(uri string) error {
+	file, err := uriToPath(uri)
+	if err != nil {
+		return err
+	}
+	ws, _, err := srv.workspace(file)
+	if err != nil {
+		return err
+	}
+	ws.compile()
+	return srv.publish(ws)
+}
+
+func (srv *server) hover(params *TextDocumentPositionParams) (interface{}, error) {
+	ws, name, pos, err := srv.identAt(params)
+	if err != nil || name == "" {
+		return nil, err
+	}
+	start := posToLSP(pos)
+	end := start
+	end.Character += len(name)
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: ws.hover(name)},
+		Range:    &Range{start, end},
+	}, nil
+}
+
+func (srv *server) definition(params *TextDocumentPositionParams) (interface{}, error) {
+	ws, name, _, err := srv.identAt(params)
+	if err != nil || name == "" {
+		return nil, err
+	}
+	pos, ok := ws.definition(name)
+	if !ok {
+		return nil, nil
+	}
+	return makeLocation(pos, name), nil
+}
+
+func (srv *server) references(params *ReferenceParams) (interface{}, error) {
+	ws, name, _, err := srv.identAt(&params.TextDocumentPositionParams)
+	if err != nil || name == "" {
+		return nil, err
+	}
+	locs := []Location{}
+	for _, pos := range ws.references(name, params.Context.IncludeDeclaration) {
+		locs = append(locs, makeLocation(pos, name))
+	}
+	return locs, nil
+}
+
+func (srv *server) formatting(params *DocumentFormattingParams) (interface{}, error) {
+	file, err := uriToPath(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	ws, _, err := srv.workspace(file)
+	if err != nil {
+		return nil, err
+	}
+	f := ws.files[file]
+	if f == nil || f.desc == nil {
+		// Can't format files with syntax errors.
+		return nil, nil
+	}
+	formatted := ast.Format(f.desc)
+	if bytes.Equal(formatted, f.data) {
+		return []TextEdit{}, nil
+	}
+	end := Position{Line: bytes.Count(f.data, []byte{'\n'}) + 1}
+	return []TextEdit{{
+		Range:   Range{Position{}, end},
+		NewText: string(formatted),
+	}}, nil
+}
+
+func (srv *server) identAt(params *TextDocumentPositionParams) (*workspace, string, ast.Pos, error) {
+	file, err := uriToPath(params.TextDocument.URI)
+	if err != nil {
+		return nil, "", ast.Pos{}, err
+	}
+	ws, _, err := srv.workspace(file)
+	if err != nil {
+		return nil, "", ast.Pos{}, err
+	}
+	name, pos, _ := ws.identAt(file, params.Position.Line+1, params.Position.Character+1)
+	return ws, name, pos, nil
+}
+
+// workspace returns the workspace for the descriptions dir of the file.
+// The OS is deduced from the dir name (e.g. sys/linux).
+func (srv *server) workspace(file string) (*workspace, bool, error) {
+	dir := filepath.Dir(file)
+	if ws := srv.workspaces[dir]; ws != nil {
+		return ws, false, nil
+	}
+	target, err := srv.target(filepath.Base(dir))
+	if err != nil {
+		return nil, false, err
+	}
+	ws, err := newWorkspace(dir, target, srv.logf)
+	if err != nil {
+		return nil, false, err
+	}
+	srv.workspaces[dir] = ws
+	return ws, true, nil
+}
+
+func (srv *server) target(OS string) (*targets.Target, error) {
+	if targets.List[OS] == nil {
+		OS = srv.defaultOS
+	}
+	archs := targets.List[OS]
+	if archs == nil {
+		return nil, fmt.Errorf("unknown OS %v", OS)
+	}
+	if target := archs[srv.arch]; target != nil {
+		return target, nil
+	}
+	var names []string
+	for arch := range archs {
+		names = append(names, arch)
+	}
+	sort.Strings(names)
+	return archs[names[0]], nil
+}
+
+// publish sends diagnostics for all files of the workspace.
+// Files without diagnostics are skipped, unless they had diagnostics before.
+func (srv *server) publish(ws *workspace) error {
+	for _, file := range ws.sortedFiles() {
+		diags := ws.diagnostics(file)
+		if len(diags) == 0 && !srv.published[file] {
+			continue
+		}
+		srv.published[file] = len(diags) != 0
+		err := srv.conn.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+			URI:         pathToURI(file),
+			Diagnostics: diags,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logf sends a message of the given type (messageError, messageInfo, etc) to the client.
+func (srv *server) logf(typ int, msg string, args ...interface{}) {
+	srv.conn.notify("window/logMessage", &LogMessageParams{
+		Type:    typ,
+		Message: fmt.Sprintf(msg, args...),
+	})
+}
+
+func makeLocation(pos ast.Pos, name string) Location {
+	start := posToLSP(pos)
+	end := start
+	end.Character += len(name)
+	return Location{
+		URI:   pathToURI(pos.File),
+		Range: Range{start, end},
+	}
+}
+
+func uriToPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", &responseError{errInvalidParams, fmt.Sprintf("bad uri %q: %v", uri, err)}
+	}
+	if u.Scheme != "file" {
+		return "", &responseError{errInvalidParams, fmt.Sprintf("unsupported uri %q", uri)}
+	}
+	return filepath.Clean(filepath.FromSlash(u.Path)), nil
+}
+
+func pathToURI(file string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(file)}
+	return u.String()
+}
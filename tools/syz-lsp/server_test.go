@@ -0,0 +1,299 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/syzkaller/sys/targets"
+)
+
+const testDescriptions = `resource fd_lsp[int32]: -1
+
+test$lsp_open(a ptr[in, lsp_struct]) fd_lsp
+test$lsp_use(fd fd_lsp, a ptr[in, lsp_tmpl[int16]], b flags[lsp_flags])
+
+lsp_struct {
+	f1	int32
+	f2	int64
+	f3	fd_lsp
+}
+
+type lsp_tmpl[T] {
+	f	T
+	g	int8
+}
+
+lsp_flags = 1, 2, 4
+`
+
+type testClient struct {
+	t      *testing.T
+	conn   *conn
+	id     int
+	msgs   chan *message
+	notifs []*message
+}
+
+func startServer(t *testing.T) *testClient {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	srv := newServer(serverIn, serverOut, targets.Linux, targets.TestArch64)
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.serve()
+		serverOut.Close()
+	}()
+	c := &testClient{
+		t:    t,
+		conn: newConn(clientIn, clientOut),
+		msgs: make(chan *message, 1000),
+	}
+	// io.Pipe is synchronous, so read messages in the background
+	// to not deadlock with the server writing notifications.
+	go func() {
+		for {
+			msg, err := c.conn.read()
+			if err != nil {
+				close(c.msgs)
+				return
+			}
+			c.msgs <- msg
+		}
+	}()
+	t.Cleanup(func() {
+		c.call("shutdown", nil, nil)
+		if err := c.conn.write(&notification{JSONRPC: "2.0", Method: "exit"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	})
+	c.call("initialize", map[string]interface{}{}, nil)
+	return c
+}
+
+func (c *testClient) call(method string, params, result interface{}) {
+	c.t.Helper()
+	c.id++
+	id := json.RawMessage(fmt.Sprint(c.id))
+	c.send(id, method, params)
+	for {
+		msg := <-c.msgs
+		if msg == nil {
+			c.t.Fatalf("connection closed")
+		}
+		if msg.Method != "" {
+			c.notifs = append(c.notifs, msg)
+			continue
+		}
+		if string(msg.ID) != string(id) {
+			c.t.Fatalf("unexpected response id %s, want %s", msg.ID, id)
+		}
+		if msg.Error != nil {
+			c.t.Fatalf("%v failed: %v", method, msg.Error)
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return
+	}
+}
+
+// notify sends a notification and waits until it's processed.
+func (c *testClient) notify(method string, params interface{}) {
+	c.t.Helper()
+	c.send(nil, method, params)
+	// Requests are handled sequentially, so a response means the notification was handled.
+	c.call("textDocument/hover", &TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///nonexistent/file.txt"},
+	}, nil)
+}
+
+func (c *testClient) send(id json.RawMessage, method string, params interface{}) {
+	c.t.Helper()
+	data, err := json.Marshal(params)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := c.conn.write(&message{JSONRPC: "2.0", ID: id, Method: method, Params: data}); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// diagnostics returns the last published diagnostics for the uri.
+func (c *testClient) diagnostics(uri string) []Diagnostic {
+	c.t.Helper()
+	var res []Diagnostic
+	for _, msg := range c.notifs {
+		if msg.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		params := new(PublishDiagnosticsParams)
+		if err := json.Unmarshal(msg.Params, params); err != nil {
+			c.t.Fatal(err)
+		}
+		if params.URI == uri {
+			res = params.Diagnostics
+		}
+	}
+	return res
+}
+
+// logMessages returns all window/logMessage messages received so far.
+func (c *testClient) logMessages() []LogMessageParams {
+	c.t.Helper()
+	var res []LogMessageParams
+	for _, msg := range c.notifs {
+		if msg.Method != "window/logMessage" {
+			continue
+		}
+		var params LogMessageParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			c.t.Fatal(err)
+		}
+		res = append(res, params)
+	}
+	return res
+}
+
+func position(uri, text, ident string, n int) TextDocumentPositionParams {
+	off := -1
+	for i := 0; i <= n; i++ {
+		off += strings.Index(text[off+1:], ident) + 1
+	}
+	line := strings.Count(text[:off], "\n")
+	col := off - strings.LastIndexByte(text[:off], '\n') - 1
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: col},
+	}
+}
+
+func TestServer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), targets.TestOS)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "lsp.txt")
+	if err := os.WriteFile(file, []byte(testDescriptions), 0644); err != nil {
+		t.Fatal(err)
+	}
+	uri := pathToURI(file)
+	c := startServer(t)
+	c.notify("textDocument/didOpen", &DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, Text: testDescriptions},
+	})
+	if diags := c.diagnostics(uri); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %+v", diags)
+	}
+
+	// Definition of the struct from its use in the syscall.
+	var loc Location
+	pos := position(uri, testDescriptions, "lsp_struct", 0)
+	c.call("textDocument/definition", pos, &loc)
+	want := Location{URI: uri, Range: Range{Position{5, 0}, Position{5, 10}}}
+	if loc != want {
+		t.Fatalf("got definition %+v, want %+v", loc, want)
+	}
+
+	// References to the resource.
+	var locs []Location
+	refs := &ReferenceParams{TextDocumentPositionParams: position(uri, testDescriptions, "fd_lsp", 0)}
+	refs.Context.IncludeDeclaration = true
+	c.call("textDocument/references", refs, &locs)
+	var lines []int
+	for _, loc := range locs {
+		lines = append(lines, loc.Range.Start.Line)
+	}
+	if fmt.Sprint(lines) != "[0 2 3 8]" {
+		t.Fatalf("got references on lines %v", lines)
+	}
+
+	// Hover shows sizes of the compiled types.
+	var hover Hover
+	c.call("textDocument/hover", position(uri, testDescriptions, "lsp_struct", 1), &hover)
+	if !strings.Contains(hover.Contents.Value, "`lsp_struct`: size 24, align 8") {
+		t.Fatalf("bad struct hover:\n%v", hover.Contents.Value)
+	}
+	c.call("textDocument/hover", position(uri, testDescriptions, "lsp_tmpl", 0), &hover)
+	if !strings.Contains(hover.Contents.Value, "`lsp_tmpl[int16]`: size 4, align 2") {
+		t.Fatalf("bad template hover:\n%v", hover.Contents.Value)
+	}
+
+	// Syntax errors are reported on change, semantic errors on save.
+	broken := strings.Replace(testDescriptions, "lsp_flags = 1, 2, 4", "lsp_flags = 1, 2,", 1)
+	c.notify("textDocument/didChange", &DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: broken}},
+	})
+	if diags := c.diagnostics(uri); len(diags) != 1 || diags[0].Range.Start.Line != 16 ||
+		diags[0].Severity != severityError {
+		t.Fatalf("bad syntax error diagnostics: %+v", diags)
+	}
+	broken = strings.Replace(testDescriptions, "f3	fd_lsp", "f3	fd_lsp_typo", 1)
+	c.notify("textDocument/didChange", &DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: broken}},
+	})
+	if diags := c.diagnostics(uri); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics before save: %+v", diags)
+	}
+	c.notify("textDocument/didSave", &DidSaveTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}})
+	diags := c.diagnostics(uri)
+	if len(diags) != 1 || diags[0].Range != (Range{Position{8, 4}, Position{8, 15}}) ||
+		!strings.Contains(diags[0].Message, "fd_lsp_typo") {
+		t.Fatalf("bad compiler diagnostics: %+v", diags)
+	}
+
+	// Formatting.
+	unformatted := strings.Replace(testDescriptions, "lsp_flags = 1, 2, 4", "lsp_flags   =  1,2,  4", 1)
+	c.notify("textDocument/didChange", &DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: unformatted}},
+	})
+	var edits []TextEdit
+	c.call("textDocument/formatting", &DocumentFormattingParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &edits)
+	if len(edits) != 1 || edits[0].NewText != testDescriptions {
+		t.Fatalf("bad formatting: %+v", edits)
+	}
+}
+
+func TestLogMessages(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), targets.Linux)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "lsp.txt")
+	if err := os.WriteFile(file, []byte(testDescriptions), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := startServer(t)
+	// Unsupported notifications are routine, a missing const file is not a failure.
+	c.notify("$/cancelRequest", map[string]interface{}{"id": 1})
+	c.notify("textDocument/didOpen", &DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: pathToURI(file), Text: testDescriptions},
+	})
+	// Malformed notifications are real failures.
+	c.notify("textDocument/didSave", "garbage")
+	msgs := c.logMessages()
+	want := []int{messageLog, messageInfo, messageError}
+	if len(msgs) != len(want) {
+		t.Fatalf("got messages %+v, want types %v", msgs, want)
+	}
+	for i, msg := range msgs {
+		if msg.Type != want[i] {
+			t.Errorf("message %q has type %v, want %v", msg.Message, msg.Type, want[i])
+		}
+	}
+}
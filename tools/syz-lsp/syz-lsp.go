@@ -0,0 +1,39 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// syz-lsp is a Language Server Protocol server for syzlang descriptions (sys/*/*.txt).
+// It speaks LSP over stdin/stdout and provides:
+//   - diagnostics: syntax errors on every change, compiler errors and warnings on open/save
+//     (descriptions are compiled with consts from *.const files for a single arch);
+//   - hover with the declaration and sizes of the compiled types;
+//   - go-to-definition and references for resources, structs, unions, typedefs, templates and flags;
+//   - formatting with the same rules as syz-fmt.
+//
+// All *.txt files in the dir of an opened file are analyzed together,
+// the target OS is deduced from the dir name (e.g. sys/linux).
+//
+// Usage with an editor: configure syz-lsp as the language server for *.txt files in sys/, e.g. for Neovim:
+//
+//	vim.lsp.start({name = "syz-lsp", cmd = {"syz-lsp"}, root_dir = vim.fs.dirname(vim.api.nvim_buf_get_name(0))})
+package main
+
+import (
+	"flag"
+	"os"
+	"runtime"
+
+	"github.com/google/syzkaller/pkg/tool"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func main() {
+	var (
+		flagOS   = flag.String("os", targets.Linux, "target OS for descriptions outside of sys/OS dirs")
+		flagArch = flag.String("arch", runtime.GOARCH, "target arch to compile descriptions for")
+	)
+	defer tool.Init()()
+	srv := newServer(os.Stdin, os.Stdout, *flagOS, *flagArch)
+	if err := srv.serve(); err != nil {
+		tool.Fail(err)
+	}
+}
@@ -0,0 +1,314 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/syzkaller/pkg/ast"
+	"github.com/google/syzkaller/pkg/compiler"
+	"github.com/google/syzkaller/prog"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+// workspace holds descriptions of a single OS (all *.txt files in a sys/OS dir).
+// Descriptions of an OS can only be compiled together, so a change in one file
+// can affect diagnostics in all other files of the OS.
+type workspace struct {
+	dir    string
+	target *targets.Target
+	files  map[string]*sourceFile // keyed by file path
+	// Named top-level declarations (resources, structs, unions, typedefs, templates, flags)
+	// and references to them.
+	decls map[string]*decl
+	refs  map[string][]ast.Pos
+	// Compiled struct, union and resource types keyed by name (template name for templates).
+	// Empty if the last compilation failed.
+	types        map[string][]prog.Type
+	compileDiags map[string][]Diagnostic // keyed by file path
+	// logf reports problems that can't be attributed to any of the files (typ is messageError, etc).
+	logf func(typ int, msg string, args ...interface{})
+}
+
+type sourceFile struct {
+	data       []byte
+	desc       *ast.Description // nil if the file has syntax errors
+	parseDiags []Diagnostic
+}
+
+type decl struct {
+	name *ast.Ident
+	node ast.Node
+}
+
+func newWorkspace(dir string, target *targets.Target, logf func(typ int, msg string, args ...interface{})) (
+	*workspace, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	ws := &workspace{
+		dir:    dir,
+		target: target,
+		files:  make(map[string]*sourceFile),
+		logf:   logf,
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		ws.parse(file, data)
+	}
+	ws.index()
+	return ws, nil
+}
+
+// update re-parses a single file after it was changed in the editor.
+// Compilation results for the file become stale and are dropped until the next compile.
+func (ws *workspace) update(file string, data []byte) {
+	ws.parse(file, data)
+	delete(ws.compileDiags, file)
+	ws.index()
+}
+
+func (ws *workspace) parse(file string, data []byte) {
+	f := &sourceFile{data: data}
+	f.desc = ast.Parse(data, file, func(pos ast.Pos, msg string) {
+		f.parseDiags = append(f.parseDiags, makeDiagnostic(data, pos, msg, severityError))
+	})
+	ws.files[file] = f
+}
+
+func (ws *workspace) sortedFiles() []string {
+	var files []string
+	for file := range ws.files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func (ws *workspace) index() {
+	ws.decls = make(map[string]*decl)
+	ws.refs = make(map[string][]ast.Pos)
+	for _, file := range ws.sortedFiles() {
+		desc := ws.files[file].desc
+		if desc == nil {
+			continue
+		}
+		for _, n := range desc.Nodes {
+			var name *ast.Ident
+			switch n := n.(type) {
+			case *ast.Resource:
+				name = n.Name
+			case *ast.Struct:
+				name = n.Name
+			case *ast.TypeDef:
+				name = n.Name
+			case *ast.IntFlags:
+				name = n.Name
+			case *ast.StrFlags:
+				name = n.Name
+			}
+			if name != nil && ws.decls[name.Name] == nil {
+				ws.decls[name.Name] = &decl{name, n}
+			}
+		}
+		desc.Walk(ast.Recursive(func(n ast.Node) {
+			if t, ok := n.(*ast.Type); ok && t.Ident != "" {
+				ws.refs[t.Ident] = append(ws.refs[t.Ident], t.Pos)
+			}
+		}))
+	}
+}
+
+// compile compiles all descriptions and updates compiler diagnostics.
+// If compilation succeeds, all reported messages are warnings.
+func (ws *workspace) compile() {
+	ws.compileDiags = make(map[string][]Diagnostic)
+	ws.types = make(map[string][]prog.Type)
+	desc := &ast.Description{}
+	for _, file := range ws.sortedFiles() {
+		f := ws.files[file]
+		if f.desc == nil {
+			// Syntax errors are already reported, and there is no point in compiling the rest.
+			return
+		}
+		desc.Nodes = append(desc.Nodes, f.desc.Nodes...)
+	}
+	type message struct {
+		pos ast.Pos
+		msg string
+	}
+	var messages []message
+	seen := make(map[message]bool)
+	eh := func(pos ast.Pos, msg string) {
+		// For the test OS errors are reported twice: by ExtractConsts and by Compile.
+		m := message{pos, msg}
+		if !seen[m] {
+			seen[m] = true
+			messages = append(messages, m)
+		}
+	}
+	var consts map[string]uint64
+	constGlob := filepath.Join(ws.dir, "*.const")
+	if constFiles, _ := filepath.Glob(constGlob); len(constFiles) != 0 {
+		if constFile := compiler.DeserializeConstFile(constGlob, eh); constFile != nil {
+			consts = constFile.Arch(ws.target.Arch)
+		}
+	} else if ws.target.OS != targets.TestOS {
+		ws.logf(messageInfo, "no const files in %v, can't compile descriptions", ws.dir)
+	}
+	if ws.target.OS == targets.TestOS {
+		if consts == nil {
+			consts = make(map[string]uint64)
+		}
+		constInfo := compiler.ExtractConsts(desc, ws.target, eh)
+		compiler.FabricateSyscallConsts(ws.target, constInfo, consts)
+	}
+	var res *compiler.Prog
+	if consts != nil {
+		res = compiler.Compile(desc, consts, ws.target, eh)
+	}
+	severity, msgType := severityError, messageError
+	if res != nil {
+		severity, msgType = severityWarning, messageWarning
+		for _, typ := range res.Types {
+			switch typ.(type) {
+			case *prog.StructType, *prog.UnionType, *prog.ResourceType:
+				name := typ.TemplateName()
+				ws.types[name] = append(ws.types[name], typ)
+			}
+		}
+	}
+	for _, m := range messages {
+		f := ws.files[m.pos.File]
+		if f == nil {
+			// Builtins and errors without position (e.g. failure to read const files).
+			ws.logf(msgType, "%v: %v", m.pos, m.msg)
+			continue
+		}
+		ws.compileDiags[m.pos.File] = append(ws.compileDiags[m.pos.File],
+			makeDiagnostic(f.data, m.pos, m.msg, severity))
+	}
+}
+
+func (ws *workspace) diagnostics(file string) []Diagnostic {
+	f := ws.files[file]
+	if f == nil {
+		return nil
+	}
+	diags := []Diagnostic{}
+	diags = append(diags, f.parseDiags...)
+	diags = append(diags, ws.compileDiags[file]...)
+	return diags
+}
+
+// identAt returns the declared identifier at the given position.
+func (ws *workspace) identAt(file string, line, col int) (string, ast.Pos, bool) {
+	contains := func(pos ast.Pos, name string) bool {
+		return pos.File == file && pos.Line == line && col >= pos.Col && col < pos.Col+len(name)
+	}
+	for name, d := range ws.decls {
+		if contains(d.name.Pos, name) {
+			return name, d.name.Pos, true
+		}
+	}
+	for name, refs := range ws.refs {
+		if ws.decls[name] == nil {
+			continue
+		}
+		for _, pos := range refs {
+			if contains(pos, name) {
+				return name, pos, true
+			}
+		}
+	}
+	return "", ast.Pos{}, false
+}
+
+func (ws *workspace) definition(name string) (ast.Pos, bool) {
+	d := ws.decls[name]
+	if d == nil {
+		return ast.Pos{}, false
+	}
+	return d.name.Pos, true
+}
+
+func (ws *workspace) references(name string, includeDecl bool) []ast.Pos {
+	var res []ast.Pos
+	if d := ws.decls[name]; includeDecl && d != nil {
+		res = append(res, d.name.Pos)
+	}
+	return append(res, ws.refs[name]...)
+}
+
+// hover returns markdown description of the declaration: its source and
+// sizes of the compiled types (all instantiations for templates).
+func (ws *workspace) hover(name string) string {
+	d := ws.decls[name]
+	if d == nil {
+		return ""
+	}
+	const maxLines = 30
+	src := strings.Split(strings.TrimSpace(ast.SerializeNode(d.node)), "\n")
+	if len(src) > maxLines {
+		src = append(src[:maxLines], "...")
+	}
+	buf := new(strings.Builder)
+	fmt.Fprintf(buf, "```\n%v\n```\n", strings.Join(src, "\n"))
+	for _, typ := range ws.types[name] {
+		size := "varlen"
+		if !typ.Varlen() {
+			size = fmt.Sprint(typ.Size())
+		}
+		fmt.Fprintf(buf, "\n`%v`: size %v, align %v (%v)\n", typ.Name(), size, typ.Alignment(), ws.target.Arch)
+	}
+	return buf.String()
+}
+
+func makeDiagnostic(data []byte, pos ast.Pos, msg string, severity int) Diagnostic {
+	start := posToLSP(pos)
+	end := start
+	end.Character += tokenLen(data, pos.Off)
+	return Diagnostic{
+		Range:    Range{start, end},
+		Severity: severity,
+		Source:   "syz-lsp",
+		Message:  msg,
+	}
+}
+
+func posToLSP(pos ast.Pos) Position {
+	p := Position{Line: pos.Line - 1, Character: pos.Col - 1}
+	if p.Line < 0 {
+		p.Line = 0
+	}
+	if p.Character < 0 {
+		p.Character = 0
+	}
+	return p
+}
+
+// tokenLen returns length of the identifier/number starting at the offset (at least 1),
+// so that diagnostics highlight the whole token.
+func tokenLen(data []byte, off int) int {
+	n := 0
+	for i := off; i < len(data); i++ {
+		c := data[i]
+		if c != '_' && c != '$' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			break
+		}
+		n++
+	}
+	if n == 0 {
+		n = 1
+	}
+	return n
+}
@@ -0,0 +1,213 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/google/syzkaller/pkg/cover"
+	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/pkg/instance"
+	"github.com/google/syzkaller/pkg/kconfig"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/mgrconfig"
+	"github.com/google/syzkaller/vm"
+)
+
+// coverOracle builds kernels with candidate configs, runs the corpus on them
+// and says which of the target functions/files are covered.
+type coverOracle struct {
+	cfg      *mgrconfig.Config
+	env      instance.Env
+	compiler string
+	corpus   string
+	targets  []string
+	// Symbolizing the kernel is expensive, so the report generator is reused
+	// while the built kernel object stays the same.
+	makeReportGenerator func() (*cover.ReportGenerator, error)
+	rg                  *cover.ReportGenerator
+	rgKernelHash        string
+	// Kernels are rebuilt in place, so the VM pool is reused while the kernel image path stays the same.
+	pool      *vm.Pool
+	poolImage string
+}
+
+func newCoverOracle(cfgFile, corpus, compiler string, targets []string) (*coverOracle, error) {
+	cfg, err := mgrconfig.LoadFile(cfgFile)
+	if err != nil {
+		return nil, err
+	}
+	env, err := instance.NewEnv(cfg, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &coverOracle{
+		cfg:      cfg,
+		env:      env,
+		compiler: compiler,
+		corpus:   corpus,
+		targets:  targets,
+		makeReportGenerator: func() (*cover.ReportGenerator, error) {
+			return cover.MakeReportGenerator(cfg.SysTarget, cfg.Type, cfg.KernelObj, cfg.KernelSrc,
+				cfg.KernelBuildSrc, nil, cfg.ModuleObj, nil, false)
+		},
+	}, nil
+}
+
+func (oracle *coverOracle) cover(cf *kconfig.ConfigFile) (map[string]bool, error) {
+	log.Logf(0, "building kernel with %v configs", len(cf.Configs))
+	_, _, err := oracle.env.BuildKernel(&instance.BuildKernelConfig{
+		CompilerBin:  oracle.compiler,
+		KernelConfig: cf.Serialize(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("kernel build failed: %v", err)
+	}
+	pcs, err := oracle.runCorpus()
+	if err != nil {
+		return nil, err
+	}
+	rg, err := oracle.reportGenerator()
+	if err != nil {
+		return nil, err
+	}
+	funcs, files := rg.CoveredSymbols(pcs)
+	res := coveredTargets(oracle.targets, funcs, files)
+	log.Logf(0, "corpus covers %v PCs, %v/%v targets", len(pcs), len(res), len(oracle.targets))
+	return res, nil
+}
+
+// reportGenerator returns report generator for the current kernel object.
+func (oracle *coverOracle) reportGenerator() (*cover.ReportGenerator, error) {
+	data, err := os.ReadFile(filepath.Join(oracle.cfg.KernelObj, oracle.cfg.SysTarget.KernelObject))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kernel object: %v", err)
+	}
+	kernelHash := hash.String(data)
+	if oracle.rg != nil && oracle.rgKernelHash == kernelHash {
+		return oracle.rg, nil
+	}
+	rg, err := oracle.makeReportGenerator()
+	if err != nil {
+		return nil, err
+	}
+	oracle.rg, oracle.rgKernelHash = rg, kernelHash
+	return rg, nil
+}
+
+func coveredTargets(targets []string, funcs, files map[string]bool) map[string]bool {
+	res := make(map[string]bool)
+	for _, target := range targets {
+		if funcs[target] || files[target] {
+			res[target] = true
+		}
+	}
+	return res
+}
+
+// vmPool returns VM pool for the current kernel image.
+func (oracle *coverOracle) vmPool() (*vm.Pool, error) {
+	if oracle.pool != nil && oracle.poolImage == oracle.cfg.Image {
+		return oracle.pool, nil
+	}
+	if oracle.pool != nil {
+		oracle.pool.Close()
+		oracle.pool = nil
+	}
+	pool, err := vm.Create(oracle.cfg, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create VM pool: %v", err)
+	}
+	oracle.pool, oracle.poolImage = pool, oracle.cfg.Image
+	return pool, nil
+}
+
+// runCorpus boots a VM with the current kernel image, executes the corpus there and returns covered PCs.
+func (oracle *coverOracle) runCorpus() ([]uint64, error) {
+	cfg := oracle.cfg
+	vmPool, err := oracle.vmPool()
+	if err != nil {
+		return nil, err
+	}
+	inst, err := vmPool.Create(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create VM: %v", err)
+	}
+	defer inst.Close()
+	execprogBin, err := inst.Copy(cfg.ExecprogBin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy syz-execprog to VM: %v", err)
+	}
+	executorBin := cfg.SysTarget.ExecutorBin
+	if executorBin == "" {
+		if executorBin, err = inst.Copy(cfg.ExecutorBin); err != nil {
+			return nil, fmt.Errorf("failed to copy syz-executor to VM: %v", err)
+		}
+	}
+	corpus, err := inst.Copy(oracle.corpus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy corpus to VM: %v", err)
+	}
+	// Coverage files are written in the VM, so we print them to get them back.
+	// PCs are prefixed with a marker to distinguish them from kernel/execprog output.
+	// There are no coverage files if nothing was covered, so the glob may match nothing.
+	coverFile := path.Join(path.Dir(execprogBin), "syz-minconfig-cover")
+	cmd := fmt.Sprintf("%v -executor=%v -os=%v -arch=%v -sandbox=%v -procs=1 -repeat=1 -coverfile=%v %v"+
+		" && cat %v.* 2>/dev/null | sed 's/^/%v/'",
+		execprogBin, executorBin, cfg.TargetOS, cfg.TargetArch, cfg.Sandbox, coverFile, corpus,
+		coverFile, pcMarker)
+	outc, errc, err := inst.Run(time.Hour*cfg.Timeouts.Scale, nil, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run syz-execprog in VM: %v", err)
+	}
+	var output []byte
+	for {
+		select {
+		case out := <-outc:
+			output = append(output, out...)
+		case err := <-errc:
+			if err != nil {
+				return nil, fmt.Errorf("syz-execprog failed: %v\n%s", err, output)
+			}
+			for {
+				select {
+				case out := <-outc:
+					output = append(output, out...)
+				default:
+					return parsePCs(output), nil
+				}
+			}
+		}
+	}
+}
+
+const pcMarker = "SYZ-MINCONFIG-PC: "
+
+// parsePCs extracts PCs printed by syz-execprog coverage files from the VM output.
+// Only lines with pcMarker are considered, the marker may be preceded by console noise.
+func parsePCs(output []byte) []uint64 {
+	unique := make(map[uint64]bool)
+	var pcs []uint64
+	for s := bufio.NewScanner(bytes.NewReader(output)); s.Scan(); {
+		line := s.Bytes()
+		pos := bytes.Index(line, []byte(pcMarker))
+		if pos == -1 {
+			continue
+		}
+		pc, err := strconv.ParseUint(string(bytes.TrimSpace(line[pos+len(pcMarker):])), 0, 64)
+		if err != nil || unique[pc] {
+			continue
+		}
+		unique[pc] = true
+		pcs = append(pcs, pc)
+	}
+	return pcs
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/syzkaller/pkg/cover"
+	"github.com/google/syzkaller/pkg/mgrconfig"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func TestParsePCs(t *testing.T) {
+	output := []byte(`
+executing program 0:
+0xffffffff81000100
+[   10.123456] random: crng init done
+SYZ-MINCONFIG-PC: 0xffffffff81000000
+SYZ-MINCONFIG-PC: 0xffffffff81000010
+[   10.223456] foo: bar SYZ-MINCONFIG-PC: 0xffffffff81000020
+SYZ-MINCONFIG-PC: 0xffffffff81000000
+SYZ-MINCONFIG-PC: garbage
+`)
+	want := []uint64{0xffffffff81000000, 0xffffffff81000010, 0xffffffff81000020}
+	if got := parsePCs(output); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got PCs %x, want %x", got, want)
+	}
+}
+
+func TestCoveredTargets(t *testing.T) {
+	funcs := map[string]bool{"rose_rx_call_request": true, "ax25_connect": true}
+	files := map[string]bool{"net/ax25/af_ax25.c": true}
+	got := coveredTargets([]string{"net/ax25/af_ax25.c", "rose_rx_call_request", "net/rose/af_rose.c"},
+		funcs, files)
+	want := map[string]bool{"net/ax25/af_ax25.c": true, "rose_rx_call_request": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestReportGeneratorCache(t *testing.T) {
+	dir := t.TempDir()
+	target := targets.Get(targets.Linux, targets.AMD64)
+	kernelObject := filepath.Join(dir, target.KernelObject)
+	created := 0
+	oracle := &coverOracle{
+		cfg: &mgrconfig.Config{
+			KernelObj: dir,
+			Derived:   mgrconfig.Derived{SysTarget: target},
+		},
+		makeReportGenerator: func() (*cover.ReportGenerator, error) {
+			created++
+			return new(cover.ReportGenerator), nil
+		},
+	}
+	for i, test := range []struct {
+		kernel  string
+		created int
+	}{
+		{"kernel1", 1},
+		{"kernel1", 1},
+		{"kernel2", 2},
+		{"kernel1", 3},
+	} {
+		if err := os.WriteFile(kernelObject, []byte(test.kernel), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := oracle.reportGenerator(); err != nil {
+			t.Fatal(err)
+		}
+		if created != test.created {
+			t.Fatalf("step #%v: report generator created %v times, want %v", i, created, test.created)
+		}
+	}
+}
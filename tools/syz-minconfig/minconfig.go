@@ -7,6 +7,15 @@
 //	$ go run tools/syz-minconfig/minconfig.go -sourcedir /src/linux -configs CAIF_NETDEV,CAIF_USB \
 //		-base dashboard/config/linux/upstream-kasan-base.config \
 //		-full dashboard/config/linux/upstream-kasan.config \
+//
+// With -targets it instead minimizes the config while preserving coverage of the target functions/files
+// by the corpus. Every candidate config is built, booted using the manager config and the corpus
+// is executed with syz-execprog to collect coverage:
+//
+//	$ go run ./tools/syz-minconfig -sourcedir /src/linux -manager manager.cfg -corpus corpus.db \
+//		-targets net/ax25/af_ax25.c,rose_rx_call_request \
+//		-base dashboard/config/linux/upstream-kasan-base.config \
+//		-full dashboard/config/linux/upstream-kasan.config
 package main
 
 import (
@@ -29,6 +38,10 @@ func main() {
 		flagFull      = flag.String("full", "", "full config")
 		flagConfigs   = flag.String("configs", "", "comma-separated list of configs for the crash predicate")
 		flagArch      = flag.String("arch", runtime.GOARCH, "kernel arch")
+		flagTargets   = flag.String("targets", "", "comma-separated list of functions/files whose coverage to preserve")
+		flagManager   = flag.String("manager", "", "manager config used to build and boot kernels (with -targets)")
+		flagCorpus    = flag.String("corpus", "", "corpus.db used to collect coverage (with -targets)")
+		flagCompiler  = flag.String("compiler", "", "kernel compiler (with -targets)")
 	)
 	flag.Parse()
 	kconf, err := kconfig.Parse(targets.Get("linux", *flagArch), filepath.Join(*flagSourceDir, "Kconfig"))
@@ -54,7 +67,17 @@ func main() {
 	gt := &debugtracer.GenericTracer{
 		TraceWriter: os.Stdout,
 	}
-	res, err := kconf.Minimize(base, full, pred, gt)
+	var res *kconfig.ConfigFile
+	if *flagTargets != "" {
+		targets := strings.Split(*flagTargets, ",")
+		oracle, oracleErr := newCoverOracle(*flagManager, *flagCorpus, *flagCompiler, targets)
+		if oracleErr != nil {
+			tool.Fail(oracleErr)
+		}
+		res, err = kconf.MinimizeCoverage(base, full, targets, oracle.cover, gt)
+	} else {
+		res, err = kconf.Minimize(base, full, pred, gt)
+	}
 	if err != nil {
 		tool.Fail(err)
 	}
@@ -74,6 +74,9 @@ func main() {
 		fmt.Printf("simplifying prog options: %v\n", stats.SimplifyProgTime)
 		fmt.Printf("extracting C: %v\n", stats.ExtractCTime)
 		fmt.Printf("simplifying C: %v\n", stats.SimplifyCTime)
+		fmt.Printf("estimating reliability: %v\n", stats.ReliabilityTime)
+		fmt.Printf("prog reliability: %v\n", stats.ProgReliability)
+		fmt.Printf("C reliability: %v\n", stats.CReliability)
 	}
 	if res == nil {
 		return
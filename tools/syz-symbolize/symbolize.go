@@ -24,6 +24,7 @@ var (
 	flagKernelObj = flag.String("kernel_obj", ".", "path to kernel build/obj dir")
 	flagKernelSrc = flag.String("kernel_src", "", "path to kernel sources (defaults to kernel_obj)")
 	flagOutDir    = flag.String("outdir", "", "output directory")
+	flagSymbolize = flag.String("symbolizer", "addr2line", "symbolizer to use (addr2line/native)")
 )
 
 func main() {
@@ -36,6 +37,7 @@ func main() {
 	cfg, err := mgrconfig.LoadPartialData([]byte(`{
 		"kernel_obj": "` + *flagKernelObj + `",
 		"kernel_src": "` + *flagKernelSrc + `",
+		"symbolizer": "` + *flagSymbolize + `",
 		"target": "` + *flagOS + "/" + *flagArch + `"
 	}`))
 	if err != nil {
@@ -27,6 +27,7 @@ import (
 
 func init() {
 	var _ vmimpl.Infoer = (*instance)(nil)
+	var _ vmimpl.Snapshotter = (*instance)(nil)
 	vmimpl.Register("qemu", ctor, true)
 }
 
@@ -71,6 +72,10 @@ type Config struct {
 	Mem int `json:"mem"`
 	// For building kernels without -snapshot for pkg/build (true by default).
 	Snapshot bool `json:"snapshot"`
+	// Allow saving and restoring VM state with savevm/loadvm (false by default).
+	// Used by syz-manager and pkg/repro if snapshot_resets is enabled in the manager config.
+	// Requires either snapshot (the default) or a qcow2 image.
+	Savevm bool `json:"savevm"`
 	// Magic key used to dongle macOS to the device.
 	AppleSmcOsk string `json:"apple_smc_osk"`
 }
@@ -717,6 +722,34 @@ func (inst *instance) Info() ([]byte, error) {
 	return []byte(info), nil
 }
 
+const vmSnapshotTag = "syz"
+
+func (inst *instance) Snapshot() error {
+	if !inst.cfg.Savevm {
+		return vmimpl.ErrSnapshotsNotSupported
+	}
+	return inst.hmpCheck("savevm " + vmSnapshotTag)
+}
+
+func (inst *instance) Restore() error {
+	if !inst.cfg.Savevm {
+		return vmimpl.ErrSnapshotsNotSupported
+	}
+	return inst.hmpCheck("loadvm " + vmSnapshotTag)
+}
+
+// hmpCheck executes an HMP command that does not produce any output on success.
+func (inst *instance) hmpCheck(cmd string) error {
+	output, err := inst.hmp(cmd, 0)
+	if err != nil {
+		return fmt.Errorf("%v failed: %v", cmd, err)
+	}
+	if output = strings.TrimSpace(output); output != "" {
+		return fmt.Errorf("%v failed: %v", cmd, output)
+	}
+	return nil
+}
+
 func (inst *instance) Diagnose(rep *report.Report) ([]byte, bool) {
 	if inst.target.OS == targets.Linux {
 		if output, wait, handled := vmimpl.DiagnoseLinux(rep, inst.ssh); handled {
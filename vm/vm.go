@@ -57,9 +57,10 @@ type Instance struct {
 }
 
 var (
-	Shutdown               = vmimpl.Shutdown
-	ErrTimeout             = vmimpl.ErrTimeout
-	_          BootErrorer = vmimpl.BootError{}
+	Shutdown                             = vmimpl.Shutdown
+	ErrTimeout                           = vmimpl.ErrTimeout
+	ErrSnapshotsNotSupported             = vmimpl.ErrSnapshotsNotSupported
+	_                        BootErrorer = vmimpl.BootError{}
 )
 
 type BootErrorer interface {
@@ -182,6 +183,23 @@ func (inst *Instance) Info() ([]byte, error) {
 	return nil, nil
 }
 
+// Snapshot saves the current VM state, so that it can be later restored with Restore.
+// Returns ErrSnapshotsNotSupported if the VM type does not support snapshots.
+func (inst *Instance) Snapshot() error {
+	if ss, ok := inst.impl.(vmimpl.Snapshotter); ok {
+		return ss.Snapshot()
+	}
+	return ErrSnapshotsNotSupported
+}
+
+// Restore reverts the VM to the state saved by the last Snapshot call.
+func (inst *Instance) Restore() error {
+	if ss, ok := inst.impl.(vmimpl.Snapshotter); ok {
+		return ss.Restore()
+	}
+	return ErrSnapshotsNotSupported
+}
+
 func (inst *Instance) diagnose(rep *report.Report) ([]byte, bool) {
 	if rep == nil {
 		panic("rep is nil")
@@ -5,6 +5,7 @@ package vm
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -413,3 +414,48 @@ func TestVMType(t *testing.T) {
 		}
 	}
 }
+
+type snapshotTestInstance struct {
+	testInstance
+	snapshots int
+	restores  int
+}
+
+func (inst *snapshotTestInstance) Snapshot() error {
+	inst.snapshots++
+	return nil
+}
+
+func (inst *snapshotTestInstance) Restore() error {
+	if inst.snapshots == 0 {
+		return errors.New("no snapshot")
+	}
+	inst.restores++
+	return nil
+}
+
+func TestSnapshot(t *testing.T) {
+	inst := &Instance{impl: &testInstance{}}
+	if err := inst.Snapshot(); err != ErrSnapshotsNotSupported {
+		t.Fatalf("Snapshot returned %v, want %v", err, ErrSnapshotsNotSupported)
+	}
+	if err := inst.Restore(); err != ErrSnapshotsNotSupported {
+		t.Fatalf("Restore returned %v, want %v", err, ErrSnapshotsNotSupported)
+	}
+	impl := &snapshotTestInstance{}
+	inst = &Instance{impl: impl}
+	if err := inst.Restore(); err == nil {
+		t.Fatalf("Restore without snapshot succeeded")
+	}
+	if err := inst.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := inst.Restore(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if impl.snapshots != 1 || impl.restores != 2 {
+		t.Fatalf("got %v snapshots and %v restores, want 1 and 2", impl.snapshots, impl.restores)
+	}
+}
@@ -67,6 +67,14 @@ type Infoer interface {
 	Info() ([]byte, error)
 }
 
+// Snapshotter is an optional interface that can be implemented by Instance.
+type Snapshotter interface {
+	// Snapshot saves the current state of the VM.
+	Snapshot() error
+	// Restore reverts the VM to the state saved by the last Snapshot call.
+	Restore() error
+}
+
 // Env contains global constant parameters for a pool of VMs.
 type Env struct {
 	// Unique name
@@ -126,6 +134,8 @@ var (
 	// Close to interrupt all pending operations in all VMs.
 	Shutdown   = make(chan struct{})
 	ErrTimeout = errors.New("timeout")
+	// Returned by Snapshotter methods if the VM does not support snapshots in the current configuration.
+	ErrSnapshotsNotSupported = errors.New("VM snapshots are not supported")
 
 	Types = make(map[string]Type)
 )